@@ -0,0 +1,309 @@
+package lockbook
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type ArchiveFormat int
+
+const (
+	ArchiveZip ArchiveFormat = iota
+	ArchiveTarGz
+)
+
+// ParseArchiveFormat returns the archive format for "zip" or "tgz" (also "tar.gz").
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	switch strings.ToLower(s) {
+	case "zip":
+		return ArchiveZip, nil
+	case "tgz", "tar.gz":
+		return ArchiveTarGz, nil
+	default:
+		return 0, fmt.Errorf("unknown archive format %q", s)
+	}
+}
+
+// ArchiveFormatFromPath determines the archive format from a file name's extension.
+func ArchiveFormatFromPath(p string) (ArchiveFormat, bool) {
+	p = strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(p, ".zip"):
+		return ArchiveZip, true
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return ArchiveTarGz, true
+	default:
+		return 0, false
+	}
+}
+
+func (f ArchiveFormat) Ext() string {
+	switch f {
+	case ArchiveZip:
+		return ".zip"
+	case ArchiveTarGz:
+		return ".tar.gz"
+	default:
+		return ""
+	}
+}
+
+// archiveWriter is the common ground between zip and tar writers.
+type archiveWriter interface {
+	addDir(name string, f *File) error
+	addDoc(name string, f *File, data []byte) error
+	Close() error
+}
+
+type zipArchive struct{ zw *zip.Writer }
+
+func (a zipArchive) addDir(name string, f *File) error {
+	_, err := a.zw.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: f.Lastmod})
+	return err
+}
+
+func (a zipArchive) addDoc(name string, f *File, data []byte) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Modified: f.Lastmod, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (a zipArchive) Close() error { return a.zw.Close() }
+
+type tarGzArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a tarGzArchive) addDir(name string, f *File) error {
+	return a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0o755,
+		ModTime:  f.Lastmod,
+	})
+}
+
+func (a tarGzArchive) addDoc(name string, f *File, data []byte) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  f.Lastmod,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.tw.Write(data)
+	return err
+}
+
+func (a tarGzArchive) Close() error {
+	err := a.tw.Close()
+	if gzErr := a.gz.Close(); err == nil {
+		err = gzErr
+	}
+	return err
+}
+
+// ExportArchive streams the file with the given ID (and everything in it if it's a
+// folder) into an archive written to `w`. Paths within the archive start with the
+// file's name, and each entry's modification time is the file's `Lastmod`. Links are
+// skipped. The archive writers are closed even if the export fails partway, and the
+// first error is returned.
+func ExportArchive(core Core, id FileID, w io.Writer, format ArchiveFormat) (err error) {
+	var aw archiveWriter
+	switch format {
+	case ArchiveZip:
+		aw = zipArchive{zw: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = tarGzArchive{gz: gz, tw: tar.NewWriter(gz)}
+	default:
+		return fmt.Errorf("unknown archive format %d", format)
+	}
+	defer func() {
+		if cerr := aw.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("closing archive: %w", cerr)
+		}
+	}()
+	target, err := core.FileByID(id)
+	if err != nil {
+		return fmt.Errorf("file by id %q: %w", id, err)
+	}
+	files := []File{target}
+	if target.IsDir() {
+		children, err := core.GetAndGetChildrenRecursively(id)
+		if err != nil {
+			return fmt.Errorf("getting children of %q: %w", id, err)
+		}
+		files = children
+	}
+	byID := make(map[FileID]*File, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	// An entry's name is the path of names from the target down to the file.
+	var nameOf func(f *File) string
+	nameOf = func(f *File) string {
+		if f.ID == id || f.IsRoot() {
+			return f.Name
+		}
+		parent, ok := byID[f.Parent]
+		if !ok {
+			return f.Name
+		}
+		return nameOf(parent) + "/" + f.Name
+	}
+	// Sorting by name puts folders before their contents.
+	names := make(map[FileID]string, len(files))
+	for i := range files {
+		names[files[i].ID] = nameOf(&files[i])
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return names[files[i].ID] < names[files[j].ID]
+	})
+
+	for i := range files {
+		f := &files[i]
+		name := names[f.ID]
+		switch f.Type.(type) {
+		case FileTypeFolder:
+			err = aw.addDir(name, f)
+		case FileTypeDocument:
+			var data []byte
+			data, err = core.ReadDocument(f.ID)
+			if err != nil {
+				return fmt.Errorf("reading %q: %w", name, err)
+			}
+			err = aw.addDoc(name, f, data)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("archiving %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ImportArchive extracts an archive into the folder with the given ID, creating any
+// folders and documents as needed (existing documents are overwritten). Progress is
+// reported in the same way as `ImportFile`, except that tarballs don't report a total.
+func ImportArchive(core Core, r io.Reader, format ArchiveFormat, dest FileID, fn func(ImportFileInfo)) error {
+	if fn == nil {
+		fn = func(ImportFileInfo) {}
+	}
+	destPath, err := core.PathByID(dest)
+	if err != nil {
+		return fmt.Errorf("getting path of %q: %w", dest, err)
+	}
+	destPath = strings.TrimSuffix(destPath, "/") + "/"
+
+	extract := func(name string, isDir bool, rc io.Reader) error {
+		clean := path.Clean(strings.TrimPrefix(name, "/"))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid archive entry %q", name)
+		}
+		lbPath := destPath + clean
+		if isDir {
+			_, exists, err := MaybeFileByPath(core, lbPath+"/")
+			if err != nil || exists {
+				return err
+			}
+			_, err = core.CreateFileAtPath(lbPath + "/")
+			return err
+		}
+		fn(ImportFileInfo{DiskPath: name})
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		f, err := WriteFileAtPath(core, lbPath, data)
+		if err != nil {
+			return err
+		}
+		fn(ImportFileInfo{FileDone: &f})
+		return nil
+	}
+
+	switch format {
+	case ArchiveZip:
+		zr, err := newZipReader(r)
+		if err != nil {
+			return fmt.Errorf("opening zip: %w", err)
+		}
+		numFiles := 0
+		for _, zf := range zr.File {
+			if !zf.FileInfo().IsDir() {
+				numFiles++
+			}
+		}
+		fn(ImportFileInfo{Total: numFiles})
+		for _, zf := range zr.File {
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("opening %q: %w", zf.Name, err)
+			}
+			err = extract(zf.Name, zf.FileInfo().IsDir(), rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("extracting %q: %w", zf.Name, err)
+			}
+		}
+		return nil
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("opening gzip: %w", err)
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading tar: %w", err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeDir, tar.TypeReg:
+			default:
+				continue
+			}
+			if err := extract(hdr.Name, hdr.Typeflag == tar.TypeDir, tr); err != nil {
+				return fmt.Errorf("extracting %q: %w", hdr.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown archive format %d", format)
+	}
+}
+
+// newZipReader avoids reading the whole archive into memory when it's a file.
+func newZipReader(r io.Reader) (*zip.Reader, error) {
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			return zip.NewReader(f, fi.Size())
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
@@ -0,0 +1,60 @@
+package lockbook
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+// failingReadCore fails to read one document.
+type failingReadCore struct {
+	*fakeCore
+	bad FileID
+}
+
+func (c *failingReadCore) ReadDocument(id FileID) ([]byte, error) {
+	if id == c.bad {
+		return nil, fakeErr(CodeUnexpected, "read failed")
+	}
+	return c.fakeCore.ReadDocument(id)
+}
+
+func TestExportArchiveClosesOnError(t *testing.T) {
+	fc := newFakeCore(t)
+	a := fc.mustCreate(t, "/notes/a.md")
+	b := fc.mustCreate(t, "/notes/b.md")
+	if err := fc.WriteDocument(a.ID, []byte("aaa")); err != nil {
+		t.Fatal(err)
+	}
+	core := &failingReadCore{fakeCore: fc, bad: b.ID}
+
+	var buf bytes.Buffer
+	err := ExportArchive(core, a.Parent, &buf, ArchiveTarGz)
+	var lbErr *Error
+	if !errors.As(err, &lbErr) || lbErr.Code != CodeUnexpected {
+		t.Fatalf("ExportArchive = %v, want the read error", err)
+	}
+	// The writers were still closed, so what was written so far is a complete tarball.
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading the partial archive: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 2 || names[1] != "notes/a.md" {
+		t.Errorf("partial archive has %q", names)
+	}
+}
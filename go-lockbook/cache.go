@@ -0,0 +1,278 @@
+package lockbook
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CachedCore is a core that serves metadata reads (file lookups, children and paths) from
+// an in-memory `Tree` instead of calling into the wrapped core each time. The tree is
+// loaded on first use, kept up to date by the mutations made through this core, and
+// dropped after a sync that pulls changes since the server can change anything.
+//
+// Changes made to the wrapped core directly aren't seen until `Invalidate` is called.
+type CachedCore struct {
+	Core
+
+	mu   sync.Mutex
+	tree *Tree
+}
+
+// NewCachedCore returns a core that caches the metadata of the given core.
+func NewCachedCore(core Core) *CachedCore {
+	return &CachedCore{Core: core}
+}
+
+// Invalidate drops the cached metadata so that it's reloaded on the next read.
+func (c *CachedCore) Invalidate() {
+	c.mu.Lock()
+	c.tree = nil
+	c.mu.Unlock()
+}
+
+// snapshot returns the cached tree, loading it if needed. It must be called with the
+// mutex held. A nil tree means it couldn't be loaded (e.g. there's no account yet) and
+// the caller should fall back to the wrapped core.
+func (c *CachedCore) snapshot() *Tree {
+	if c.tree == nil {
+		t, err := LoadTree(c.Core)
+		if err != nil {
+			return nil
+		}
+		c.tree = t
+	}
+	return c.tree
+}
+
+// refresh replaces a file in the cached tree with its current metadata from the wrapped
+// core. If that fails, the cache is dropped rather than left stale.
+func (c *CachedCore) refresh(id FileID) {
+	if c.tree == nil {
+		return
+	}
+	f, err := c.Core.FileByID(id)
+	if err != nil {
+		c.tree = nil
+		return
+	}
+	c.tree.put(f)
+}
+
+func (c *CachedCore) CreateAccount(uname, apiURL string, welcome bool) (Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tree = nil
+	return c.Core.CreateAccount(uname, apiURL, welcome)
+}
+
+func (c *CachedCore) ImportAccount(acctStr string) (Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tree = nil
+	return c.Core.ImportAccount(acctStr)
+}
+
+func (c *CachedCore) FileByID(id FileID) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.snapshot(); t != nil {
+		if f, ok := t.File(id); ok {
+			return f, nil
+		}
+	}
+	return c.Core.FileByID(id)
+}
+
+func (c *CachedCore) FileByPath(lbPath string) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.snapshot(); t != nil {
+		if f, ok := t.lookupPath(lbPath); ok {
+			return f, nil
+		}
+	}
+	return c.Core.FileByPath(lbPath)
+}
+
+func (c *CachedCore) GetRoot() (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.snapshot(); t != nil {
+		if root, ok := t.Root(); ok {
+			return root, nil
+		}
+	}
+	return c.Core.GetRoot()
+}
+
+func (c *CachedCore) GetChildren(id FileID) ([]File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.snapshot(); t != nil {
+		if _, ok := t.files[id]; ok {
+			return t.Children(id), nil
+		}
+	}
+	return c.Core.GetChildren(id)
+}
+
+func (c *CachedCore) GetAndGetChildrenRecursively(id FileID) ([]File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.snapshot()
+	if t == nil {
+		return c.Core.GetAndGetChildrenRecursively(id)
+	}
+	f, ok := t.files[id]
+	if !ok {
+		return c.Core.GetAndGetChildrenRecursively(id)
+	}
+	files := make([]File, 0, 1+t.SubtreeSize(id))
+	var add func(f *File)
+	add = func(f *File) {
+		files = append(files, *f)
+		for _, ch := range t.children[f.ID] {
+			add(ch)
+		}
+	}
+	add(f)
+	return files, nil
+}
+
+func (c *CachedCore) ListMetadatas() ([]File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.snapshot()
+	if t == nil {
+		return c.Core.ListMetadatas()
+	}
+	files := make([]File, 0, len(t.files))
+	for _, f := range t.files {
+		files = append(files, *f)
+	}
+	return files, nil
+}
+
+func (c *CachedCore) PathByID(id FileID) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.snapshot(); t != nil {
+		if p, err := t.Path(id); err == nil {
+			return p, nil
+		}
+	}
+	return c.Core.PathByID(id)
+}
+
+func (c *CachedCore) WriteDocument(id FileID, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.WriteDocument(id, data); err != nil {
+		return err
+	}
+	// The size and last modified time changed.
+	c.refresh(id)
+	return nil
+}
+
+func (c *CachedCore) CreateFile(name string, parentID FileID, typ FileType) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.Core.CreateFile(name, parentID, typ)
+	if err != nil {
+		return File{}, err
+	}
+	if c.tree != nil {
+		c.tree.put(f)
+	}
+	return f, nil
+}
+
+func (c *CachedCore) CreateFileAtPath(lbPath string) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Any number of missing folders along the path may have been created.
+	c.tree = nil
+	return c.Core.CreateFileAtPath(lbPath)
+}
+
+func (c *CachedCore) DeleteFile(id FileID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.DeleteFile(id); err != nil {
+		return err
+	}
+	if c.tree != nil {
+		c.tree.remove(id)
+	}
+	return nil
+}
+
+func (c *CachedCore) RenameFile(id FileID, newName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.RenameFile(id, newName); err != nil {
+		return err
+	}
+	c.refresh(id)
+	return nil
+}
+
+func (c *CachedCore) MoveFile(srcID, destID FileID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.MoveFile(srcID, destID); err != nil {
+		return err
+	}
+	c.refresh(srcID)
+	return nil
+}
+
+// ImportFile drops the cached metadata once the import is done. Like `SyncAll`, the
+// cache isn't locked while importing since it can take a while.
+func (c *CachedCore) ImportFile(src string, dest FileID, fn func(ImportFileInfo)) error {
+	defer c.Invalidate()
+	return c.Core.ImportFile(src, dest, fn)
+}
+
+// SyncAll calculates the work and syncs it with `SyncWork`.
+func (c *CachedCore) SyncAll(fn func(SyncProgress)) error {
+	work, err := c.Core.CalculateWork()
+	if err != nil {
+		return fmt.Errorf("calculating work: %w", err)
+	}
+	return c.SyncWork(work, fn)
+}
+
+// SyncWork drops the cached metadata once the sync is done if it pulls anything, whether
+// or not it succeeded, since files may have been pulled before an error. A sync that only
+// pushes just refreshes the pushed files. The cache isn't locked during the sync so that
+// reads aren't held up by it.
+func (c *CachedCore) SyncWork(work WorkCalculated, fn func(SyncProgress)) error {
+	defer c.synced(work)
+	return SyncWithWork(c.Core, work, fn)
+}
+
+func (c *CachedCore) synced(work WorkCalculated) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, wu := range work.WorkUnits {
+		if wu.Type == WorkUnitTypeServer {
+			c.tree = nil
+			return
+		}
+	}
+	for _, wu := range work.WorkUnits {
+		c.refresh(wu.ID)
+	}
+}
+
+func (c *CachedCore) ShareFile(id FileID, uname string, mode ShareMode) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.ShareFile(id, uname, mode); err != nil {
+		return err
+	}
+	c.refresh(id)
+	return nil
+}
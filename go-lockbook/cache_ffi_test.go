@@ -0,0 +1,29 @@
+//go:build ffibench
+
+package lockbook
+
+import (
+	"os"
+	"testing"
+)
+
+// These run the cache benchmarks against a real core. They need the native library and
+// the data directory of an account with some files in it, e.g.:
+//
+//	LOCKBOOK_BENCH_PATH=~/.lockbook go test -tags ffibench -run - -bench FFI ./go-lockbook
+
+func newFFICore(b *testing.B) Core {
+	dir := os.Getenv("LOCKBOOK_BENCH_PATH")
+	if dir == "" {
+		b.Skip("LOCKBOOK_BENCH_PATH isn't set")
+	}
+	core, err := NewCore(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return core
+}
+
+func BenchmarkFFIFileByID(b *testing.B)    { benchCores(b, newFFICore, benchFileByID) }
+func BenchmarkFFIGetChildren(b *testing.B) { benchCores(b, newFFICore, benchGetChildren) }
+func BenchmarkFFIPathByID(b *testing.B)    { benchCores(b, newFFICore, benchPathByID) }
@@ -0,0 +1,227 @@
+package lockbook
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// slowCore adds a fixed cost to each metadata call. It only shows how much of a fixed
+// per-call cost the cache saves; the `ffibench` build tag runs the same benchmarks
+// against a real core (see cache_ffi_test.go).
+type slowCore struct {
+	*fakeCore
+	delay time.Duration
+}
+
+func (c *slowCore) wait() {
+	// Spin rather than sleep, since sleeps this short overshoot by far more than the delay.
+	for start := time.Now(); time.Since(start) < c.delay; {
+	}
+}
+
+func (c *slowCore) FileByID(id FileID) (File, error) {
+	c.wait()
+	return c.fakeCore.FileByID(id)
+}
+
+func (c *slowCore) GetChildren(id FileID) ([]File, error) {
+	c.wait()
+	return c.fakeCore.GetChildren(id)
+}
+
+func (c *slowCore) PathByID(id FileID) (string, error) {
+	c.wait()
+	return c.fakeCore.PathByID(id)
+}
+
+func (c *slowCore) ListMetadatas() ([]File, error) {
+	c.wait()
+	return c.fakeCore.ListMetadatas()
+}
+
+// newSlowCore returns a slow core with 100 folders of 100 documents each.
+func newSlowCore(b *testing.B) Core {
+	fc := newFakeCore(b)
+	for i := 0; i < 100; i++ {
+		dir := &File{ID: uuid.Must(uuid.NewV4()), Parent: fc.root, Name: fmt.Sprintf("dir%03d", i), Type: FileTypeFolder{}}
+		fc.files[dir.ID] = dir
+		for j := 0; j < 100; j++ {
+			doc := &File{ID: uuid.Must(uuid.NewV4()), Parent: dir.ID, Name: fmt.Sprintf("doc%03d.md", j), Type: FileTypeDocument{}}
+			fc.files[doc.ID] = doc
+		}
+	}
+	return &slowCore{fakeCore: fc, delay: 20 * time.Microsecond}
+}
+
+// benchFunc is a benchmark body run against a core with the IDs of its folders and
+// documents.
+type benchFunc func(b *testing.B, core Core, dirs, docs []FileID)
+
+// benchCores runs the benchmark against the core from `newCore` both directly and
+// through a `CachedCore` (whose tree is loaded before the timer starts).
+func benchCores(b *testing.B, newCore func(b *testing.B) Core, fn benchFunc) {
+	ids := func(b *testing.B, core Core) (dirs, docs []FileID) {
+		files, err := core.ListMetadatas()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, f := range files {
+			switch f.Type.(type) {
+			case FileTypeFolder:
+				dirs = append(dirs, f.ID)
+			case FileTypeDocument:
+				docs = append(docs, f.ID)
+			}
+		}
+		if len(dirs) == 0 || len(docs) == 0 {
+			b.Skip("the core needs at least one folder and one document")
+		}
+		return dirs, docs
+	}
+	b.Run("raw", func(b *testing.B) {
+		core := newCore(b)
+		dirs, docs := ids(b, core)
+		b.ResetTimer()
+		fn(b, core, dirs, docs)
+	})
+	b.Run("cached", func(b *testing.B) {
+		cc := NewCachedCore(newCore(b))
+		dirs, docs := ids(b, cc)
+		b.ResetTimer()
+		fn(b, cc, dirs, docs)
+	})
+}
+
+func benchFileByID(b *testing.B, core Core, _, docs []FileID) {
+	for i := 0; i < b.N; i++ {
+		if _, err := core.FileByID(docs[i%len(docs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchGetChildren(b *testing.B, core Core, dirs, _ []FileID) {
+	for i := 0; i < b.N; i++ {
+		if _, err := core.GetChildren(dirs[i%len(dirs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchPathByID(b *testing.B, core Core, _, docs []FileID) {
+	for i := 0; i < b.N; i++ {
+		if _, err := core.PathByID(docs[i%len(docs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileByID(b *testing.B)    { benchCores(b, newSlowCore, benchFileByID) }
+func BenchmarkGetChildren(b *testing.B) { benchCores(b, newSlowCore, benchGetChildren) }
+func BenchmarkPathByID(b *testing.B)    { benchCores(b, newSlowCore, benchPathByID) }
+
+func TestCachedCore(t *testing.T) {
+	fc := newFakeCore(t)
+	a := fc.mustCreate(t, "/notes/a.md")
+	fc.mustCreate(t, "/notes/b.md")
+	cc := NewCachedCore(fc)
+
+	check := func(when string) {
+		t.Helper()
+		for _, id := range []FileID{fc.root, a.ID, a.Parent} {
+			want, _ := fc.PathByID(id)
+			if got, err := cc.PathByID(id); err != nil || got != want {
+				t.Errorf("%s: PathByID = %q, %v; want %q", when, got, err, want)
+			}
+			wantCh, _ := fc.GetChildren(id)
+			if gotCh, _ := cc.GetChildren(id); len(gotCh) != len(wantCh) || (len(wantCh) > 0 && !reflect.DeepEqual(gotCh, wantCh)) {
+				t.Errorf("%s: GetChildren(%s) = %v, want %v", when, want, gotCh, wantCh)
+			}
+		}
+	}
+	check("loaded")
+
+	// Changes made through the cached core are applied to the cache.
+	c, err := cc.CreateFile("c.md", a.Parent, FileTypeDocument{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.RenameFile(a.ID, "renamed.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.MoveFile(c.ID, fc.root); err != nil {
+		t.Fatal(err)
+	}
+	check("after changes")
+	if err := cc.DeleteFile(a.Parent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cc.FileByID(a.ID); err == nil {
+		t.Error("FileByID of a deleted file: expected an error")
+	}
+
+	// Changes made to the wrapped core directly need an invalidation.
+	fc.mustCreate(t, "/outside.md")
+	if _, err := cc.FileByPath("/outside.md"); err != nil {
+		t.Errorf("FileByPath of a file only in the wrapped core: %v", err)
+	}
+	cc.Invalidate()
+	if ch, _ := cc.GetChildren(fc.root); len(ch) != 2 {
+		t.Errorf("after Invalidate, root has %d children, want 2", len(ch))
+	}
+}
+
+// workCore is a fake core with fixed sync work that counts how often its metadata is
+// listed.
+type workCore struct {
+	*fakeCore
+	work  WorkCalculated
+	loads int
+}
+
+func (c *workCore) CalculateWork() (WorkCalculated, error) { return c.work, nil }
+
+func (c *workCore) SyncAll(func(SyncProgress)) error { return nil }
+
+func (c *workCore) ListMetadatas() ([]File, error) {
+	c.loads++
+	return c.fakeCore.ListMetadatas()
+}
+
+func TestCachedCoreSync(t *testing.T) {
+	wc := &workCore{fakeCore: newFakeCore(t)}
+	a := wc.mustCreate(t, "/a.md")
+	cc := NewCachedCore(wc)
+	lookup := func() {
+		t.Helper()
+		if _, err := cc.FileByID(a.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lookup()
+
+	// Syncing nothing or only pushing keeps the cache.
+	for _, work := range [][]WorkUnit{nil, {{Type: WorkUnitTypeLocal, ID: a.ID}}} {
+		wc.work = WorkCalculated{WorkUnits: work}
+		if err := cc.SyncAll(nil); err != nil {
+			t.Fatal(err)
+		}
+		lookup()
+	}
+	if wc.loads != 1 {
+		t.Errorf("the metadata was listed %d times after syncs that didn't pull, want 1", wc.loads)
+	}
+
+	wc.work = WorkCalculated{WorkUnits: []WorkUnit{{Type: WorkUnitTypeServer, ID: a.ID}}}
+	if err := cc.SyncAll(nil); err != nil {
+		t.Fatal(err)
+	}
+	lookup()
+	if wc.loads != 2 {
+		t.Errorf("the metadata was listed %d times after a pull, want 2", wc.loads)
+	}
+}
@@ -0,0 +1,363 @@
+package lockbook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const conflictsFileName = "conflicts.json"
+
+// Conflict is a document that was changed both locally and on the server between syncs,
+// where the sync replaced (or merged into) the local content.
+type Conflict struct {
+	ID   FileID `json:"id"`
+	Path string `json:"path"`
+	// CopyID is the document holding the local content from before the sync.
+	CopyID   FileID    `json:"copy_id"`
+	CopyPath string    `json:"copy_path"`
+	Detected time.Time `json:"detected"`
+	// LocalHash is the hash of the local content from before the sync, and SyncedHash is
+	// the hash of the content after it.
+	LocalHash  string `json:"local_hash"`
+	SyncedHash string `json:"synced_hash"`
+	// LastmodBy is who made the change that the sync pulled.
+	LastmodBy string `json:"lastmod_by"`
+}
+
+// ConflictResolution is which version a conflict is resolved with.
+type ConflictResolution uint8
+
+const (
+	// KeepSynced keeps the synced document and deletes the conflict copy.
+	KeepSynced ConflictResolution = iota
+	// KeepLocal writes the conflict copy back to the document and deletes the copy.
+	KeepLocal
+	// KeepBoth keeps both documents as they are.
+	KeepBoth
+)
+
+// ConflictCore is a core that detects sync conflicts. Before each sync, it records the
+// content hash and last modified time of every document with local changes. After the
+// sync, any of those documents whose content changed were also changed on the server,
+// whether or not `CalculateWork` showed server work for them beforehand. The local
+// content from before the sync is saved as a copy named "name (conflict YYYY-MM-DD).md"
+// next to the document, and the conflict is kept (in the core's writeable path) until
+// it's resolved.
+//
+// A conflict where the local content won isn't detected, since the server's content is
+// never seen locally.
+type ConflictCore struct {
+	Core
+
+	fpath string
+	mu    sync.Mutex
+}
+
+// DetectConflicts returns a core that detects conflicts on each sync.
+func DetectConflicts(core Core) *ConflictCore {
+	return &ConflictCore{
+		Core:  core,
+		fpath: filepath.Join(core.WriteablePath(), conflictsFileName),
+	}
+}
+
+// Conflicts returns the unresolved conflicts, oldest first. Conflicts whose document or
+// copy has since been deleted are left out.
+func (c *ConflictCore) Conflicts() ([]Conflict, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conflicts, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	live := conflicts[:0]
+	for _, cf := range conflicts {
+		if !c.exists(cf.ID) || !c.exists(cf.CopyID) {
+			continue
+		}
+		if p, err := c.Core.PathByID(cf.ID); err == nil {
+			cf.Path = p
+		}
+		if p, err := c.Core.PathByID(cf.CopyID); err == nil {
+			cf.CopyPath = p
+		}
+		live = append(live, cf)
+	}
+	if len(live) != len(conflicts) {
+		if err := c.save(live); err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+func (c *ConflictCore) exists(id FileID) bool {
+	_, err := c.Core.FileByID(id)
+	return err == nil
+}
+
+func (c *ConflictCore) load() ([]Conflict, error) {
+	data, err := os.ReadFile(c.fpath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading conflicts: %w", err)
+	}
+	var conflicts []Conflict
+	if err := json.Unmarshal(data, &conflicts); err != nil {
+		return nil, fmt.Errorf("decoding conflicts: %w", err)
+	}
+	return conflicts, nil
+}
+
+func (c *ConflictCore) save(conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		if err := os.Remove(c.fpath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing conflicts: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.fpath+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("writing conflicts: %w", err)
+	}
+	return os.Rename(c.fpath+".tmp", c.fpath)
+}
+
+// Resolve resolves the conflict for the given document (or its conflict copy). The
+// resulting writes and deletes go through the given core, which should be the outermost
+// one (such as a history core) so that they're handled like any other change. A nil
+// core means this one.
+func (c *ConflictCore) Resolve(through Core, id FileID, res ConflictResolution) (Conflict, error) {
+	if through == nil {
+		through = c.Core
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conflicts, err := c.load()
+	if err != nil {
+		return Conflict{}, err
+	}
+	i := 0
+	for ; i < len(conflicts); i++ {
+		if conflicts[i].ID == id || conflicts[i].CopyID == id {
+			break
+		}
+	}
+	if i == len(conflicts) {
+		return Conflict{}, fmt.Errorf("no conflict for %q", id)
+	}
+	cf := conflicts[i]
+	switch res {
+	case KeepSynced:
+		if err := through.DeleteFile(cf.CopyID); err != nil {
+			return cf, fmt.Errorf("deleting conflict copy: %w", err)
+		}
+	case KeepLocal:
+		data, err := through.ReadDocument(cf.CopyID)
+		if err != nil {
+			return cf, fmt.Errorf("reading conflict copy: %w", err)
+		}
+		if err := through.WriteDocument(cf.ID, data); err != nil {
+			return cf, fmt.Errorf("writing %q: %w", cf.Path, err)
+		}
+		if err := through.DeleteFile(cf.CopyID); err != nil {
+			return cf, fmt.Errorf("deleting conflict copy: %w", err)
+		}
+	case KeepBoth:
+	default:
+		return cf, fmt.Errorf("unknown conflict resolution %d", res)
+	}
+	conflicts = append(conflicts[:i], conflicts[i+1:]...)
+	return cf, c.save(conflicts)
+}
+
+// localDoc is a document with local changes as it was right before a sync.
+type localDoc struct {
+	file File
+	data []byte
+	hash string
+	// bothChanged is whether the server also had changes for the document.
+	bothChanged bool
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SyncAll syncs and then saves a conflict copy of each document with local changes that
+// the sync changed. The conflicts found are returned by `Conflicts`.
+func (c *ConflictCore) SyncAll(fn func(SyncProgress)) error {
+	work, err := c.Core.CalculateWork()
+	if err != nil {
+		return fmt.Errorf("calculating work: %w", err)
+	}
+	return c.SyncWork(work, fn)
+}
+
+// SyncWork is `SyncAll` with the work already calculated.
+func (c *ConflictCore) SyncWork(work WorkCalculated, fn func(SyncProgress)) error {
+	before, err := c.snapshotLocal(work)
+	if err != nil {
+		return err
+	}
+	if err := SyncWithWork(c.Core, work, fn); err != nil {
+		return err
+	}
+	if _, err := c.detect(before); err != nil {
+		return fmt.Errorf("detecting conflicts: %w", err)
+	}
+	return nil
+}
+
+// snapshotLocal reads each document with local changes.
+func (c *ConflictCore) snapshotLocal(work WorkCalculated) ([]localDoc, error) {
+	onServer := make(map[FileID]bool)
+	for _, wu := range work.WorkUnits {
+		if wu.Type == WorkUnitTypeServer {
+			onServer[wu.ID] = true
+		}
+	}
+	var docs []localDoc
+	for _, wu := range work.WorkUnits {
+		if wu.Type != WorkUnitTypeLocal {
+			continue
+		}
+		f, err := c.Core.FileByID(wu.ID)
+		if err != nil {
+			// The local change may be the file's deletion.
+			continue
+		}
+		if _, isDoc := f.Type.(FileTypeDocument); !isDoc {
+			continue
+		}
+		data, err := c.Core.ReadDocument(f.ID)
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: %w", f.Name, err)
+		}
+		docs = append(docs, localDoc{
+			file:        f,
+			data:        data,
+			hash:        contentHash(data),
+			bothChanged: onServer[f.ID],
+		})
+	}
+	return docs, nil
+}
+
+// detect compares the documents from before a sync with their synced versions, saves a
+// conflict copy for each one that changed, and returns the new conflicts.
+func (c *ConflictCore) detect(before []localDoc) ([]Conflict, error) {
+	var found []Conflict
+	for _, d := range before {
+		f, err := c.Core.FileByID(d.file.ID)
+		if err != nil {
+			// It was deleted on the server.
+			continue
+		}
+		if f.Lastmod.Equal(d.file.Lastmod) && !d.bothChanged {
+			continue
+		}
+		data, err := c.Core.ReadDocument(f.ID)
+		if err != nil {
+			return found, fmt.Errorf("reading %q: %w", f.Name, err)
+		}
+		hash := contentHash(data)
+		if hash == d.hash {
+			continue
+		}
+		cf, err := c.saveCopy(f, d.data, d.hash, hash)
+		if err != nil {
+			return found, err
+		}
+		found = append(found, cf)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found, c.record(found...)
+}
+
+// SaveConflict saves local content that can't be written over a document's current
+// content as a conflict copy next to it, and records the conflict.
+func (c *ConflictCore) SaveConflict(f File, local []byte) (Conflict, error) {
+	data, err := c.Core.ReadDocument(f.ID)
+	if err != nil {
+		return Conflict{}, fmt.Errorf("reading %q: %w", f.Name, err)
+	}
+	cf, err := c.saveCopy(f, local, contentHash(local), contentHash(data))
+	if err != nil {
+		return cf, err
+	}
+	return cf, c.record(cf)
+}
+
+// saveCopy saves a conflict copy of a document with the given local content and returns
+// the (not yet recorded) conflict.
+func (c *ConflictCore) saveCopy(f File, local []byte, localHash, syncedHash string) (Conflict, error) {
+	now := time.Now()
+	cp, err := saveConflictCopy(c.Core, f, local, now)
+	if err != nil {
+		return Conflict{}, err
+	}
+	cf := Conflict{
+		ID:         f.ID,
+		CopyID:     cp.ID,
+		Detected:   now,
+		LocalHash:  localHash,
+		SyncedHash: syncedHash,
+		LastmodBy:  f.LastmodBy,
+	}
+	cf.Path, _ = c.Core.PathByID(f.ID)
+	cf.CopyPath, _ = c.Core.PathByID(cp.ID)
+	return cf, nil
+}
+
+func (c *ConflictCore) record(found ...Conflict) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conflicts, err := c.load()
+	if err != nil {
+		return err
+	}
+	return c.save(append(conflicts, found...))
+}
+
+// saveConflictCopy creates a copy of a document with the given content, named after the
+// document and date, in the same folder.
+func saveConflictCopy(core Core, f File, data []byte, now time.Time) (File, error) {
+	ext := filepath.Ext(f.Name)
+	base := strings.TrimSuffix(f.Name, ext)
+	date := now.Format("2006-01-02")
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s (conflict %s)%s", base, date, ext)
+		if n > 1 {
+			name = fmt.Sprintf("%s (conflict %s %d)%s", base, date, n, ext)
+		}
+		cp, err := core.CreateFile(name, f.Parent, FileTypeDocument{})
+		if err != nil {
+			var lbErr *Error
+			if errors.As(err, &lbErr) && lbErr.Code == CodePathTaken {
+				continue
+			}
+			return File{}, fmt.Errorf("creating conflict copy of %q: %w", f.Name, err)
+		}
+		if err := core.WriteDocument(cp.ID, data); err != nil {
+			return File{}, fmt.Errorf("writing conflict copy of %q: %w", f.Name, err)
+		}
+		return cp, nil
+	}
+}
@@ -0,0 +1,152 @@
+// Package drawing parses lockbook drawings and renders them without going through
+// lockbook-core, both to raster images and to SVG.
+package drawing
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Drawing is the content of a lockbook drawing document (as returned by
+// `ReadDocument`).
+type Drawing struct {
+	// Scale and translation are the app's view of the drawing when it was last edited.
+	// They don't affect rendering.
+	Scale        float32                 `json:"scale"`
+	TranslationX float32                 `json:"translation_x"`
+	TranslationY float32                 `json:"translation_y"`
+	Strokes      []Stroke                `json:"strokes"`
+	Theme        map[ColorAlias]ColorRGB `json:"theme,omitempty"`
+}
+
+// Stroke is a single continuous line. Each point has a girth (the width of the line at
+// that point) which comes from pen pressure.
+type Stroke struct {
+	PointsX     []float32  `json:"points_x"`
+	PointsY     []float32  `json:"points_y"`
+	PointsGirth []float32  `json:"points_girth"`
+	Color       ColorAlias `json:"color"`
+	Alpha       float32    `json:"alpha"`
+}
+
+type ColorAlias string
+
+const (
+	Black   ColorAlias = "Black"
+	Red     ColorAlias = "Red"
+	Green   ColorAlias = "Green"
+	Yellow  ColorAlias = "Yellow"
+	Blue    ColorAlias = "Blue"
+	Magenta ColorAlias = "Magenta"
+	Cyan    ColorAlias = "Cyan"
+	White   ColorAlias = "White"
+)
+
+type ColorRGB struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+// DefaultTheme is the color for each alias when a drawing doesn't have its own theme.
+var DefaultTheme = map[ColorAlias]ColorRGB{
+	Black:   {0x00, 0x00, 0x00},
+	Red:     {0xFF, 0x00, 0x00},
+	Green:   {0x00, 0xFF, 0x00},
+	Yellow:  {0xFF, 0xFF, 0x00},
+	Blue:    {0x00, 0x00, 0xFF},
+	Magenta: {0xFF, 0x00, 0xFF},
+	Cyan:    {0x00, 0xFF, 0xFF},
+	White:   {0xFF, 0xFF, 0xFF},
+}
+
+// Parse decodes a drawing document. Empty data (a brand new drawing) results in an
+// empty drawing.
+func Parse(data []byte) (*Drawing, error) {
+	d := &Drawing{Scale: 1}
+	if len(data) == 0 {
+		return d, nil
+	}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("decoding drawing: %w", err)
+	}
+	for i := range d.Strokes {
+		s := &d.Strokes[i]
+		if len(s.PointsX) != len(s.PointsY) || len(s.PointsX) != len(s.PointsGirth) {
+			return nil, fmt.Errorf("stroke %d: mismatched point data (%d x, %d y, %d girth)",
+				i, len(s.PointsX), len(s.PointsY), len(s.PointsGirth))
+		}
+	}
+	return d, nil
+}
+
+// Color returns the RGBA color of the given stroke using the drawing's theme, falling
+// back to the default theme.
+func (d *Drawing) Color(s *Stroke) color.NRGBA {
+	c, ok := d.Theme[s.Color]
+	if !ok {
+		c = DefaultTheme[s.Color]
+	}
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: alphaByte(s.Alpha)}
+}
+
+func alphaByte(a float32) uint8 {
+	switch {
+	case a <= 0:
+		return 0
+	case a >= 1:
+		return 255
+	default:
+		return uint8(a*255 + 0.5)
+	}
+}
+
+// Rect is an axis aligned rectangle in drawing coordinates.
+type Rect struct {
+	MinX, MinY float32
+	MaxX, MaxY float32
+}
+
+func (r Rect) Dx() float32    { return r.MaxX - r.MinX }
+func (r Rect) Dy() float32    { return r.MaxY - r.MinY }
+func (r Rect) Empty() bool    { return r.MinX >= r.MaxX || r.MinY >= r.MaxY }
+func (r Rect) String() string { return fmt.Sprintf("(%g,%g)-(%g,%g)", r.MinX, r.MinY, r.MaxX, r.MaxY) }
+
+// Bounds returns the smallest rectangle containing every stroke, including the girth of
+// each point. It's empty if there are no points.
+func (d *Drawing) Bounds() Rect {
+	r := Rect{
+		MinX: math.MaxFloat32, MinY: math.MaxFloat32,
+		MaxX: -math.MaxFloat32, MaxY: -math.MaxFloat32,
+	}
+	for i := range d.Strokes {
+		s := &d.Strokes[i]
+		for j := range s.PointsX {
+			half := s.PointsGirth[j] / 2
+			r.MinX = min32(r.MinX, s.PointsX[j]-half)
+			r.MinY = min32(r.MinY, s.PointsY[j]-half)
+			r.MaxX = max32(r.MaxX, s.PointsX[j]+half)
+			r.MaxY = max32(r.MaxY, s.PointsY[j]+half)
+		}
+	}
+	if r.Empty() {
+		return Rect{}
+	}
+	return r
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+package drawing
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// sampleDrawing is a drawing document with a red line (whose color the drawing's theme
+// overrides) and a single translucent blue dot.
+const sampleDrawing = `{
+  "scale": 1.5,
+  "translation_x": -100,
+  "translation_y": 40,
+  "strokes": [
+    {"points_x": [10, 30], "points_y": [20, 20], "points_girth": [4, 4], "color": "Red", "alpha": 1},
+    {"points_x": [50], "points_y": [40], "points_girth": [2], "color": "Blue", "alpha": 0.5}
+  ],
+  "theme": {"Red": {"r": 18, "g": 52, "b": 86}}
+}`
+
+func parseSample(t *testing.T) *Drawing {
+	t.Helper()
+	d, err := Parse([]byte(sampleDrawing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	d := parseSample(t)
+	if len(d.Strokes) != 2 || d.Scale != 1.5 || d.TranslationX != -100 {
+		t.Fatalf("unexpected drawing %+v", d)
+	}
+	if got, want := d.Color(&d.Strokes[0]), (color.NRGBA{0x12, 0x34, 0x56, 0xFF}); got != want {
+		t.Errorf("themed color = %v, want %v", got, want)
+	}
+	if got, want := d.Color(&d.Strokes[1]), (color.NRGBA{0, 0, 0xFF, 0x80}); got != want {
+		t.Errorf("default color = %v, want %v", got, want)
+	}
+	if got, want := d.Bounds(), (Rect{MinX: 8, MinY: 18, MaxX: 51, MaxY: 41}); got != want {
+		t.Errorf("Bounds() = %v, want %v", got, want)
+	}
+
+	empty, err := Parse(nil)
+	if err != nil || len(empty.Strokes) != 0 || empty.Scale != 1 || !empty.Bounds().Empty() {
+		t.Errorf("Parse(nil) = %+v, %v", empty, err)
+	}
+	for _, data := range []string{
+		`{"strokes": [{"points_x": [1, 2], "points_y": [1], "points_girth": [1, 1]}]}`,
+		`{"strokes": 1}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s): expected an error", data)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	d := parseSample(t)
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, d, Options{Scale: 2, Padding: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The bounds (43x23) are scaled by 2 and padded by 1 on each side, and the points are
+	// moved so that the bounds start at the padding.
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="88" height="48" viewBox="0 0 88 48">
+<g stroke="#123456" fill="#123456" opacity="1.00" stroke-linecap="round">
+<line x1="5.00" y1="5.00" x2="45.00" y2="5.00" stroke-width="8.00"/>
+</g>
+<g stroke="#0000ff" fill="#0000ff" opacity="0.50" stroke-linecap="round">
+<circle cx="85.00" cy="45.00" r="2.00"/>
+</g>
+</svg>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteSVG wrote:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteSVG(&buf, d, Options{Region: Rect{MaxX: 10, MaxY: 10}, Background: color.White}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`viewBox="0 0 10 10"`,
+		`<rect width="100%" height="100%" fill="#ffffff" fill-opacity="1.00"/>`,
+		`<line x1="10.00" y1="20.00" x2="30.00" y2="20.00" stroke-width="4.00"/>`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("SVG of a region doesn't contain %s:\n%s", s, buf.Bytes())
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := parseSample(t)
+	img := Render(d, Options{Scale: 2, Padding: 1})
+	if got, want := img.Bounds(), image.Rect(0, 0, 88, 48); got != want {
+		t.Fatalf("Render bounds = %v, want %v", got, want)
+	}
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		// The middle of the line and one of its round caps.
+		{25, 5, color.RGBA{0x12, 0x34, 0x56, 0xFF}},
+		{2, 5, color.RGBA{0x12, 0x34, 0x56, 0xFF}},
+		// Off the ends of the line and between the strokes.
+		{49, 5, color.RGBA{}},
+		{25, 20, color.RGBA{}},
+		// The translucent dot (premultiplied).
+		{85, 45, color.RGBA{0, 0, 0x80, 0x80}},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel (%d, %d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	img = Render(d, Options{Region: Rect{MaxX: 10, MaxY: 10}, Background: color.White})
+	if got, want := img.Bounds(), image.Rect(0, 0, 10, 10); got != want {
+		t.Errorf("bounds of a rendered region = %v, want %v", got, want)
+	}
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("background pixel = %v, want white", got)
+	}
+
+	if got := Render(&Drawing{}, Options{}).Bounds(); got != image.Rect(0, 0, 1, 1) {
+		t.Errorf("bounds of an empty drawing = %v, want 1x1", got)
+	}
+}
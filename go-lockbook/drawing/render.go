@@ -0,0 +1,130 @@
+package drawing
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Options control how a drawing is rendered.
+type Options struct {
+	// Scale multiplies the drawing's coordinates. Zero means 1.
+	Scale float32
+	// Region is the part of the drawing to render. If it's empty, the drawing's bounds
+	// are used (which crops away any empty space).
+	Region Rect
+	// Padding is added around the region (in output pixels).
+	Padding float32
+	// Background fills the output before the strokes are drawn. If it's nil, the
+	// background is transparent.
+	Background color.Color
+}
+
+func (o *Options) scale() float32 {
+	if o.Scale <= 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+func (o *Options) region(d *Drawing) Rect {
+	if o.Region.Empty() {
+		return d.Bounds()
+	}
+	return o.Region
+}
+
+// transform maps a point in drawing coordinates to output coordinates.
+type transform struct {
+	minX, minY float32
+	scale      float32
+	padding    float32
+}
+
+func (o *Options) transform(d *Drawing) (transform, image.Point) {
+	r := o.region(d)
+	t := transform{minX: r.MinX, minY: r.MinY, scale: o.scale(), padding: o.Padding}
+	size := image.Pt(
+		int(math.Ceil(float64(r.Dx()*t.scale+2*o.Padding))),
+		int(math.Ceil(float64(r.Dy()*t.scale+2*o.Padding))),
+	)
+	if size.X < 1 {
+		size.X = 1
+	}
+	if size.Y < 1 {
+		size.Y = 1
+	}
+	return t, size
+}
+
+func (t transform) apply(x, y float32) (float32, float32) {
+	return (x-t.minX)*t.scale + t.padding, (y-t.minY)*t.scale + t.padding
+}
+
+// Render rasterizes the drawing. Each stroke is drawn as a series of round capped
+// segments whose width follows the girth of the points.
+func Render(d *Drawing, opts Options) *image.RGBA {
+	t, size := opts.transform(d)
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	if opts.Background != nil {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	z := vector.NewRasterizer(size.X, size.Y)
+	for i := range d.Strokes {
+		s := &d.Strokes[i]
+		if len(s.PointsX) == 0 {
+			continue
+		}
+		z.Reset(size.X, size.Y)
+		// All of a stroke's shapes go into one rasterizer pass so overlapping parts of
+		// a translucent stroke don't get darker.
+		for j := range s.PointsX {
+			x, y := t.apply(s.PointsX[j], s.PointsY[j])
+			r := s.PointsGirth[j] * t.scale / 2
+			addCircle(z, x, y, r)
+			if j+1 < len(s.PointsX) {
+				nx, ny := t.apply(s.PointsX[j+1], s.PointsY[j+1])
+				addSegment(z, x, y, nx, ny, r)
+			}
+		}
+		z.Draw(dst, dst.Bounds(), image.NewUniform(d.Color(s)), image.Point{})
+	}
+	return dst
+}
+
+// addSegment adds a rectangle of the given half width from (x0, y0) to (x1, y1). It has
+// the same winding as the circles from `addCircle` so that they union together.
+func addSegment(z *vector.Rasterizer, x0, y0, x1, y1, halfWidth float32) {
+	dx, dy := x1-x0, y1-y0
+	l := float32(math.Hypot(float64(dx), float64(dy)))
+	if l == 0 {
+		return
+	}
+	nx, ny := -dy/l*halfWidth, dx/l*halfWidth
+	z.MoveTo(x0+nx, y0+ny)
+	z.LineTo(x1+nx, y1+ny)
+	z.LineTo(x1-nx, y1-ny)
+	z.LineTo(x0-nx, y0-ny)
+	z.ClosePath()
+}
+
+func addCircle(z *vector.Rasterizer, cx, cy, r float32) {
+	if r <= 0 {
+		return
+	}
+	n := int(r * 2)
+	if n < 8 {
+		n = 8
+	} else if n > 64 {
+		n = 64
+	}
+	z.MoveTo(cx+r, cy)
+	for i := 1; i < n; i++ {
+		a := -2 * math.Pi * float64(i) / float64(n)
+		z.LineTo(cx+r*float32(math.Cos(a)), cy+r*float32(math.Sin(a)))
+	}
+	z.ClosePath()
+}
@@ -0,0 +1,54 @@
+package drawing
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// WriteSVG writes the drawing as an SVG document using the same options as `Render`.
+func WriteSVG(w io.Writer, d *Drawing, opts Options) error {
+	t, size := opts.transform(d)
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size.X, size.Y, size.X, size.Y)
+	if opts.Background != nil {
+		c := color.NRGBAModel.Convert(opts.Background).(color.NRGBA)
+		fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s" fill-opacity="%s"/>`+"\n",
+			svgColor(c), svgNum(float32(c.A)/255))
+	}
+	for i := range d.Strokes {
+		s := &d.Strokes[i]
+		if len(s.PointsX) == 0 {
+			continue
+		}
+		c := d.Color(s)
+		// The opacity is on the group so that overlapping segments don't get darker.
+		fmt.Fprintf(bw, `<g stroke="%s" fill="%s" opacity="%s" stroke-linecap="round">`+"\n",
+			svgColor(c), svgColor(c), svgNum(float32(c.A)/255))
+		if len(s.PointsX) == 1 {
+			x, y := t.apply(s.PointsX[0], s.PointsY[0])
+			fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s"/>`+"\n",
+				svgNum(x), svgNum(y), svgNum(s.PointsGirth[0]*t.scale/2))
+		}
+		for j := 0; j+1 < len(s.PointsX); j++ {
+			x0, y0 := t.apply(s.PointsX[j], s.PointsY[j])
+			x1, y1 := t.apply(s.PointsX[j+1], s.PointsY[j+1])
+			fmt.Fprintf(bw, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke-width="%s"/>`+"\n",
+				svgNum(x0), svgNum(y0), svgNum(x1), svgNum(y1), svgNum(s.PointsGirth[j]*t.scale))
+		}
+		bw.WriteString("</g>\n")
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgNum(f float32) string {
+	return fmt.Sprintf("%.2f", f)
+}
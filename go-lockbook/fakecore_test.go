@@ -0,0 +1,229 @@
+package lockbook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeCore is an in-memory core for tests. It only implements the file methods, so
+// calling any other method panics.
+type fakeCore struct {
+	Core
+
+	dir   string
+	root  FileID
+	files map[FileID]*File
+	docs  map[FileID][]byte
+}
+
+func newFakeCore(t testing.TB) *fakeCore {
+	id := uuid.Must(uuid.NewV4())
+	c := &fakeCore{
+		dir:   t.TempDir(),
+		root:  id,
+		files: make(map[FileID]*File),
+		docs:  make(map[FileID][]byte),
+	}
+	c.files[id] = &File{ID: id, Parent: id, Name: "me", Type: FileTypeFolder{}}
+	return c
+}
+
+// mustCreate creates the file at the given path (and any missing folders along the way)
+// or fails the test.
+func (c *fakeCore) mustCreate(t testing.TB, p string) File {
+	t.Helper()
+	f, err := c.CreateFileAtPath(p)
+	if err != nil {
+		t.Fatalf("creating %q: %v", p, err)
+	}
+	return f
+}
+
+func fakeErr(code ErrorCode, msg string) error {
+	return &Error{Code: code, Msg: msg}
+}
+
+func (c *fakeCore) WriteablePath() string {
+	return c.dir
+}
+
+func (c *fakeCore) GetRoot() (File, error) {
+	return *c.files[c.root], nil
+}
+
+func (c *fakeCore) FileByID(id FileID) (File, error) {
+	f, ok := c.files[id]
+	if !ok {
+		return File{}, fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	return *f, nil
+}
+
+func (c *fakeCore) FileByPath(lbPath string) (File, error) {
+	f := c.files[c.root]
+	for _, name := range strings.Split(strings.Trim(lbPath, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		ch, ok := c.child(f.ID, name)
+		if !ok {
+			return File{}, fakeErr(CodeFileNonexistent, "file does not exist")
+		}
+		f = ch
+	}
+	return *f, nil
+}
+
+func (c *fakeCore) child(parent FileID, name string) (*File, bool) {
+	for _, f := range c.files {
+		if f.Parent == parent && f.ID != parent && f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func (c *fakeCore) GetChildren(id FileID) ([]File, error) {
+	var files []File
+	for _, f := range c.files {
+		if f.Parent == id && f.ID != id {
+			files = append(files, *f)
+		}
+	}
+	SortFiles(files)
+	return files, nil
+}
+
+func (c *fakeCore) GetAndGetChildrenRecursively(id FileID) ([]File, error) {
+	f, ok := c.files[id]
+	if !ok {
+		return nil, fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	files := []File{*f}
+	children, _ := c.GetChildren(id)
+	for _, ch := range children {
+		sub, _ := c.GetAndGetChildrenRecursively(ch.ID)
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+func (c *fakeCore) ListMetadatas() ([]File, error) {
+	files := make([]File, 0, len(c.files))
+	for _, f := range c.files {
+		files = append(files, *f)
+	}
+	return files, nil
+}
+
+func (c *fakeCore) PathByID(id FileID) (string, error) {
+	f, ok := c.files[id]
+	if !ok {
+		return "", fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	if f.IsRoot() {
+		return "/", nil
+	}
+	p, _ := c.PathByID(f.Parent)
+	p += f.Name
+	if f.IsDir() {
+		p += "/"
+	}
+	return p, nil
+}
+
+func (c *fakeCore) ReadDocument(id FileID) ([]byte, error) {
+	if _, ok := c.files[id]; !ok {
+		return nil, fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	return c.docs[id], nil
+}
+
+func (c *fakeCore) WriteDocument(id FileID, data []byte) error {
+	f, ok := c.files[id]
+	if !ok {
+		return fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	c.docs[id] = append([]byte(nil), data...)
+	f.Lastmod = time.Now()
+	return nil
+}
+
+func (c *fakeCore) CreateFile(name string, parentID FileID, typ FileType) (File, error) {
+	if _, taken := c.child(parentID, name); taken {
+		return File{}, fakeErr(CodePathTaken, "path taken")
+	}
+	f := &File{
+		ID:      uuid.Must(uuid.NewV4()),
+		Parent:  parentID,
+		Name:    name,
+		Type:    typ,
+		Lastmod: time.Now(),
+	}
+	c.files[f.ID] = f
+	return *f, nil
+}
+
+func (c *fakeCore) CreateFileAtPath(lbPath string) (File, error) {
+	names := strings.Split(strings.Trim(lbPath, "/"), "/")
+	parent := c.root
+	for i, name := range names {
+		last := i == len(names)-1
+		if f, ok := c.child(parent, name); ok {
+			if last {
+				return File{}, fakeErr(CodePathTaken, "path taken")
+			}
+			parent = f.ID
+			continue
+		}
+		var typ FileType = FileTypeFolder{}
+		if last && !strings.HasSuffix(lbPath, "/") {
+			typ = FileTypeDocument{}
+		}
+		f, err := c.CreateFile(name, parent, typ)
+		if err != nil || last {
+			return f, err
+		}
+		parent = f.ID
+	}
+	return File{}, fakeErr(CodePathTaken, "path taken")
+}
+
+func (c *fakeCore) DeleteFile(id FileID) error {
+	files, err := c.GetAndGetChildrenRecursively(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		delete(c.files, f.ID)
+		delete(c.docs, f.ID)
+	}
+	return nil
+}
+
+func (c *fakeCore) RenameFile(id FileID, newName string) error {
+	f, ok := c.files[id]
+	if !ok {
+		return fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	if _, taken := c.child(f.Parent, newName); taken {
+		return fakeErr(CodePathTaken, "path taken")
+	}
+	f.Name = newName
+	return nil
+}
+
+func (c *fakeCore) MoveFile(srcID, destID FileID) error {
+	f, ok := c.files[srcID]
+	if !ok {
+		return fakeErr(CodeFileNonexistent, "file does not exist")
+	}
+	if _, taken := c.child(destID, f.Name); taken {
+		return fakeErr(CodePathTaken, "path taken")
+	}
+	f.Parent = destID
+	return nil
+}
@@ -0,0 +1,272 @@
+package lockbook
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GlobMatch is a file matched by `Glob` along with its path.
+type GlobMatch struct {
+	Path string
+	File File
+}
+
+// HasGlobMeta reports whether the string contains any of the special characters
+// recognized by `Glob`.
+func HasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// Glob returns the files whose paths match the pattern, sorted by path. The pattern is
+// always relative to root (a leading slash is optional). Within a path segment, `*`, `?`
+// and `[...]` behave as in `path.Match`. A `**` segment matches any number of folders
+// (including none), and brace sets such as `{a,b}` expand into alternatives that may
+// contain slashes. A trailing slash only matches folders. Links aren't followed.
+func Glob(core Core, pattern string) ([]GlobMatch, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	root, err := core.GetRoot()
+	if err != nil {
+		return nil, fmt.Errorf("getting root: %w", err)
+	}
+	g := globber{
+		core:     core,
+		children: make(map[FileID][]File),
+		matches:  make(map[FileID]GlobMatch),
+	}
+	for _, p := range patterns {
+		onlyDirs := strings.HasSuffix(p, "/")
+		segs := strings.Split(strings.Trim(p, "/"), "/")
+		for _, seg := range segs {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+		if len(segs) == 1 && segs[0] == "" {
+			g.matches[root.ID] = GlobMatch{Path: "/", File: root}
+			continue
+		}
+		if err := g.match(root, "/", segs, onlyDirs); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := make([]GlobMatch, 0, len(g.matches))
+	for _, m := range g.matches {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+	return matches, nil
+}
+
+// MatchPath reports whether a path matches the pattern, using the same rules as `Glob`.
+// Folder paths must end with a slash for a pattern with a trailing slash to match them.
+func MatchPath(pattern, p string) (bool, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return false, err
+	}
+	isDir := strings.HasSuffix(p, "/")
+	names := strings.Split(strings.Trim(p, "/"), "/")
+	for _, pat := range patterns {
+		if strings.HasSuffix(pat, "/") && !isDir {
+			continue
+		}
+		segs := strings.Split(strings.Trim(pat, "/"), "/")
+		ok, err := matchSegs(segs, names)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchSegs matches pattern segments against path names like `globber.match` does.
+func matchSegs(segs, names []string) (bool, error) {
+	if len(segs) == 0 {
+		return len(names) == 0, nil
+	}
+	seg, rest := segs[0], segs[1:]
+	if seg == "**" {
+		for i := 0; i <= len(names); i++ {
+			if ok, err := matchSegs(rest, names[i:]); ok || err != nil {
+				return ok, err
+			}
+			if i < len(names) && strings.HasPrefix(names[i], ".") {
+				break
+			}
+		}
+		return false, nil
+	}
+	if len(names) == 0 {
+		return false, nil
+	}
+	ok, err := path.Match(seg, names[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	if strings.HasPrefix(names[0], ".") && !strings.HasPrefix(seg, ".") {
+		return false, nil
+	}
+	return matchSegs(rest, names[1:])
+}
+
+type globber struct {
+	core     Core
+	children map[FileID][]File
+	matches  map[FileID]GlobMatch
+}
+
+func (g *globber) getChildren(id FileID) ([]File, error) {
+	if files, ok := g.children[id]; ok {
+		return files, nil
+	}
+	files, err := g.core.GetChildren(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting children of %q: %w", id, err)
+	}
+	g.children[id] = files
+	return files, nil
+}
+
+// match finds the files within `dir` (at `dirPath`) matching the remaining segments.
+func (g *globber) match(dir File, dirPath string, segs []string, onlyDirs bool) error {
+	if len(segs) == 0 {
+		return nil
+	}
+	seg, rest := segs[0], segs[1:]
+	if seg == "**" {
+		// Zero folders, then one or more folders.
+		if len(rest) == 0 {
+			return g.matchAllBelow(dir, dirPath, onlyDirs)
+		}
+		if err := g.match(dir, dirPath, rest, onlyDirs); err != nil {
+			return err
+		}
+		children, err := g.getChildren(dir.ID)
+		if err != nil {
+			return err
+		}
+		for _, ch := range children {
+			if ch.IsDir() && !strings.HasPrefix(ch.Name, ".") {
+				if err := g.match(ch, dirPath+ch.Name+"/", segs, onlyDirs); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	children, err := g.getChildren(dir.ID)
+	if err != nil {
+		return err
+	}
+	for _, ch := range children {
+		if ok, _ := path.Match(seg, ch.Name); !ok {
+			continue
+		}
+		// Like in shells, wildcards don't match hidden files unless the segment is
+		// explicitly hidden too.
+		if strings.HasPrefix(ch.Name, ".") && !strings.HasPrefix(seg, ".") {
+			continue
+		}
+		chPath := dirPath + ch.Name
+		if len(rest) == 0 {
+			if !onlyDirs || ch.IsDir() {
+				g.add(ch, chPath)
+			}
+			continue
+		}
+		if ch.IsDir() {
+			if err := g.match(ch, chPath+"/", rest, onlyDirs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchAllBelow adds every (non-hidden) file within `dir` recursively.
+func (g *globber) matchAllBelow(dir File, dirPath string, onlyDirs bool) error {
+	children, err := g.getChildren(dir.ID)
+	if err != nil {
+		return err
+	}
+	for _, ch := range children {
+		if strings.HasPrefix(ch.Name, ".") {
+			continue
+		}
+		chPath := dirPath + ch.Name
+		if !ch.IsDir() {
+			if !onlyDirs {
+				g.add(ch, chPath)
+			}
+			continue
+		}
+		g.add(ch, chPath+"/")
+		if err := g.matchAllBelow(ch, chPath+"/", onlyDirs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *globber) add(f File, p string) {
+	if f.IsDir() && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	g.matches[f.ID] = GlobMatch{Path: p, File: f}
+}
+
+// expandBraces expands every (possibly nested) brace set in the pattern, such as
+// "a/{b,c{d,e}}" into "a/b", "a/cd" and "a/ce".
+func expandBraces(pattern string) ([]string, error) {
+	start := strings.IndexByte(pattern, '{')
+	if start == -1 {
+		if strings.IndexByte(pattern, '}') != -1 {
+			return nil, fmt.Errorf("invalid pattern %q: unmatched '}'", pattern)
+		}
+		return []string{pattern}, nil
+	}
+	// Find the matching closing brace and the top level commas in between.
+	depth := 0
+	end := -1
+	commas := []int{}
+	for i := start; i < len(pattern) && end == -1; i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	if end == -1 {
+		return nil, fmt.Errorf("invalid pattern %q: unmatched '{'", pattern)
+	}
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	bounds := append(append([]int{start}, commas...), end)
+	var out []string
+	for i := 0; i < len(bounds)-1; i++ {
+		alt := pattern[bounds[i]+1 : bounds[i+1]]
+		expanded, err := expandBraces(prefix + alt + suffix)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package lockbook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	core := newFakeCore(t)
+	for _, p := range []string{
+		"/notes/a.md",
+		"/notes/b.md",
+		"/notes/c.txt",
+		"/notes/.hidden.md",
+		"/notes/old/d.md",
+		"/notes/old/deeper/e.md",
+		"/notes/.trash/f.md",
+		"/work/a.md",
+		"/work/plans/",
+		"/x{y}.md",
+	} {
+		core.mustCreate(t, p)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"notes/*.md", []string{"/notes/a.md", "/notes/b.md"}},
+		{"/notes/*.md", []string{"/notes/a.md", "/notes/b.md"}},
+		{"notes/?.txt", []string{"/notes/c.txt"}},
+		{"notes/[ab].md", []string{"/notes/a.md", "/notes/b.md"}},
+		{"notes/.*.md", []string{"/notes/.hidden.md"}},
+		{"*/a.md", []string{"/notes/a.md", "/work/a.md"}},
+		{"missing/*", []string{}},
+		// A `**` segment matches zero or more (non-hidden) folders.
+		{"notes/**/*.md", []string{
+			"/notes/a.md", "/notes/b.md", "/notes/old/d.md", "/notes/old/deeper/e.md",
+		}},
+		{"**/e.md", []string{"/notes/old/deeper/e.md"}},
+		{"notes/old/**", []string{"/notes/old/d.md", "/notes/old/deeper/", "/notes/old/deeper/e.md"}},
+		{"notes/old/**/", []string{"/notes/old/deeper/"}},
+		// Braces expand into alternatives, which may contain slashes.
+		{"{notes,work}/a.md", []string{"/notes/a.md", "/work/a.md"}},
+		{"notes/{a,c}.*", []string{"/notes/a.md", "/notes/c.txt"}},
+		{"{notes/old,work}/*.md", []string{"/notes/old/d.md", "/work/a.md"}},
+		{"notes/{o{ld,ther}}/d.md", []string{"/notes/old/d.md"}},
+		// A trailing slash only matches folders.
+		{"*/", []string{"/notes/", "/work/"}},
+		{"work/*/", []string{"/work/plans/"}},
+		{"work/*", []string{"/work/a.md", "/work/plans/"}},
+		{"notes/a.md/", []string{}},
+		{"/", []string{"/"}},
+	}
+	for _, tt := range tests {
+		matches, err := Glob(core, tt.pattern)
+		if err != nil {
+			t.Errorf("Glob(%q): %v", tt.pattern, err)
+			continue
+		}
+		got := []string{}
+		for _, m := range matches {
+			got = append(got, m.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Glob(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestGlobInvalid(t *testing.T) {
+	core := newFakeCore(t)
+	for _, pattern := range []string{"notes/[a", "{a,b", "a}", "{a,[b}"} {
+		if _, err := Glob(core, pattern); err == nil {
+			t.Errorf("Glob(%q): expected an error", pattern)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"a", []string{"a"}},
+		{"{a,b}", []string{"a", "b"}},
+		{"x/{a,b}/y", []string{"x/a/y", "x/b/y"}},
+		{"{a,b}{c,d}", []string{"ac", "ad", "bc", "bd"}},
+		{"a/{b,c{d,e}}", []string{"a/b", "a/cd", "a/ce"}},
+		{"{a,}.md", []string{"a.md", ".md"}},
+	}
+	for _, tt := range tests {
+		got, err := expandBraces(tt.pattern)
+		if err != nil {
+			t.Errorf("expandBraces(%q): %v", tt.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
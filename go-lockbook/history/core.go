@@ -0,0 +1,89 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// Core is a lockbook core that records the prior content of documents in a history store
+// before they're overwritten by a write or a sync.
+type Core struct {
+	lockbook.Core
+	Store *Store
+}
+
+// Wrap returns a core that records history in the given store.
+func Wrap(core lockbook.Core, s *Store) *Core {
+	return &Core{Core: core, Store: s}
+}
+
+// record saves the current content of a document (if any) as a revision.
+func (c *Core) record(id lockbook.FileID, reason Reason) error {
+	f, err := c.Core.FileByID(id)
+	if err != nil {
+		return fmt.Errorf("file by id %q: %w", id, err)
+	}
+	if _, ok := f.Type.(lockbook.FileTypeDocument); !ok {
+		return nil
+	}
+	data, err := c.Core.ReadDocument(id)
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", id, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, _, err := c.Store.Record(&f, data, reason); err != nil {
+		return fmt.Errorf("recording revision of %q: %w", id, err)
+	}
+	return nil
+}
+
+func (c *Core) WriteDocument(id lockbook.FileID, data []byte) error {
+	if err := c.record(id, ReasonWrite); err != nil {
+		return err
+	}
+	return c.Core.WriteDocument(id, data)
+}
+
+// SyncAll records each document that the server has changes for before syncing.
+func (c *Core) SyncAll(fn func(lockbook.SyncProgress)) error {
+	work, err := c.Core.CalculateWork()
+	if err != nil {
+		return fmt.Errorf("calculating work: %w", err)
+	}
+	return c.SyncWork(work, fn)
+}
+
+// SyncWork is `SyncAll` with the work already calculated.
+func (c *Core) SyncWork(work lockbook.WorkCalculated, fn func(lockbook.SyncProgress)) error {
+	for _, wu := range work.WorkUnits {
+		if wu.Type != lockbook.WorkUnitTypeServer {
+			continue
+		}
+		if err := c.record(wu.ID, ReasonSync); err != nil {
+			// A new file from the server doesn't exist locally yet.
+			var lbErr *lockbook.Error
+			if errors.As(err, &lbErr) && lbErr.Code == lockbook.CodeFileNonexistent {
+				continue
+			}
+			return err
+		}
+	}
+	return lockbook.SyncWithWork(c.Core, work, fn)
+}
+
+// Restore writes the content of a revision back to its document. The content being
+// replaced is recorded first, so a restore can itself be undone.
+func (c *Core) Restore(id lockbook.FileID, n int) error {
+	data, err := c.Store.Read(id, n)
+	if err != nil {
+		return err
+	}
+	if err := c.record(id, ReasonRestore); err != nil {
+		return err
+	}
+	return c.Core.WriteDocument(id, data)
+}
@@ -0,0 +1,241 @@
+// Package history keeps prior revisions of documents locally. Lockbook overwrites a
+// document on every write, so the revisions are captured right before a document is
+// written locally or changed by a sync.
+package history
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+const logFileName = "log.json"
+
+// Reason is why a revision was recorded.
+type Reason string
+
+const (
+	ReasonWrite   Reason = "write"
+	ReasonSync    Reason = "sync"
+	ReasonRestore Reason = "restore"
+)
+
+// Revision describes a recorded version of a document.
+type Revision struct {
+	// N is the revision number, starting at 1 for each document.
+	N int `json:"n"`
+	// Recorded is when the revision was captured (right before it was replaced).
+	Recorded  time.Time `json:"recorded"`
+	Lastmod   time.Time `json:"lastmod"`
+	LastmodBy string    `json:"lastmod_by"`
+	Size      int       `json:"size"`
+	Hash      string    `json:"hash"`
+	Reason    Reason    `json:"reason"`
+}
+
+// Policy limits how many revisions are kept. Zero values mean no limit.
+type Policy struct {
+	MaxRevisions int
+	MaxAge       time.Duration
+}
+
+// DefaultPolicy is applied to a document each time a revision of it is recorded.
+var DefaultPolicy = Policy{
+	MaxRevisions: 100,
+	MaxAge:       90 * 24 * time.Hour,
+}
+
+// Store is the on-disk history of all documents. Each document gets a directory named
+// after its ID holding a log of its revisions and the (compressed) content of each.
+type Store struct {
+	dir    string
+	Policy Policy
+	mu     sync.Mutex
+}
+
+// Open returns the history store within the core's writeable path.
+func Open(core lockbook.Core) *Store {
+	return &Store{
+		dir:    filepath.Join(core.WriteablePath(), "history"),
+		Policy: DefaultPolicy,
+	}
+}
+
+func (s *Store) docDir(id lockbook.FileID) string {
+	return filepath.Join(s.dir, id.String())
+}
+
+func (s *Store) revPath(id lockbook.FileID, n int) string {
+	return filepath.Join(s.docDir(id), fmt.Sprintf("%d.gz", n))
+}
+
+// Revisions returns the recorded revisions of a document from oldest to newest.
+func (s *Store) Revisions(id lockbook.FileID) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readLog(id)
+}
+
+func (s *Store) readLog(id lockbook.FileID) ([]Revision, error) {
+	data, err := os.ReadFile(filepath.Join(s.docDir(id), logFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	if err := json.Unmarshal(data, &revs); err != nil {
+		return nil, fmt.Errorf("decoding history of %q: %w", id, err)
+	}
+	return revs, nil
+}
+
+func (s *Store) writeLog(id lockbook.FileID, revs []Revision) error {
+	data, err := json.MarshalIndent(revs, "", "  ")
+	if err != nil {
+		return err
+	}
+	fpath := filepath.Join(s.docDir(id), logFileName)
+	if err := os.WriteFile(fpath+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(fpath+".tmp", fpath)
+}
+
+// Record saves the given content as the newest revision of a document unless it's the
+// same as the newest revision already recorded. The file's metadata should be from
+// before it's replaced.
+func (s *Store) Record(f *lockbook.File, data []byte, reason Reason) (Revision, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revs, err := s.readLog(f.ID)
+	if err != nil {
+		return Revision{}, false, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if len(revs) > 0 && revs[len(revs)-1].Hash == hash {
+		return revs[len(revs)-1], false, nil
+	}
+	rev := Revision{
+		N:         1,
+		Recorded:  time.Now(),
+		Lastmod:   f.Lastmod,
+		LastmodBy: f.LastmodBy,
+		Size:      len(data),
+		Hash:      hash,
+		Reason:    reason,
+	}
+	if len(revs) > 0 {
+		rev.N = revs[len(revs)-1].N + 1
+	}
+	if err := os.MkdirAll(s.docDir(f.ID), 0o700); err != nil {
+		return Revision{}, false, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return Revision{}, false, err
+	}
+	if err := os.WriteFile(s.revPath(f.ID, rev.N), buf.Bytes(), 0o600); err != nil {
+		return Revision{}, false, fmt.Errorf("writing revision: %w", err)
+	}
+	revs, err = s.prune(f.ID, append(revs, rev), s.Policy)
+	if err != nil {
+		return Revision{}, false, err
+	}
+	return rev, true, s.writeLog(f.ID, revs)
+}
+
+// Read returns the content of a revision.
+func (s *Store) Read(id lockbook.FileID, n int) ([]byte, error) {
+	f, err := os.Open(s.revPath(id, n))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no revision %d of %q", n, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading revision %d of %q: %w", n, id, err)
+	}
+	return io.ReadAll(zr)
+}
+
+// Prune applies a retention policy to the history of every document (or only the given
+// documents) and returns how many revisions were removed.
+func (s *Store) Prune(p Policy, ids ...lockbook.FileID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(ids) == 0 {
+		entries, err := os.ReadDir(s.dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if id, err := uuid.FromString(e.Name()); err == nil && e.IsDir() {
+				ids = append(ids, id)
+			}
+		}
+	}
+	n := 0
+	for _, id := range ids {
+		revs, err := s.readLog(id)
+		if err != nil {
+			return n, err
+		}
+		kept, err := s.prune(id, revs, p)
+		if err != nil {
+			return n, err
+		}
+		if removed := len(revs) - len(kept); removed > 0 {
+			n += removed
+			if err := s.writeLog(id, kept); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// prune removes the content of revisions beyond the policy's limits and returns the
+// revisions that are kept.
+func (s *Store) prune(id lockbook.FileID, revs []Revision, p Policy) ([]Revision, error) {
+	start := 0
+	if p.MaxRevisions > 0 && len(revs) > p.MaxRevisions {
+		start = len(revs) - p.MaxRevisions
+	}
+	if p.MaxAge > 0 {
+		cutoff := time.Now().Add(-p.MaxAge)
+		for start < len(revs) && revs[start].Recorded.Before(cutoff) {
+			start++
+		}
+	}
+	for _, r := range revs[:start] {
+		if err := os.Remove(s.revPath(id, r.N)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return revs[start:], nil
+}
@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// enmlMedia is an attachment referenced by an `<en-media>` tag.
+type enmlMedia struct {
+	name    string
+	lbPath  string
+	isImage bool
+}
+
+var (
+	spaceRunRgx   = regexp.MustCompile(`[ \t\r\n]+`)
+	blankLinesRgx = regexp.MustCompile(`\n{3,}`)
+)
+
+type mdList struct {
+	isOrdered bool
+	n         int
+}
+
+// mdWriter builds markdown while keeping track of line starts so blocks can be separated
+// and quotes prefixed.
+type mdWriter struct {
+	b           strings.Builder
+	atLineStart bool
+	trailingNLs int
+	quoteDepth  int
+	lists       []mdList
+	links       []string
+	inPre       bool
+	skipDepth   int
+	// Table state.
+	rowNum  int
+	numCols int
+	colNum  int
+}
+
+func (w *mdWriter) write(s string) {
+	for s != "" {
+		if w.atLineStart && s[0] != '\n' {
+			w.b.WriteString(strings.Repeat("> ", w.quoteDepth))
+			w.atLineStart = false
+		}
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			w.b.WriteString(s)
+			w.trailingNLs = 0
+			return
+		}
+		if i > 0 {
+			w.trailingNLs = 0
+		}
+		if w.atLineStart && w.quoteDepth > 0 {
+			w.b.WriteString(strings.TrimSpace(strings.Repeat("> ", w.quoteDepth)))
+		}
+		w.b.WriteString(s[:i+1])
+		w.trailingNLs++
+		w.atLineStart = true
+		s = s[i+1:]
+	}
+}
+
+// ensureNewlines makes sure the output ends with at least `n` newlines (unless nothing
+// has been written yet).
+func (w *mdWriter) ensureNewlines(n int) {
+	if w.b.Len() == 0 {
+		return
+	}
+	for w.trailingNLs < n {
+		w.write("\n")
+	}
+}
+
+func (w *mdWriter) text(s string) {
+	if w.inPre {
+		w.write(s)
+		return
+	}
+	s = spaceRunRgx.ReplaceAllString(s, " ")
+	if w.atLineStart || w.b.Len() == 0 {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.write(s)
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// enmlToMarkdown converts an Evernote note's ENML (a subset of XHTML) into markdown.
+// Unknown tags are dropped but their text is kept.
+func enmlToMarkdown(enml string, media map[string]enmlMedia) (string, error) {
+	dec := xml.NewDecoder(strings.NewReader(enml))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	w := mdWriter{atLineStart: true}
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if w.skipDepth > 0 {
+			switch tok.(type) {
+			case xml.StartElement:
+				w.skipDepth++
+			case xml.EndElement:
+				w.skipDepth--
+			}
+			continue
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			w.text(string(t))
+		case xml.StartElement:
+			w.start(t, media)
+		case xml.EndElement:
+			w.end(t)
+		}
+	}
+	out := blankLinesRgx.ReplaceAllString(w.b.String(), "\n\n")
+	return strings.TrimSpace(out) + "\n", nil
+}
+
+func (w *mdWriter) start(se xml.StartElement, media map[string]enmlMedia) {
+	switch name := se.Name.Local; name {
+	case "p", "div":
+		w.ensureNewlines(1)
+	case "br":
+		w.write("\n")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.ensureNewlines(2)
+		w.write(strings.Repeat("#", int(name[1]-'0')) + " ")
+	case "b", "strong":
+		w.write("**")
+	case "i", "em":
+		w.write("*")
+	case "s", "strike", "del":
+		w.write("~~")
+	case "code":
+		if !w.inPre {
+			w.write("`")
+		}
+	case "pre":
+		w.ensureNewlines(2)
+		w.write("```\n")
+		w.inPre = true
+	case "blockquote":
+		w.ensureNewlines(2)
+		w.quoteDepth++
+	case "hr":
+		w.ensureNewlines(2)
+		w.write("---")
+		w.ensureNewlines(2)
+	case "a":
+		href := attr(se, "href")
+		w.links = append(w.links, href)
+		if href != "" {
+			w.write("[")
+		}
+	case "ul", "ol":
+		if len(w.lists) == 0 {
+			w.ensureNewlines(2)
+		}
+		w.lists = append(w.lists, mdList{isOrdered: name == "ol"})
+	case "li":
+		w.ensureNewlines(1)
+		if len(w.lists) == 0 {
+			w.write("- ")
+			break
+		}
+		l := &w.lists[len(w.lists)-1]
+		l.n++
+		w.write(strings.Repeat("  ", len(w.lists)-1))
+		if l.isOrdered {
+			w.write(strconv.Itoa(l.n) + ". ")
+		} else {
+			w.write("- ")
+		}
+	case "en-todo":
+		if w.atLineStart || w.b.Len() == 0 {
+			w.write("- ")
+		}
+		if attr(se, "checked") == "true" {
+			w.write("[x] ")
+		} else {
+			w.write("[ ] ")
+		}
+	case "en-media":
+		m, ok := media[attr(se, "hash")]
+		if !ok {
+			break
+		}
+		if m.isImage {
+			w.write("!")
+		}
+		w.write("[" + m.name + "](" + mdLinkDest(m.lbPath) + ")")
+	case "img":
+		w.write("![" + attr(se, "alt") + "](" + mdLinkDest(attr(se, "src")) + ")")
+	case "en-crypt":
+		w.write("[encrypted content omitted]")
+		w.skipDepth = 1
+	case "table":
+		w.ensureNewlines(2)
+		w.rowNum = 0
+	case "tr":
+		w.ensureNewlines(1)
+		w.write("|")
+		w.colNum = 0
+	case "td", "th":
+		w.write(" ")
+	}
+}
+
+func (w *mdWriter) end(ee xml.EndElement) {
+	switch name := ee.Name.Local; name {
+	case "p", "div":
+		w.ensureNewlines(1)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.ensureNewlines(2)
+	case "b", "strong":
+		w.write("**")
+	case "i", "em":
+		w.write("*")
+	case "s", "strike", "del":
+		w.write("~~")
+	case "code":
+		if !w.inPre {
+			w.write("`")
+		}
+	case "pre":
+		w.ensureNewlines(1)
+		w.inPre = false
+		w.write("```")
+		w.ensureNewlines(2)
+	case "blockquote":
+		if w.quoteDepth > 0 {
+			w.quoteDepth--
+		}
+		w.ensureNewlines(2)
+	case "a":
+		if len(w.links) == 0 {
+			break
+		}
+		href := w.links[len(w.links)-1]
+		w.links = w.links[:len(w.links)-1]
+		if href != "" {
+			w.write("](" + mdLinkDest(href) + ")")
+		}
+	case "ul", "ol":
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+		if len(w.lists) == 0 {
+			w.ensureNewlines(2)
+		} else {
+			w.ensureNewlines(1)
+		}
+	case "td", "th":
+		w.write(" |")
+		w.colNum++
+	case "tr":
+		if w.rowNum == 0 {
+			w.numCols = w.colNum
+			w.write("\n|" + strings.Repeat(" --- |", w.numCols))
+		}
+		w.rowNum++
+		w.ensureNewlines(1)
+	case "table":
+		w.ensureNewlines(2)
+	}
+}
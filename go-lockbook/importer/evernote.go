@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+const enexTimeLayout = "20060102T150405Z"
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data struct {
+		Encoding string `xml:"encoding,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+// Evernote imports an Evernote `.enex` export into a folder (named after the export file)
+// within the lockbook folder at `dest`. Each note's ENML is converted to markdown with
+// its title, creation & update times and tags in front matter. Resources (images and
+// other attachments) are extracted into an "attachments" folder and linked from the
+// notes that use them.
+func Evernote(core lockbook.Core, enexPath, dest string, fn func(lockbook.ImportFileInfo)) error {
+	f, err := os.Open(enexPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	notebook := sanitizeName(strings.TrimSuffix(filepath.Base(enexPath), filepath.Ext(enexPath)))
+	used := uniquePaths{}
+	var items []item
+
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", enexPath, err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "note" {
+			continue
+		}
+		var n enexNote
+		if err := dec.DecodeElement(&n, &se); err != nil {
+			return fmt.Errorf("parsing %s: %w", enexPath, err)
+		}
+		noteItems, err := convertEnexNote(&n, enexPath, notebook, dest, used)
+		if err != nil {
+			return fmt.Errorf("converting note %q: %w", n.Title, err)
+		}
+		items = append(items, noteItems...)
+	}
+	return writeItems(core, dest, items, fn)
+}
+
+// convertEnexNote returns the markdown document for a note followed by its attachments.
+func convertEnexNote(n *enexNote, src, notebook, dest string, used uniquePaths) ([]item, error) {
+	items := []item{{
+		src:  src + ": " + n.Title,
+		path: used.get(notebook + "/" + sanitizeName(n.Title) + ".md"),
+	}}
+	media := make(map[string]enmlMedia, len(n.Resources))
+	for i := range n.Resources {
+		r := &n.Resources[i]
+		if r.Data.Encoding != "base64" {
+			return nil, fmt.Errorf("unsupported resource encoding %q", r.Data.Encoding)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.Map(dropSpace, r.Data.Value))
+		if err != nil {
+			return nil, fmt.Errorf("decoding resource: %w", err)
+		}
+		sum := md5.Sum(data)
+		hash := hex.EncodeToString(sum[:])
+
+		name := sanitizeName(r.FileName)
+		if r.FileName == "" {
+			name = "resource-" + hash[:8]
+			if exts, _ := mime.ExtensionsByType(r.Mime); len(exts) > 0 {
+				name += exts[0]
+			}
+		}
+		p := used.get(notebook + "/attachments/" + name)
+		items = append(items, item{src: src + ": " + n.Title + ": " + name, path: p, data: data})
+		media[hash] = enmlMedia{
+			name:    path.Base(p),
+			lbPath:  lbJoin(dest, p),
+			isImage: strings.HasPrefix(r.Mime, "image/"),
+		}
+	}
+
+	body, err := enmlToMarkdown(n.Content, media)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("title: " + strconv.Quote(n.Title) + "\n")
+	if t, err := time.Parse(enexTimeLayout, n.Created); err == nil {
+		b.WriteString("created: " + t.Format(time.RFC3339) + "\n")
+	}
+	if t, err := time.Parse(enexTimeLayout, n.Updated); err == nil {
+		b.WriteString("updated: " + t.Format(time.RFC3339) + "\n")
+	}
+	if len(n.Tags) > 0 {
+		tags := make([]string, len(n.Tags))
+		for i, t := range n.Tags {
+			tags[i] = strconv.Quote(t)
+		}
+		b.WriteString("tags: [" + strings.Join(tags, ", ") + "]\n")
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(body)
+	items[0].data = []byte(b.String())
+	return items, nil
+}
+
+func dropSpace(r rune) rune {
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return -1
+	default:
+		return r
+	}
+}
@@ -0,0 +1,82 @@
+// Package importer brings notes exported from other apps (Obsidian, Notion and Evernote)
+// into lockbook, converting them to lockbook's conventions along the way.
+package importer
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// item is a single document to be written into lockbook.
+type item struct {
+	// src describes where the item came from for progress updates.
+	src string
+	// path is the slash separated path of the document relative to the destination.
+	path string
+	data []byte
+}
+
+// writeItems writes every item under the destination folder and reports progress in the
+// same way as `ImportFile`.
+func writeItems(core lockbook.Core, dest string, items []item, fn func(lockbook.ImportFileInfo)) error {
+	if fn == nil {
+		fn = func(lockbook.ImportFileInfo) {}
+	}
+	fn(lockbook.ImportFileInfo{Total: len(items)})
+	for _, it := range items {
+		fn(lockbook.ImportFileInfo{DiskPath: it.src})
+		f, err := lockbook.WriteFileAtPath(core, lbJoin(dest, it.path), it.data)
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", it.src, err)
+		}
+		fn(lockbook.ImportFileInfo{FileDone: &f})
+	}
+	return nil
+}
+
+// lbJoin joins a lockbook folder path and a relative path.
+func lbJoin(dir, rel string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + strings.TrimPrefix(rel, "/")
+}
+
+// mdLinkDest formats a link destination so that paths with spaces or parentheses still
+// work in markdown.
+func mdLinkDest(p string) string {
+	if strings.ContainsAny(p, " ()<>") {
+		return "<" + p + ">"
+	}
+	return p
+}
+
+// sanitizeName makes a title usable as a lockbook file name.
+func sanitizeName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+// uniquePaths hands out paths that haven't been used yet by adding " (2)", " (3)", etc.
+// before the extension.
+type uniquePaths map[string]bool
+
+func (u uniquePaths) get(p string) string {
+	if !u[p] {
+		u[p] = true
+		return p
+	}
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for n := 2; ; n++ {
+		q := base + " (" + strconv.Itoa(n) + ")" + ext
+		if !u[q] {
+			u[q] = true
+			return q
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestEnmlToMarkdown(t *testing.T) {
+	media := map[string]enmlMedia{
+		"abc": {name: "photo.png", lbPath: "/imports/Note files/photo.png", isImage: true},
+		"def": {name: "doc.pdf", lbPath: "/imports/doc.pdf"},
+	}
+	tests := []struct {
+		name, enml, want string
+	}{
+		{
+			name: "paragraphs and inline styles",
+			enml: `<en-note><div>Hello <b>bold</b> and <i>italic</i></div><div>  second   line </div></en-note>`,
+			want: "Hello **bold** and *italic*\nsecond line\n",
+		},
+		{
+			name: "headings and rules",
+			enml: `<en-note><h2>Title</h2><p>text</p><hr/><p>after</p></en-note>`,
+			want: "## Title\n\ntext\n\n---\n\nafter\n",
+		},
+		{
+			name: "nested lists",
+			enml: `<en-note><ul><li>one<ol><li>a</li><li>b</li></ol></li><li>two</li></ul><p>end</p></en-note>`,
+			want: "- one\n  1. a\n  2. b\n- two\n\nend\n",
+		},
+		{
+			name: "todos",
+			enml: `<en-note><div><en-todo checked="true"/>done</div><div><en-todo/>not yet</div></en-note>`,
+			want: "- [x] done\n- [ ] not yet\n",
+		},
+		{
+			name: "links and media",
+			enml: `<en-note><div><a href="https://example.com/a b">site</a> <en-media hash="abc" type="image/png"/> <en-media hash="def"/> <en-media hash="nope"/></div></en-note>`,
+			want: "[site](<https://example.com/a b>) ![photo.png](</imports/Note files/photo.png>) [doc.pdf](/imports/doc.pdf)\n",
+		},
+		{
+			name: "quotes and code",
+			enml: `<en-note><blockquote><div>quoted</div><div>more</div></blockquote><pre>x  :=  1
+y</pre><div>use <code>go vet</code></div></en-note>`,
+			want: "> quoted\n> more\n\n```\nx  :=  1\ny\n```\n\nuse `go vet`\n",
+		},
+		{
+			name: "tables",
+			enml: `<en-note><table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table></en-note>`,
+			want: "| a | b |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			name: "encrypted content and entities",
+			enml: `<en-note><div>a &amp; b&nbsp;c</div><en-crypt cipher="AES">c2VjcmV0</en-crypt></en-note>`,
+			want: "a & b c\n[encrypted content omitted]\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := enmlToMarkdown(tt.enml, media)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCleanNotionPath(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+	tests := []struct{ path, want string }{
+		{"Notes " + id + ".md", "Notes.md"},
+		{"Notes " + id + "/Sub page " + id + ".md", "Notes/Sub page.md"},
+		{"Notes " + id + "/image.png", "Notes/image.png"},
+		{"Tasks " + id + "_all.csv", "Tasks (all).csv"},
+		{"Tasks " + id + ".csv", "Tasks.csv"},
+		// Anything that isn't a full 32 character ID is left alone.
+		{"Notes 0123.md", "Notes 0123.md"},
+		{"Notes" + id + ".md", "Notes" + id + ".md"},
+		{id + ".md", id + ".md"},
+		{"Notes " + id + "/ " + id + ".md", "Notes/Untitled.md"},
+	}
+	for _, tt := range tests {
+		if got := cleanNotionPath(tt.path); got != tt.want {
+			t.Errorf("cleanNotionPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNotionRenames(t *testing.T) {
+	const id1, id2 = "11111111111111111111111111111111", "22222222222222222222222222222222"
+	paths := []string{
+		"Notes " + id1 + ".md",
+		"Notes " + id2 + ".md",
+		"Notes " + id1 + "/Child " + id1 + ".md",
+		"Notes " + id2 + "/Child " + id2 + ".md",
+	}
+	sort.Strings(paths)
+	used := uniquePaths{}
+	renamed := map[string]string{}
+	for _, p := range paths {
+		renamed[p] = used.get(cleanNotionPath(p))
+	}
+	// Pages that only differed by their ID get numbered rather than overwriting each other.
+	want := map[string]string{
+		"Notes " + id1 + ".md":                   "Notes.md",
+		"Notes " + id2 + ".md":                   "Notes (2).md",
+		"Notes " + id1 + "/Child " + id1 + ".md": "Notes/Child.md",
+		"Notes " + id2 + "/Child " + id2 + ".md": "Notes/Child (2).md",
+	}
+	for p, w := range want {
+		if renamed[p] != w {
+			t.Errorf("%q was renamed to %q, want %q", p, renamed[p], w)
+		}
+	}
+
+	md := []byte("See [child](Notes%2011111111111111111111111111111111/Child%2011111111111111111111111111111111.md), " +
+		"[web](https://example.com/x.md), [anchor](#top) and [missing](Gone.md).")
+	got := string(rewriteNotionLinks(md, ".", "/imports", renamed))
+	wantMD := "See [child](/imports/Notes/Child.md), [web](https://example.com/x.md), [anchor](#top) and [missing](Gone.md)."
+	if got != wantMD {
+		t.Errorf("rewriteNotionLinks = %q, want %q", got, wantMD)
+	}
+}
+
+func TestRewriteWikilinks(t *testing.T) {
+	idx := newVaultIndex([]item{
+		{path: "Home.md"},
+		{path: "projects/Plan.md"},
+		{path: "archive/projects/Plan.md"},
+		{path: "My Notes.md"},
+		{path: "assets/pic.png"},
+		{path: "assets/file.pdf"},
+	})
+	tests := []struct{ in, want string }{
+		{"[[Home]]", "[Home](/v/Home.md)"},
+		{"[[home.md]]", "[Home](/v/Home.md)"},
+		// A name shared by several files resolves to the one with the shortest path, and
+		// a path picks a specific one.
+		{"[[Plan]]", "[Plan](/v/projects/Plan.md)"},
+		{"[[archive/projects/Plan]]", "[Plan](/v/archive/projects/Plan.md)"},
+		{"[[Plan|the plan]]", "[the plan](/v/projects/Plan.md)"},
+		{"[[Plan#Next Steps]]", "[Plan > Next Steps](/v/projects/Plan.md#next-steps)"},
+		{"[[Plan#Next Steps|next]]", "[next](/v/projects/Plan.md#next-steps)"},
+		{"[[My Notes]]", "[My Notes](</v/My Notes.md>)"},
+		{"![[pic.png]]", "![pic.png](/v/assets/pic.png)"},
+		{"![[pic.png|300x200]]", "![pic.png](/v/assets/pic.png)"},
+		{"![[pic.png|a picture]]", "![a picture](/v/assets/pic.png)"},
+		// Only images can be embedded, so other embeds become links.
+		{"![[file.pdf]]", "[file.pdf](/v/assets/file.pdf)"},
+		{"![[Home]]", "[Home](/v/Home.md)"},
+		// Unresolved and same-note links are left alone.
+		{"[[Nowhere]]", "[[Nowhere]]"},
+		{"[[#Heading]]", "[[#Heading]]"},
+		{"a [[Home]] and [[Plan]].", "a [Home](/v/Home.md) and [Plan](/v/projects/Plan.md)."},
+	}
+	for _, tt := range tests {
+		if got := string(rewriteWikilinks([]byte(tt.in), "/v", idx)); got != tt.want {
+			t.Errorf("rewriteWikilinks(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
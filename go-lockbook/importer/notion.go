@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+var (
+	// notionIDRgx matches the ID that Notion appends to every exported page, database and
+	// folder name (along with the "_all" suffix of full database CSVs).
+	notionIDRgx = regexp.MustCompile(`^(.*?)\s+[0-9a-f]{32}(_all)?$`)
+	mdLinkRgx   = regexp.MustCompile(`(!?\[[^\]]*\])\(([^)\s]+)\)`)
+)
+
+// Notion imports a Notion "Markdown & CSV" zip export into the lockbook folder at `dest`.
+// The IDs are stripped from every file and folder name, which rebuilds the page
+// hierarchy, and relative links between pages are rewritten to match. Zips nested inside
+// the export (as Notion does for large workspaces) are unpacked as well.
+func Notion(core lockbook.Core, zipPath, dest string, fn func(lockbook.ImportFileInfo)) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", zipPath, err)
+	}
+	defer zr.Close()
+
+	var items []item
+	if err := collectNotionZip(&zr.Reader, zipPath, &items); err != nil {
+		return err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].path < items[j].path })
+
+	// Every file is renamed first so links can be rewritten to the new paths.
+	renamed := make(map[string]string, len(items))
+	used := uniquePaths{}
+	for i := range items {
+		newPath := used.get(cleanNotionPath(items[i].path))
+		renamed[items[i].path] = newPath
+	}
+	for i := range items {
+		orig := items[i].path
+		if path.Ext(orig) == ".md" {
+			items[i].data = rewriteNotionLinks(items[i].data, path.Dir(orig), dest, renamed)
+		}
+		items[i].path = renamed[orig]
+	}
+	return writeItems(core, dest, items, fn)
+}
+
+func collectNotionZip(zr *zip.Reader, src string, items *[]item) error {
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readZipFile(zf)
+		if err != nil {
+			return fmt.Errorf("reading %s in %s: %w", zf.Name, src, err)
+		}
+		if path.Ext(zf.Name) == ".zip" {
+			inner, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return fmt.Errorf("opening %s in %s: %w", zf.Name, src, err)
+			}
+			if err := collectNotionZip(inner, src+"/"+zf.Name, items); err != nil {
+				return err
+			}
+			continue
+		}
+		*items = append(*items, item{src: src + "/" + zf.Name, path: zf.Name, data: data})
+	}
+	return nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// cleanNotionPath strips Notion's IDs from each element of a path.
+func cleanNotionPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		ext := ""
+		if i == len(parts)-1 {
+			ext = path.Ext(part)
+		}
+		name := strings.TrimSuffix(part, ext)
+		if m := notionIDRgx.FindStringSubmatch(name); m != nil {
+			name = m[1]
+			if m[2] != "" {
+				name += " (all)"
+			}
+		}
+		parts[i] = sanitizeName(name) + ext
+	}
+	return strings.Join(parts, "/")
+}
+
+func rewriteNotionLinks(data []byte, dir, dest string, renamed map[string]string) []byte {
+	return mdLinkRgx.ReplaceAllFunc(data, func(m []byte) []byte {
+		sub := mdLinkRgx.FindSubmatch(m)
+		text, target := string(sub[1]), string(sub[2])
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+			return m
+		}
+		unescaped, err := url.PathUnescape(target)
+		if err != nil {
+			return m
+		}
+		newPath, ok := renamed[path.Join(dir, unescaped)]
+		if !ok {
+			return m
+		}
+		return []byte(text + "(" + mdLinkDest(lbJoin(dest, newPath)) + ")")
+	})
+}
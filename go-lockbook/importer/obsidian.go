@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// wikilinkRgx matches `[[target]]`, `[[target#heading]]`, `[[target|alias]]` and embeds
+// (the same with a leading `!`).
+var wikilinkRgx = regexp.MustCompile(`(!?)\[\[([^\]|#]*)(#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+
+// Obsidian imports an Obsidian vault into the lockbook folder at `dest`. The vault's
+// folder structure is kept and wikilinks and embeds are rewritten as markdown links to
+// lockbook paths. Hidden folders (such as `.obsidian`) are skipped.
+func Obsidian(core lockbook.Core, vaultDir, dest string, fn func(lockbook.ImportFileInfo)) error {
+	var items []item
+	err := filepath.WalkDir(vaultDir, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && fpath != vaultDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(vaultDir, fpath)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		items = append(items, item{src: fpath, path: filepath.ToSlash(rel), data: data})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading vault: %w", err)
+	}
+
+	idx := newVaultIndex(items)
+	for i := range items {
+		if path.Ext(items[i].path) == ".md" {
+			items[i].data = rewriteWikilinks(items[i].data, dest, idx)
+		}
+	}
+	return writeItems(core, dest, items, fn)
+}
+
+// vaultIndex resolves wikilink targets the way Obsidian does: by path relative to the
+// vault or by file name alone, with or without the `.md` extension.
+type vaultIndex struct {
+	byPath map[string]string
+	byName map[string]string
+}
+
+func newVaultIndex(items []item) vaultIndex {
+	idx := vaultIndex{
+		byPath: make(map[string]string, len(items)),
+		byName: make(map[string]string, len(items)),
+	}
+	// Shorter paths win when names collide, like Obsidian's "shortest path" setting.
+	paths := make([]string, len(items))
+	for i := range items {
+		paths[i] = items[i].path
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	add := func(m map[string]string, key, p string) {
+		key = strings.ToLower(key)
+		if _, ok := m[key]; !ok {
+			m[key] = p
+		}
+	}
+	for _, p := range paths {
+		add(idx.byPath, p, p)
+		add(idx.byName, path.Base(p), p)
+		if path.Ext(p) == ".md" {
+			add(idx.byPath, strings.TrimSuffix(p, ".md"), p)
+			add(idx.byName, strings.TrimSuffix(path.Base(p), ".md"), p)
+		}
+	}
+	return idx
+}
+
+func (idx vaultIndex) resolve(target string) (string, bool) {
+	target = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(target), "/"))
+	if p, ok := idx.byPath[target]; ok {
+		return p, true
+	}
+	p, ok := idx.byName[path.Base(target)]
+	return p, ok
+}
+
+func rewriteWikilinks(data []byte, dest string, idx vaultIndex) []byte {
+	return wikilinkRgx.ReplaceAllFunc(data, func(m []byte) []byte {
+		sub := wikilinkRgx.FindSubmatch(m)
+		isEmbed := len(sub[1]) > 0
+		target, heading, alias := string(sub[2]), string(sub[3]), string(sub[4])
+
+		p, ok := idx.resolve(target)
+		if target == "" || !ok {
+			return m
+		}
+		text := alias
+		if text == "" {
+			text = strings.TrimSuffix(path.Base(p), ".md")
+			if heading != "" {
+				text += " > " + strings.TrimPrefix(heading, "#")
+			}
+		}
+		link := mdLinkDest(lbJoin(dest, p) + headingAnchor(heading))
+		// Only images can be embedded in markdown. Any other embed becomes a link.
+		if isEmbed && isImageExt(path.Ext(p)) {
+			// An image embed's alias can be a size ("300" or "300x200") rather than text.
+			if alias == "" || strings.Trim(alias, "0123456789x") == "" {
+				text = path.Base(p)
+			}
+			return []byte("![" + text + "](" + link + ")")
+		}
+		return []byte("[" + text + "](" + link + ")")
+	})
+}
+
+func headingAnchor(heading string) string {
+	h := strings.TrimSpace(strings.TrimPrefix(heading, "#"))
+	if h == "" {
+		return ""
+	}
+	return "#" + strings.ReplaceAll(strings.ToLower(h), " ", "-")
+}
+
+func isImageExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp", ".svg":
+		return true
+	default:
+		return false
+	}
+}
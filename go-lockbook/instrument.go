@@ -0,0 +1,480 @@
+package lockbook
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram buckets.
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	30 * time.Second,
+}
+
+// OpStats holds the metrics recorded for one operation, such as a `Core` method.
+type OpStats struct {
+	Op     string
+	Calls  int64
+	Errors int64
+	// ErrorCodes counts the errors that were an `*Error` by their code. Other errors are
+	// only counted in `Errors`.
+	ErrorCodes map[ErrorCode]int64 `json:",omitempty"`
+	Total      time.Duration
+	Max        time.Duration
+	// Buckets counts the calls that took at most each of the `latencyBuckets` (not
+	// cumulative). The last count is for calls that took longer than all of them.
+	Buckets []int64
+	// Size is the sum of the payload sizes: bytes for document content, or the number of
+	// items for calls that return lists.
+	Size int64
+}
+
+// Mean returns the average call latency.
+func (s *OpStats) Mean() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// Stats collects per-operation call counts, latencies, error codes and payload sizes.
+// The zero value is ready to use.
+type Stats struct {
+	mu  sync.Mutex
+	ops map[string]*OpStats
+}
+
+// Observe records a call to an operation that took `d`. The error (which can be nil) and
+// payload size are counted as well.
+func (s *Stats) Observe(op string, d time.Duration, err error, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ops == nil {
+		s.ops = make(map[string]*OpStats)
+	}
+	st, ok := s.ops[op]
+	if !ok {
+		st = &OpStats{Op: op, Buckets: make([]int64, len(latencyBuckets)+1)}
+		s.ops[op] = st
+	}
+	st.Calls++
+	st.Total += d
+	if d > st.Max {
+		st.Max = d
+	}
+	st.Buckets[sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })]++
+	st.Size += int64(size)
+	if err != nil {
+		st.Errors++
+		var lbErr *Error
+		if errors.As(err, &lbErr) {
+			if st.ErrorCodes == nil {
+				st.ErrorCodes = make(map[ErrorCode]int64)
+			}
+			st.ErrorCodes[lbErr.Code]++
+		}
+	}
+}
+
+// Snapshot returns a copy of the metrics for each operation, sorted by name.
+func (s *Stats) Snapshot() []OpStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := make([]OpStats, 0, len(s.ops))
+	for _, st := range s.ops {
+		cp := *st
+		cp.Buckets = append([]int64(nil), st.Buckets...)
+		if st.ErrorCodes != nil {
+			cp.ErrorCodes = make(map[ErrorCode]int64, len(st.ErrorCodes))
+			for code, n := range st.ErrorCodes {
+				cp.ErrorCodes[code] = n
+			}
+		}
+		snap = append(snap, cp)
+	}
+	sort.Slice(snap, func(i, j int) bool { return snap[i].Op < snap[j].Op })
+	return snap
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (s *Stats) WritePrometheus(w io.Writer) error {
+	snap := s.Snapshot()
+	var err error
+	printf := func(format string, a ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	printf("# HELP lockbook_calls_total Number of calls by operation.\n")
+	printf("# TYPE lockbook_calls_total counter\n")
+	for _, st := range snap {
+		printf("lockbook_calls_total{op=%q} %d\n", st.Op, st.Calls)
+	}
+	printf("# HELP lockbook_errors_total Number of failed calls by operation and lockbook error code (0 for other errors).\n")
+	printf("# TYPE lockbook_errors_total counter\n")
+	for _, st := range snap {
+		other := st.Errors
+		codes := make([]ErrorCode, 0, len(st.ErrorCodes))
+		for code, n := range st.ErrorCodes {
+			codes = append(codes, code)
+			other -= n
+		}
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+		if other > 0 {
+			printf("lockbook_errors_total{op=%q,code=\"0\"} %d\n", st.Op, other)
+		}
+		for _, code := range codes {
+			printf("lockbook_errors_total{op=%q,code=\"%d\"} %d\n", st.Op, code, st.ErrorCodes[code])
+		}
+	}
+	printf("# HELP lockbook_payload_size_total Bytes of document content, or number of items for lists, by operation.\n")
+	printf("# TYPE lockbook_payload_size_total counter\n")
+	for _, st := range snap {
+		printf("lockbook_payload_size_total{op=%q} %d\n", st.Op, st.Size)
+	}
+	printf("# HELP lockbook_call_duration_seconds Call latencies by operation.\n")
+	printf("# TYPE lockbook_call_duration_seconds histogram\n")
+	for _, st := range snap {
+		var cum int64
+		for i, le := range latencyBuckets {
+			cum += st.Buckets[i]
+			printf("lockbook_call_duration_seconds_bucket{op=%q,le=\"%g\"} %d\n", st.Op, le.Seconds(), cum)
+		}
+		printf("lockbook_call_duration_seconds_bucket{op=%q,le=\"+Inf\"} %d\n", st.Op, st.Calls)
+		printf("lockbook_call_duration_seconds_sum{op=%q} %g\n", st.Op, st.Total.Seconds())
+		printf("lockbook_call_duration_seconds_count{op=%q} %d\n", st.Op, st.Calls)
+	}
+	return err
+}
+
+// Publish exports the metrics as an expvar variable with the given name. Like
+// `expvar.Publish`, it panics if the name is already in use.
+func (s *Stats) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return s.Snapshot() }))
+}
+
+// Handler returns an HTTP handler for a debug listener. It serves the metrics in the
+// Prometheus text format at "/metrics", as JSON at "/stats", and all expvar variables at
+// "/debug/vars".
+func (s *Stats) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.WritePrometheus(w)
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Snapshot())
+	})
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// InstrumentOptions configures `Instrument`.
+type InstrumentOptions struct {
+	// Stats is where the metrics are recorded. If it's nil, a new one is used.
+	Stats *Stats
+	// SlowThreshold is how long a call can take before it's logged. Zero means slow calls
+	// aren't logged.
+	SlowThreshold time.Duration
+	// Logger is where slow calls are logged. If it's nil, the standard logger is used.
+	Logger *log.Logger
+}
+
+// InstrumentedCore is a core that records metrics about each call to the wrapped core.
+type InstrumentedCore struct {
+	Core
+	Stats *Stats
+
+	slow   time.Duration
+	logger *log.Logger
+}
+
+// Instrument returns a core that records the call count, latency, error codes and
+// payload size of each call to the given core, and logs calls that are slow.
+func Instrument(core Core, opts InstrumentOptions) *InstrumentedCore {
+	c := &InstrumentedCore{
+		Core:   core,
+		Stats:  opts.Stats,
+		slow:   opts.SlowThreshold,
+		logger: opts.Logger,
+	}
+	if c.Stats == nil {
+		c.Stats = &Stats{}
+	}
+	if c.logger == nil {
+		c.logger = log.Default()
+	}
+	return c
+}
+
+// done records a call to a method that started at `start`.
+func (c *InstrumentedCore) done(method string, start time.Time, err error, size int) {
+	d := time.Since(start)
+	c.Stats.Observe(method, d, err, size)
+	if c.slow > 0 && d >= c.slow {
+		c.logger.Printf("slow call: method=%s duration=%v size=%d err=%v", method, d, size, err)
+	}
+}
+
+func (c *InstrumentedCore) GetAccount() (Account, error) {
+	start := time.Now()
+	v, err := c.Core.GetAccount()
+	c.done("GetAccount", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) CreateAccount(uname, apiURL string, welcome bool) (Account, error) {
+	start := time.Now()
+	v, err := c.Core.CreateAccount(uname, apiURL, welcome)
+	c.done("CreateAccount", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) ImportAccount(acctStr string) (Account, error) {
+	start := time.Now()
+	v, err := c.Core.ImportAccount(acctStr)
+	c.done("ImportAccount", start, err, len(acctStr))
+	return v, err
+}
+
+func (c *InstrumentedCore) ExportAccount() (string, error) {
+	start := time.Now()
+	v, err := c.Core.ExportAccount()
+	c.done("ExportAccount", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) FileByID(id FileID) (File, error) {
+	start := time.Now()
+	v, err := c.Core.FileByID(id)
+	c.done("FileByID", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) FileByPath(lbPath string) (File, error) {
+	start := time.Now()
+	v, err := c.Core.FileByPath(lbPath)
+	c.done("FileByPath", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) GetRoot() (File, error) {
+	start := time.Now()
+	v, err := c.Core.GetRoot()
+	c.done("GetRoot", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) GetChildren(id FileID) ([]File, error) {
+	start := time.Now()
+	v, err := c.Core.GetChildren(id)
+	c.done("GetChildren", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) GetAndGetChildrenRecursively(id FileID) ([]File, error) {
+	start := time.Now()
+	v, err := c.Core.GetAndGetChildrenRecursively(id)
+	c.done("GetAndGetChildrenRecursively", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) ListMetadatas() ([]File, error) {
+	start := time.Now()
+	v, err := c.Core.ListMetadatas()
+	c.done("ListMetadatas", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) PathByID(id FileID) (string, error) {
+	start := time.Now()
+	v, err := c.Core.PathByID(id)
+	c.done("PathByID", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) ReadDocument(id FileID) ([]byte, error) {
+	start := time.Now()
+	v, err := c.Core.ReadDocument(id)
+	c.done("ReadDocument", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) WriteDocument(id FileID, data []byte) error {
+	start := time.Now()
+	err := c.Core.WriteDocument(id, data)
+	c.done("WriteDocument", start, err, len(data))
+	return err
+}
+
+func (c *InstrumentedCore) CreateFile(name string, parentID FileID, typ FileType) (File, error) {
+	start := time.Now()
+	v, err := c.Core.CreateFile(name, parentID, typ)
+	c.done("CreateFile", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) CreateFileAtPath(lbPath string) (File, error) {
+	start := time.Now()
+	v, err := c.Core.CreateFileAtPath(lbPath)
+	c.done("CreateFileAtPath", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) DeleteFile(id FileID) error {
+	start := time.Now()
+	err := c.Core.DeleteFile(id)
+	c.done("DeleteFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) RenameFile(id FileID, newName string) error {
+	start := time.Now()
+	err := c.Core.RenameFile(id, newName)
+	c.done("RenameFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) MoveFile(srcID, destID FileID) error {
+	start := time.Now()
+	err := c.Core.MoveFile(srcID, destID)
+	c.done("MoveFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) ImportFile(src string, dest FileID, fn func(ImportFileInfo)) error {
+	start := time.Now()
+	err := c.Core.ImportFile(src, dest, fn)
+	c.done("ImportFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) ExportFile(id FileID, dest string, fn func(ExportFileInfo)) error {
+	start := time.Now()
+	err := c.Core.ExportFile(id, dest, fn)
+	c.done("ExportFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) ExportDrawing(id FileID, imgFmt ImageFormat) ([]byte, error) {
+	start := time.Now()
+	v, err := c.Core.ExportDrawing(id, imgFmt)
+	c.done("ExportDrawing", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) ExportDrawingToDisk(id FileID, imgFmt ImageFormat, dest string) error {
+	start := time.Now()
+	err := c.Core.ExportDrawingToDisk(id, imgFmt, dest)
+	c.done("ExportDrawingToDisk", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) GetLastSynced() (time.Time, error) {
+	start := time.Now()
+	v, err := c.Core.GetLastSynced()
+	c.done("GetLastSynced", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) GetLastSyncedHumanString() (string, error) {
+	start := time.Now()
+	v, err := c.Core.GetLastSyncedHumanString()
+	c.done("GetLastSyncedHumanString", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) GetUsage() (UsageMetrics, error) {
+	start := time.Now()
+	v, err := c.Core.GetUsage()
+	c.done("GetUsage", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) GetUncompressedUsage() (UsageItemMetric, error) {
+	start := time.Now()
+	v, err := c.Core.GetUncompressedUsage()
+	c.done("GetUncompressedUsage", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) CalculateWork() (WorkCalculated, error) {
+	start := time.Now()
+	v, err := c.Core.CalculateWork()
+	c.done("CalculateWork", start, err, len(v.WorkUnits))
+	return v, err
+}
+
+func (c *InstrumentedCore) SyncAll(fn func(SyncProgress)) error {
+	start := time.Now()
+	err := c.Core.SyncAll(fn)
+	c.done("SyncAll", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) ShareFile(id FileID, uname string, mode ShareMode) error {
+	start := time.Now()
+	err := c.Core.ShareFile(id, uname, mode)
+	c.done("ShareFile", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) GetPendingShares() ([]File, error) {
+	start := time.Now()
+	v, err := c.Core.GetPendingShares()
+	c.done("GetPendingShares", start, err, len(v))
+	return v, err
+}
+
+func (c *InstrumentedCore) DeletePendingShare(id FileID) error {
+	start := time.Now()
+	err := c.Core.DeletePendingShare(id)
+	c.done("DeletePendingShare", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) GetSubscriptionInfo() (SubscriptionInfo, error) {
+	start := time.Now()
+	v, err := c.Core.GetSubscriptionInfo()
+	c.done("GetSubscriptionInfo", start, err, 0)
+	return v, err
+}
+
+func (c *InstrumentedCore) UpgradeViaStripe(card *CreditCard) error {
+	start := time.Now()
+	err := c.Core.UpgradeViaStripe(card)
+	c.done("UpgradeViaStripe", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) CancelSubscription() error {
+	start := time.Now()
+	err := c.Core.CancelSubscription()
+	c.done("CancelSubscription", start, err, 0)
+	return err
+}
+
+func (c *InstrumentedCore) Validate() ([]string, error) {
+	start := time.Now()
+	v, err := c.Core.Validate()
+	c.done("Validate", start, err, len(v))
+	return v, err
+}
@@ -329,11 +329,14 @@ func (l *lbCoreFFI) SyncAll(fn func(SyncProgress)) error {
 		if fn == nil {
 			return
 		}
-		fn(SyncProgress{
+		sp := SyncProgress{
 			Total:    uint64(cSP.total),
 			Progress: uint64(cSP.progress),
 			Msg:      C.GoString(cSP.msg),
-		})
+		}
+		// The C struct only has a message, so the stage and name are parsed out of it.
+		sp.Stage, sp.Name = parseSyncMsg(sp.Msg)
+		fn(sp)
 	})
 	defer handle.Delete()
 	e := C.lb_sync_all(l.ref, C.LbSyncProgressCallback(C.go_sync_callback), unsafe.Pointer(&handle))
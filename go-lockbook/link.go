@@ -0,0 +1,67 @@
+package lockbook
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxLinkDepth bounds how many links `ResolveLink` follows in a row.
+const maxLinkDepth = 8
+
+// ResolveLink returns the file a link points to. Any other file is returned as is. If
+// the link's target doesn't exist (or isn't available locally), the error is an `*Error`
+// with the `CodeLinkTargetNonexistent` code.
+func ResolveLink(core Core, f File) (File, error) {
+	for i := 0; ; i++ {
+		link, ok := f.Type.(FileTypeLink)
+		if !ok {
+			return f, nil
+		}
+		if i == maxLinkDepth {
+			return File{}, fmt.Errorf("too many levels of links resolving %q", f.Name)
+		}
+		target, err := core.FileByID(link.Target)
+		if err != nil {
+			var lbErr *Error
+			if errors.As(err, &lbErr) && lbErr.Code == CodeFileNonexistent {
+				return File{}, &Error{
+					Code: CodeLinkTargetNonexistent,
+					Msg:  fmt.Sprintf("the target of link %q doesn't exist", f.Name),
+				}
+			}
+			return File{}, fmt.Errorf("file by id %q: %w", link.Target, err)
+		}
+		f = target
+	}
+}
+
+// IsDanglingLink reports whether an error means a link's target doesn't exist.
+func IsDanglingLink(err error) bool {
+	var lbErr *Error
+	return errors.As(err, &lbErr) && lbErr.Code == CodeLinkTargetNonexistent
+}
+
+// LinkTarget describes where a link points.
+type LinkTarget struct {
+	File File
+	// Path is the target's path, or just its name if it has no path locally.
+	Path string
+	// Owner is the user who shared the target (empty if it isn't shared).
+	Owner string
+}
+
+// DescribeLink resolves a link and returns its target's path and owner.
+func DescribeLink(core Core, f File) (LinkTarget, error) {
+	target, err := ResolveLink(core, f)
+	if err != nil {
+		return LinkTarget{}, err
+	}
+	lt := LinkTarget{File: target, Path: target.Name}
+	if p, err := core.PathByID(target.ID); err == nil {
+		lt.Path = p
+	}
+	if len(target.Shares) > 0 {
+		lt.Owner = target.Shares[0].SharedBy
+	}
+	return lt, nil
+}
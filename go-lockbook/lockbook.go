@@ -1,9 +1,11 @@
 package lockbook
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -180,6 +182,82 @@ func FileTypeString(t FileType) string {
 	}
 }
 
+// fileJSON is how a `File` is encoded as JSON, since its `Type` is an interface.
+type fileJSON struct {
+	ID        FileID
+	Parent    FileID
+	Name      string
+	Type      string
+	Lastmod   time.Time
+	LastmodBy string
+	Shares    []Share
+}
+
+func (f File) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileJSON{
+		ID:        f.ID,
+		Parent:    f.Parent,
+		Name:      f.Name,
+		Type:      fileTypeKey(f.Type),
+		Lastmod:   f.Lastmod,
+		LastmodBy: f.LastmodBy,
+		Shares:    f.Shares,
+	})
+}
+
+func (f *File) UnmarshalJSON(data []byte) error {
+	var v fileJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	typ, err := parseFileTypeKey(v.Type)
+	if err != nil {
+		return err
+	}
+	*f = File{
+		ID:        v.ID,
+		Parent:    v.Parent,
+		Name:      v.Name,
+		Type:      typ,
+		Lastmod:   v.Lastmod,
+		LastmodBy: v.LastmodBy,
+		Shares:    v.Shares,
+	}
+	return nil
+}
+
+// fileTypeKey returns a string that `parseFileTypeKey` turns back into the file type:
+// "document", "folder" or "link:" followed by the target ID.
+func fileTypeKey(t FileType) string {
+	switch t := t.(type) {
+	case FileTypeDocument:
+		return "document"
+	case FileTypeFolder:
+		return "folder"
+	case FileTypeLink:
+		return "link:" + t.Target.String()
+	default:
+		return ""
+	}
+}
+
+func parseFileTypeKey(s string) (FileType, error) {
+	switch s {
+	case "document":
+		return FileTypeDocument{}, nil
+	case "folder":
+		return FileTypeFolder{}, nil
+	}
+	if strings.HasPrefix(s, "link:") {
+		id, err := uuid.FromString(strings.TrimPrefix(s, "link:"))
+		if err != nil {
+			return nil, fmt.Errorf("parsing link target: %w", err)
+		}
+		return FileTypeLink{Target: id}, nil
+	}
+	return nil, fmt.Errorf("unknown file type %q", s)
+}
+
 type Share struct {
 	Mode       ShareMode
 	SharedBy   string
@@ -206,14 +284,18 @@ func (s ShareMode) String() string {
 
 func SortFiles(files []File) {
 	sort.SliceStable(files, func(i, j int) bool {
-		a, b := files[i], files[j]
-		if a.IsDir() == b.IsDir() {
-			return a.Name < b.Name
-		}
-		return a.IsDir()
+		return fileLess(&files[i], &files[j])
 	})
 }
 
+// fileLess reports whether `a` comes before `b` in `SortFiles` order.
+func fileLess(a, b *File) bool {
+	if a.IsDir() == b.IsDir() {
+		return a.Name < b.Name
+	}
+	return a.IsDir()
+}
+
 type WorkCalculated struct {
 	LastServerUpdateAt uint64
 	WorkUnits          []WorkUnit
@@ -236,6 +318,16 @@ type SyncProgress struct {
 	Total    uint64
 	Progress uint64
 	Msg      string
+	Stage    SyncStage
+	// Name is the name of the document being pushed or pulled (parsed out of the message).
+	Name string
+	// FileID, Path and Ambiguous are only set for documents by `SyncAllDetailed`. The
+	// path is just the document's name if it isn't known yet. Ambiguous means other files
+	// being synced have the same name, so the ID and path may be another one's. The core
+	// doesn't report how many bytes it transfers.
+	FileID    FileID
+	Path      string
+	Ambiguous bool
 }
 
 type UsageMetrics struct {
@@ -280,6 +372,76 @@ const (
 	ImgFmtBMP
 )
 
+// ImageFormats lists every format a drawing can be exported as.
+var ImageFormats = []ImageFormat{
+	ImgFmtPNG,
+	ImgFmtJPEG,
+	ImgFmtPNM,
+	ImgFmtTGA,
+	ImgFmtFarbfeld,
+	ImgFmtBMP,
+}
+
+func (f ImageFormat) String() string {
+	switch f {
+	case ImgFmtPNG:
+		return "PNG"
+	case ImgFmtJPEG:
+		return "JPEG"
+	case ImgFmtPNM:
+		return "PNM"
+	case ImgFmtTGA:
+		return "TGA"
+	case ImgFmtFarbfeld:
+		return "Farbfeld"
+	case ImgFmtBMP:
+		return "BMP"
+	default:
+		return "ImageFormat(" + strconv.FormatInt(int64(f), 10) + ")"
+	}
+}
+
+// ParseImageFormat returns the image format for a name such as "png" or "jpg" (case
+// insensitive).
+func ParseImageFormat(s string) (ImageFormat, error) {
+	switch strings.ToLower(s) {
+	case "png":
+		return ImgFmtPNG, nil
+	case "jpg", "jpeg":
+		return ImgFmtJPEG, nil
+	case "pnm":
+		return ImgFmtPNM, nil
+	case "tga":
+		return ImgFmtTGA, nil
+	case "farbfeld", "ff":
+		return ImgFmtFarbfeld, nil
+	case "bmp":
+		return ImgFmtBMP, nil
+	default:
+		return 0, fmt.Errorf("unknown image format %q", s)
+	}
+}
+
+// Ext returns the file extension (including the dot) typically used for the format.
+func (f ImageFormat) Ext() string {
+	switch f {
+	case ImgFmtPNG:
+		return ".png"
+	case ImgFmtJPEG:
+		return ".jpg"
+	case ImgFmtPNM:
+		return ".pnm"
+	case ImgFmtTGA:
+		return ".tga"
+	case ImgFmtFarbfeld:
+		return ".ff"
+	case ImgFmtBMP:
+		return ".bmp"
+	default:
+		return ""
+	}
+}
+
 type CreditCard struct {
 	Number      string
 	ExpiryYear  int
@@ -362,3 +524,22 @@ func MaybeFileByPath(core Core, p string) (File, bool, error) {
 	}
 	return f, true, nil
 }
+
+// WriteFileAtPath writes the data to the document at the given path, creating it (and any
+// missing parent folders) if it doesn't exist.
+func WriteFileAtPath(core Core, lbPath string, data []byte) (File, error) {
+	f, exists, err := MaybeFileByPath(core, lbPath)
+	if err != nil {
+		return File{}, fmt.Errorf("file by path %q: %w", lbPath, err)
+	}
+	if !exists {
+		f, err = core.CreateFileAtPath(lbPath)
+		if err != nil {
+			return File{}, fmt.Errorf("creating %q: %w", lbPath, err)
+		}
+	}
+	if err := core.WriteDocument(f.ID, data); err != nil {
+		return File{}, fmt.Errorf("writing %q: %w", lbPath, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,85 @@
+package lockbook
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// IsConnectivityError reports whether the given error means the server couldn't be
+// reached (or asked to be retried later) as opposed to something being wrong with the
+// request itself.
+func IsConnectivityError(err error) bool {
+	var lberr *Error
+	if !errors.As(err, &lberr) {
+		return false
+	}
+	return lberr.Code == CodeServerUnreachable || lberr.Code == CodeTryAgain
+}
+
+// Ping checks whether the server is reachable by making a single lightweight request.
+func Ping(core Core) error {
+	_, err := core.GetUsage()
+	return err
+}
+
+// CountLocalChanges estimates how many files have local changes waiting to be pushed by
+// counting the files last modified by this account since the last sync. It doesn't
+// require a connection, so it's useful for reporting queued work while offline, but it's
+// only an estimate: deletions aren't counted, and edits pulled from this account's other
+// devices are if they're dated after the last sync (such as when clocks disagree).
+// `CalculateWork` gives the exact work, but it needs the server.
+func CountLocalChanges(core Core) (int, error) {
+	acct, err := core.GetAccount()
+	if err != nil {
+		return 0, err
+	}
+	lastSynced, err := core.GetLastSynced()
+	if err != nil {
+		return 0, err
+	}
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range files {
+		if files[i].LastmodBy == acct.Username && files[i].Lastmod.After(lastSynced) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Backoff produces exponentially increasing delays between `Min` and `Max` for retrying
+// an operation (such as syncing while offline). Each delay is jittered so that many
+// clients coming back online don't all retry at once. The zero value isn't usable; both
+// `Min` and `Max` must be set.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	attempt int
+	rnd     *rand.Rand
+}
+
+// Next returns how long to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.rnd == nil {
+		b.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	d := b.Min << b.attempt
+	if d <= 0 || d >= b.Max {
+		d = b.Max
+	} else {
+		b.attempt++
+	}
+	// Keep half of the delay and randomize the other half.
+	half := d / 2
+	return half + time.Duration(b.rnd.Int63n(int64(half)+1))
+}
+
+// Reset starts the delays over from `Min`. It should be called after a success.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
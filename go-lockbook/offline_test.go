@@ -0,0 +1,73 @@
+package lockbook
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second, rnd: rand.New(rand.NewSource(1))}
+	// Each delay is the current step with up to half of it taken off as jitter.
+	steps := []time.Duration{1, 2, 4, 8, 10, 10, 10}
+	check := func(steps []time.Duration) {
+		t.Helper()
+		for i, step := range steps {
+			step *= time.Second
+			if d := b.Next(); d < step/2 || d > step {
+				t.Errorf("delay %d is %s, want between %s and %s", i+1, d, step/2, step)
+			}
+		}
+	}
+	check(steps)
+	b.Reset()
+	check(steps[:3])
+
+	// Doubling up to a huge cap never overflows back down to a short delay.
+	b = Backoff{Min: time.Second, Max: time.Duration(1<<63 - 1)}
+	for i, step := 0, time.Second; i < 100; i++ {
+		if d := b.Next(); d < step/2 {
+			t.Fatalf("delay %d is %s, want at least %s", i+1, d, step/2)
+		}
+		if step <= b.Max/2 {
+			step *= 2
+		} else {
+			step = b.Max
+		}
+	}
+}
+
+// syncedCore is a fake core that has last synced at a fixed time.
+type syncedCore struct {
+	*fakeCore
+	username   string
+	lastSynced time.Time
+}
+
+func (c *syncedCore) GetAccount() (Account, error) { return Account{Username: c.username}, nil }
+
+func (c *syncedCore) GetLastSynced() (time.Time, error) { return c.lastSynced, nil }
+
+func TestCountLocalChanges(t *testing.T) {
+	lastSynced := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	sc := &syncedCore{fakeCore: newFakeCore(t), username: "me", lastSynced: lastSynced}
+	edit := func(p, by string, at time.Time) {
+		f := sc.mustCreate(t, p)
+		sc.files[f.ID].LastmodBy = by
+		sc.files[f.ID].Lastmod = at
+	}
+	edit("/synced.md", "me", lastSynced.Add(-time.Minute))
+	edit("/edited.md", "me", lastSynced.Add(time.Minute))
+	edit("/dir/edited.md", "me", lastSynced.Add(time.Hour))
+	// Changes made by others were pulled, so they aren't waiting to be pushed.
+	edit("/shared.md", "them", lastSynced.Add(time.Minute))
+
+	if n, err := CountLocalChanges(sc); err != nil || n != 2 {
+		t.Errorf("CountLocalChanges = %d, %v; want 2", n, err)
+	}
+	// Before the first sync, everything of ours counts.
+	sc.lastSynced = time.Time{}
+	if n, err := CountLocalChanges(sc); err != nil || n != 3 {
+		t.Errorf("CountLocalChanges before syncing = %d, %v; want 3", n, err)
+	}
+}
@@ -0,0 +1,574 @@
+package lockbook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncFilter selects which local changes a selective sync pushes.
+type SyncFilter struct {
+	// Only limits the sync to files within these folders. Empty means everything.
+	Only []string `json:"only,omitempty"`
+	// Exclude leaves out the files matching any of these patterns (see `Glob`), along
+	// with everything in a matching folder.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// IsZero reports whether the filter selects everything.
+func (f SyncFilter) IsZero() bool {
+	return len(f.Only) == 0 && len(f.Exclude) == 0
+}
+
+// Validate returns an error if any of the exclude patterns are invalid.
+func (f SyncFilter) Validate() error {
+	for _, pattern := range f.Exclude {
+		patterns, err := expandBraces(pattern)
+		if err != nil {
+			return err
+		}
+		for _, pat := range patterns {
+			for _, seg := range strings.Split(strings.Trim(pat, "/"), "/") {
+				if _, err := path.Match(seg, ""); err != nil {
+					return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports whether the file at the given path is selected by the filter.
+func (f SyncFilter) Match(p string) bool {
+	if len(f.Only) > 0 {
+		within := false
+		for _, dir := range f.Only {
+			dir = "/" + strings.Trim(dir, "/") + "/"
+			if dir == "//" || strings.HasPrefix(p, dir) || p == dir {
+				within = true
+				break
+			}
+		}
+		if !within {
+			return false
+		}
+	}
+	for _, pat := range f.Exclude {
+		// A file is excluded along with its folder, so check each of its ancestors too.
+		for q := p; q != "" && q != "/"; q = parentPath(q) {
+			if ok, _ := MatchPath(pat, q); ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parentPath returns the path of the folder containing the given path, such as "/a/" for
+// both "/a/b" and "/a/b/".
+func parentPath(p string) string {
+	i := strings.LastIndexByte(strings.TrimSuffix(p, "/"), '/')
+	if i == -1 {
+		return ""
+	}
+	return p[:i+1]
+}
+
+// SyncChange is a work unit from `CalculateWork` with what's known about its file.
+type SyncChange struct {
+	WorkUnit
+	// Path is the file's path, or its ID if the file only exists on the server.
+	Path string
+	// Size is the server's (compressed) size of the file from the usage metadata. For a
+	// document being pushed that the server doesn't have yet, it's the local size. It's
+	// -1 if unknown.
+	Size int64
+	// LastmodBy is who last modified the local copy of the file, which may not be who
+	// made a change being pulled. It's empty if the file isn't local.
+	LastmodBy string
+	IsDir     bool
+	// Selected reports whether the change is selected by the sync filter. Changes being
+	// pulled are always selected since the server's changes can't be filtered.
+	Selected bool
+}
+
+// PlanSync returns what a sync would do, pushes first and then pulls, each sorted by
+// path.
+func PlanSync(core Core, filter SyncFilter) ([]SyncChange, error) {
+	work, err := core.CalculateWork()
+	if err != nil {
+		return nil, fmt.Errorf("calculating work: %w", err)
+	}
+	if len(work.WorkUnits) == 0 {
+		return nil, nil
+	}
+	serverSizes, err := fileServerSizes(core)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]SyncChange, len(work.WorkUnits))
+	for i, wu := range work.WorkUnits {
+		ch := SyncChange{WorkUnit: wu, Path: wu.ID.String(), Size: -1, Selected: true}
+		f, err := core.FileByID(wu.ID)
+		if err == nil {
+			ch.LastmodBy = f.LastmodBy
+			ch.IsDir = f.IsDir()
+			if p, err := core.PathByID(wu.ID); err == nil {
+				ch.Path = p
+			}
+		}
+		if n, ok := serverSizes[wu.ID]; ok {
+			ch.Size = n
+		}
+		if err == nil && wu.Type == WorkUnitTypeLocal {
+			ch.Selected = filter.Match(ch.Path)
+			// Only a new document has to be read to get a size.
+			if _, isDoc := f.Type.(FileTypeDocument); isDoc && ch.Size < 0 {
+				data, err := core.ReadDocument(wu.ID)
+				if err != nil {
+					return nil, fmt.Errorf("reading %q: %w", ch.Path, err)
+				}
+				ch.Size = int64(len(data))
+			}
+		}
+		plan[i] = ch
+	}
+	sort.SliceStable(plan, func(i, j int) bool {
+		a, b := plan[i], plan[j]
+		if a.Type != b.Type {
+			return a.Type == WorkUnitTypeLocal
+		}
+		return a.Path < b.Path
+	})
+	return plan, nil
+}
+
+func fileServerSizes(core Core) (map[FileID]int64, error) {
+	u, err := core.GetUsage()
+	if err != nil {
+		return nil, fmt.Errorf("getting usage: %w", err)
+	}
+	sizes := make(map[FileID]int64, len(u.Usages))
+	for _, fu := range u.Usages {
+		sizes[fu.FileID] = int64(fu.SizeBytes)
+	}
+	return sizes, nil
+}
+
+// DeferredWrite is a document write held back by a `DeferredCore`.
+type DeferredWrite struct {
+	ID       FileID    `json:"id"`
+	Path     string    `json:"path"`
+	Size     int       `json:"size"`
+	Deferred time.Time `json:"deferred"`
+	// Lastmod is when the document was last modified before the first of its writes was
+	// held back.
+	Lastmod time.Time `json:"lastmod"`
+}
+
+const (
+	deferredDirName    = "deferred"
+	deferredIndexName  = "index.json"
+	deferredFilterName = "filter.json"
+)
+
+// DeferredCore is a core that holds back writes to documents that aren't selected by a
+// sync filter, since the core itself can only sync everything. Held back writes are kept
+// on disk (in the core's writeable path) and returned by `ReadDocument` until they're
+// flushed to the core, which happens on the first sync that selects them.
+//
+// Changes that were written to the core before they were filtered out can't be held
+// back, and neither can metadata changes such as renames and moves.
+type DeferredCore struct {
+	Core
+	// OnDefer (if set) is called each time a write is held back. It's called with the core
+	// locked, so it must not call back into it.
+	OnDefer func(DeferredWrite)
+
+	dir string
+	mu  sync.Mutex
+}
+
+// ErrWritesHeld is returned when exporting files that have writes being held back, since
+// the export would leave those writes out.
+var ErrWritesHeld = errors.New("writes are being held back by the sync filter")
+
+// DeferWrites returns a core that holds back writes according to the sync filter saved
+// by `SetFilter`.
+func DeferWrites(core Core) *DeferredCore {
+	return &DeferredCore{
+		Core: core,
+		dir:  filepath.Join(core.WriteablePath(), deferredDirName),
+	}
+}
+
+// Filter returns the saved sync filter.
+func (c *DeferredCore) Filter() (SyncFilter, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.filter()
+}
+
+func (c *DeferredCore) filter() (SyncFilter, error) {
+	var f SyncFilter
+	data, err := os.ReadFile(filepath.Join(c.dir, deferredFilterName))
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("reading sync filter: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("decoding sync filter: %w", err)
+	}
+	return f, nil
+}
+
+// SetFilter saves the sync filter that decides which writes are held back. A zero
+// filter holds nothing back.
+func (c *DeferredCore) SetFilter(f SyncFilter) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fpath := filepath.Join(c.dir, deferredFilterName)
+	if f.IsZero() {
+		if err := os.Remove(fpath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing sync filter: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("creating deferred writes dir: %w", err)
+	}
+	if err := os.WriteFile(fpath, data, 0o600); err != nil {
+		return fmt.Errorf("writing sync filter: %w", err)
+	}
+	return nil
+}
+
+// Deferred returns the writes that are being held back, sorted by path.
+func (c *DeferredCore) Deferred() ([]DeferredWrite, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index()
+}
+
+func (c *DeferredCore) index() ([]DeferredWrite, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, deferredIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading deferred writes: %w", err)
+	}
+	var writes []DeferredWrite
+	if err := json.Unmarshal(data, &writes); err != nil {
+		return nil, fmt.Errorf("decoding deferred writes: %w", err)
+	}
+	return writes, nil
+}
+
+func (c *DeferredCore) writeIndex(writes []DeferredWrite) error {
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Path < writes[j].Path })
+	data, err := json.MarshalIndent(writes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("creating deferred writes dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, deferredIndexName), data, 0o600); err != nil {
+		return fmt.Errorf("writing deferred writes: %w", err)
+	}
+	return nil
+}
+
+func (c *DeferredCore) contentPath(id FileID) string {
+	return filepath.Join(c.dir, id.String())
+}
+
+// drop removes a held back write (if there is one) from the index and disk.
+func (c *DeferredCore) drop(writes []DeferredWrite, id FileID) ([]DeferredWrite, bool, error) {
+	for i := range writes {
+		if writes[i].ID != id {
+			continue
+		}
+		if err := os.Remove(c.contentPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return writes, false, fmt.Errorf("removing deferred write: %w", err)
+		}
+		return append(writes[:i:i], writes[i+1:]...), true, nil
+	}
+	return writes, false, nil
+}
+
+// WriteDocument holds the write back if the document isn't selected by the sync filter.
+// Otherwise it's written to the core, replacing any write that was being held back.
+func (c *DeferredCore) WriteDocument(id FileID, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filter, err := c.filter()
+	if err != nil {
+		return err
+	}
+	writes, err := c.index()
+	if err != nil {
+		return err
+	}
+	p, err := c.Core.PathByID(id)
+	if err != nil {
+		return fmt.Errorf("path by id %q: %w", id, err)
+	}
+	var lastmod time.Time
+	for _, w := range writes {
+		if w.ID == id {
+			lastmod = w.Lastmod
+		}
+	}
+	writes, dropped, err := c.drop(writes, id)
+	if err != nil {
+		return err
+	}
+	if filter.Match(p) {
+		if err := c.Core.WriteDocument(id, data); err != nil {
+			return err
+		}
+		if dropped {
+			return c.writeIndex(writes)
+		}
+		return nil
+	}
+	if lastmod.IsZero() {
+		f, err := c.Core.FileByID(id)
+		if err != nil {
+			return fmt.Errorf("file by id %q: %w", id, err)
+		}
+		lastmod = f.Lastmod
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("creating deferred writes dir: %w", err)
+	}
+	if err := os.WriteFile(c.contentPath(id), data, 0o600); err != nil {
+		return fmt.Errorf("saving deferred write: %w", err)
+	}
+	w := DeferredWrite{
+		ID:       id,
+		Path:     p,
+		Size:     len(data),
+		Deferred: time.Now(),
+		Lastmod:  lastmod,
+	}
+	if err := c.writeIndex(append(writes, w)); err != nil {
+		return err
+	}
+	if c.OnDefer != nil {
+		c.OnDefer(w)
+	}
+	return nil
+}
+
+// ReadDocument returns the content of a held back write if there is one.
+func (c *DeferredCore) ReadDocument(id FileID) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := os.ReadFile(c.contentPath(id))
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading deferred write: %w", err)
+	}
+	return c.Core.ReadDocument(id)
+}
+
+// DeleteFile discards any held back write to the file.
+func (c *DeferredCore) DeleteFile(id FileID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Core.DeleteFile(id); err != nil {
+		return err
+	}
+	writes, err := c.index()
+	if err != nil {
+		return err
+	}
+	writes, dropped, err := c.drop(writes, id)
+	if err != nil || !dropped {
+		return err
+	}
+	return c.writeIndex(writes)
+}
+
+// ExportFile fails with `ErrWritesHeld` if there are held back writes to the file or
+// anything in it.
+func (c *DeferredCore) ExportFile(id FileID, dest string, fn func(ExportFileInfo)) error {
+	if err := c.checkNoneHeld(id); err != nil {
+		return err
+	}
+	return c.Core.ExportFile(id, dest, fn)
+}
+
+// ExportDrawing fails with `ErrWritesHeld` if there's a held back write to the drawing.
+func (c *DeferredCore) ExportDrawing(id FileID, imgFmt ImageFormat) ([]byte, error) {
+	if err := c.checkNoneHeld(id); err != nil {
+		return nil, err
+	}
+	return c.Core.ExportDrawing(id, imgFmt)
+}
+
+// ExportDrawingToDisk fails with `ErrWritesHeld` if there's a held back write to the
+// drawing.
+func (c *DeferredCore) ExportDrawingToDisk(id FileID, imgFmt ImageFormat, dest string) error {
+	if err := c.checkNoneHeld(id); err != nil {
+		return err
+	}
+	return c.Core.ExportDrawingToDisk(id, imgFmt, dest)
+}
+
+// checkNoneHeld returns `ErrWritesHeld` if there are held back writes to the file or
+// (if it's a folder) anything in it.
+func (c *DeferredCore) checkNoneHeld(id FileID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writes, err := c.index()
+	if err != nil || len(writes) == 0 {
+		return err
+	}
+	p, err := c.Core.PathByID(id)
+	if err != nil {
+		return fmt.Errorf("path by id %q: %w", id, err)
+	}
+	for _, w := range writes {
+		if w.ID == id || (strings.HasSuffix(p, "/") && strings.HasPrefix(w.Path, p)) {
+			return fmt.Errorf("exporting %q: %w", p, ErrWritesHeld)
+		}
+	}
+	return nil
+}
+
+// Flush writes the held back writes that the filter selects to the core and returns
+// the ones it wrote. Writes to documents that no longer exist are discarded. A document that was
+// modified (such as by a sync) after its write was held back isn't overwritten. Instead,
+// the held back content is saved as a conflict copy next to it, and recorded as a
+// conflict if the core below is a `ConflictCore`.
+func (c *DeferredCore) Flush(filter SyncFilter) ([]DeferredWrite, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writes, err := c.index()
+	if err != nil || len(writes) == 0 {
+		return nil, err
+	}
+	var flushed []DeferredWrite
+	kept := make([]DeferredWrite, 0, len(writes))
+	for _, w := range writes {
+		f, err := c.Core.FileByID(w.ID)
+		if err != nil {
+			var lbErr *Error
+			if errors.As(err, &lbErr) && lbErr.Code == CodeFileNonexistent {
+				os.Remove(c.contentPath(w.ID))
+				continue
+			}
+			return flushed, fmt.Errorf("file by id %q: %w", w.ID, err)
+		}
+		p, err := c.Core.PathByID(w.ID)
+		if err != nil {
+			return flushed, fmt.Errorf("path by id %q: %w", w.ID, err)
+		}
+		w.Path = p
+		if !filter.Match(p) {
+			kept = append(kept, w)
+			continue
+		}
+		data, err := os.ReadFile(c.contentPath(w.ID))
+		if err != nil {
+			return flushed, fmt.Errorf("reading deferred write: %w", err)
+		}
+		conflicted := !w.Lastmod.IsZero() && !f.Lastmod.Equal(w.Lastmod)
+		if conflicted {
+			err = c.saveConflict(f, data)
+		} else if err = c.Core.WriteDocument(w.ID, data); err != nil {
+			err = fmt.Errorf("writing %q: %w", p, err)
+		}
+		if err != nil {
+			return flushed, err
+		}
+		if err := os.Remove(c.contentPath(w.ID)); err != nil {
+			return flushed, fmt.Errorf("removing deferred write: %w", err)
+		}
+		if !conflicted {
+			flushed = append(flushed, w)
+		}
+	}
+	return flushed, c.writeIndex(kept)
+}
+
+// conflictSaver is implemented by `ConflictCore`.
+type conflictSaver interface {
+	SaveConflict(f File, local []byte) (Conflict, error)
+}
+
+// saveConflict saves the content of a held back write as a conflict copy of its
+// document.
+func (c *DeferredCore) saveConflict(f File, data []byte) error {
+	if cs, ok := c.Core.(conflictSaver); ok {
+		_, err := cs.SaveConflict(f, data)
+		return err
+	}
+	_, err := saveConflictCopy(c.Core, f, data, time.Now())
+	return err
+}
+
+// SyncAll flushes the held back writes that the saved filter now selects and then syncs.
+func (c *DeferredCore) SyncAll(fn func(SyncProgress)) error {
+	work, err := c.Core.CalculateWork()
+	if err != nil {
+		return fmt.Errorf("calculating work: %w", err)
+	}
+	return c.SyncWork(work, fn)
+}
+
+// SyncWork is `SyncAll` with the work already calculated. Each flushed write is added to
+// the work as a local change for the cores below.
+func (c *DeferredCore) SyncWork(work WorkCalculated, fn func(SyncProgress)) error {
+	filter, err := c.Filter()
+	if err != nil {
+		return err
+	}
+	flushed, err := c.Flush(filter)
+	if err != nil {
+		return fmt.Errorf("flushing deferred writes: %w", err)
+	}
+	if len(flushed) > 0 {
+		units := make([]WorkUnit, len(work.WorkUnits), len(work.WorkUnits)+len(flushed))
+		copy(units, work.WorkUnits)
+		for _, w := range flushed {
+			wu := WorkUnit{Type: WorkUnitTypeLocal, ID: w.ID}
+			if !hasWorkUnit(units, wu) {
+				units = append(units, wu)
+			}
+		}
+		work.WorkUnits = units
+	}
+	return SyncWithWork(c.Core, work, fn)
+}
+
+func hasWorkUnit(units []WorkUnit, wu WorkUnit) bool {
+	for _, u := range units {
+		if u == wu {
+			return true
+		}
+	}
+	return false
+}
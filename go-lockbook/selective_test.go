@@ -0,0 +1,193 @@
+package lockbook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"notes/*.md", "/notes/a.md", true},
+		{"/notes/*.md", "/notes/a.md", true},
+		{"notes/*.md", "/notes/old/a.md", false},
+		{"*.md", "/notes/a.md", false},
+		{"notes/*", "/notes/.hidden", false},
+		{"notes/.*", "/notes/.hidden", true},
+		// A `**` segment matches zero or more (non-hidden) folders.
+		{"**/*.md", "/a.md", true},
+		{"**/*.md", "/notes/old/a.md", true},
+		{"notes/**/a.md", "/notes/a.md", true},
+		{"notes/**/a.md", "/notes/x/y/z/a.md", true},
+		{"**/a.md", "/.trash/a.md", false},
+		{"notes/**", "/notes/x/y", true},
+		// Braces expand into alternatives, which may contain slashes.
+		{"{notes,work}/a.md", "/work/a.md", true},
+		{"{notes,work}/a.md", "/play/a.md", false},
+		{"{notes/old,work}/*.md", "/notes/old/a.md", true},
+		{"*.{md,txt}", "/a.txt", true},
+		// A trailing slash only matches folders, whose paths end with a slash.
+		{"drafts/", "/drafts/", true},
+		{"drafts/", "/drafts", false},
+		{"drafts", "/drafts/", true},
+		{"**/drafts/", "/notes/drafts/", true},
+	}
+	for _, tt := range tests {
+		got, err := MatchPath(tt.pattern, tt.path)
+		if err != nil {
+			t.Errorf("MatchPath(%q, %q): %v", tt.pattern, tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+	for _, pattern := range []string{"[a", "{a,b", "a}"} {
+		if _, err := MatchPath(pattern, "/a"); err == nil {
+			t.Errorf("MatchPath(%q): expected an error", pattern)
+		}
+	}
+}
+
+func TestSyncFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SyncFilter
+		path   string
+		want   bool
+	}{
+		{"zero", SyncFilter{}, "/anything.md", true},
+		{"only within", SyncFilter{Only: []string{"notes"}}, "/notes/a.md", true},
+		{"only nested", SyncFilter{Only: []string{"/notes/"}}, "/notes/old/a.md", true},
+		{"only the folder", SyncFilter{Only: []string{"notes"}}, "/notes/", true},
+		{"only outside", SyncFilter{Only: []string{"notes"}}, "/work/a.md", false},
+		{"only prefix", SyncFilter{Only: []string{"notes"}}, "/notes-old/a.md", false},
+		{"only several", SyncFilter{Only: []string{"notes", "work"}}, "/work/a.md", true},
+		{"only root", SyncFilter{Only: []string{"/"}}, "/work/a.md", true},
+		{"exclude file", SyncFilter{Exclude: []string{"**/*.tmp"}}, "/notes/a.tmp", false},
+		{"exclude other", SyncFilter{Exclude: []string{"**/*.tmp"}}, "/notes/a.md", true},
+		{"exclude folder", SyncFilter{Exclude: []string{"drafts/"}}, "/drafts/x/a.md", false},
+		{"exclude ancestor", SyncFilter{Exclude: []string{"notes/old"}}, "/notes/old/a.md", false},
+		{"exclude braces", SyncFilter{Exclude: []string{"{drafts,scratch}"}}, "/scratch/a.md", false},
+		{
+			name:   "only and exclude",
+			filter: SyncFilter{Only: []string{"notes"}, Exclude: []string{"notes/old"}},
+			path:   "/notes/old/a.md",
+			want:   false,
+		},
+		{
+			name:   "only and not excluded",
+			filter: SyncFilter{Only: []string{"notes"}, Exclude: []string{"notes/old"}},
+			path:   "/notes/new/a.md",
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.path); got != tt.want {
+			t.Errorf("%s: Match(%q) = %t, want %t", tt.name, tt.path, got, tt.want)
+		}
+	}
+
+	if err := (SyncFilter{Exclude: []string{"a/[b"}}).Validate(); err == nil {
+		t.Error("Validate with an invalid pattern: expected an error")
+	}
+}
+
+func TestDeferredCore(t *testing.T) {
+	fc := newFakeCore(t)
+	notes := fc.mustCreate(t, "/notes/a.md")
+	work := fc.mustCreate(t, "/work/b.md")
+	dc := DeferWrites(fc)
+	var deferred []string
+	dc.OnDefer = func(w DeferredWrite) { deferred = append(deferred, w.Path) }
+
+	if err := dc.SetFilter(SyncFilter{Only: []string{"notes"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.WriteDocument(notes.ID, []byte("selected")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.WriteDocument(work.ID, []byte("held")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(fc.docs[notes.ID]); got != "selected" {
+		t.Errorf("selected write: core has %q", got)
+	}
+	if got := string(fc.docs[work.ID]); got != "" {
+		t.Errorf("held back write reached the core: %q", got)
+	}
+	if data, _ := dc.ReadDocument(work.ID); string(data) != "held" {
+		t.Errorf("ReadDocument of a held back write = %q", data)
+	}
+	if len(deferred) != 1 || deferred[0] != "/work/b.md" {
+		t.Errorf("OnDefer was called for %q", deferred)
+	}
+	if err := dc.ExportFile(work.Parent, t.TempDir(), nil); !errors.Is(err, ErrWritesHeld) {
+		t.Errorf("exporting a folder with a held back write: got %v", err)
+	}
+
+	// A filter that doesn't select the write keeps holding it back.
+	flushed, err := dc.Flush(SyncFilter{Only: []string{"notes"}})
+	if err != nil || len(flushed) != 0 {
+		t.Fatalf("Flush = %v, %v; want nothing flushed", flushed, err)
+	}
+	flushed, err = dc.Flush(SyncFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flushed) != 1 || flushed[0].ID != work.ID {
+		t.Errorf("Flush = %v, want the write to b.md", flushed)
+	}
+	if got := string(fc.docs[work.ID]); got != "held" {
+		t.Errorf("after Flush: core has %q", got)
+	}
+	if held, _ := dc.Deferred(); len(held) != 0 {
+		t.Errorf("still holding back %v after Flush", held)
+	}
+}
+
+func TestDeferredCoreFlushConflict(t *testing.T) {
+	fc := newFakeCore(t)
+	doc := fc.mustCreate(t, "/work/b.md")
+	cc := DetectConflicts(fc)
+	dc := DeferWrites(cc)
+
+	if err := dc.SetFilter(SyncFilter{Only: []string{"notes"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.WriteDocument(doc.ID, []byte("local")); err != nil {
+		t.Fatal(err)
+	}
+	// The document changes (as if by a sync) while the write is held back.
+	time.Sleep(time.Millisecond)
+	if err := fc.WriteDocument(doc.ID, []byte("synced")); err != nil {
+		t.Fatal(err)
+	}
+	flushed, err := dc.Flush(SyncFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flushed) != 0 {
+		t.Errorf("Flush wrote %v over a changed document", flushed)
+	}
+	if got := string(fc.docs[doc.ID]); got != "synced" {
+		t.Errorf("document has %q, want the synced content", got)
+	}
+	conflicts, err := cc.Conflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("got %d conflicts, want 1", len(conflicts))
+	}
+	cf := conflicts[0]
+	if got := string(fc.docs[cf.CopyID]); got != "local" {
+		t.Errorf("conflict copy has %q, want the held back content", got)
+	}
+	if cf.ID != doc.ID || cf.LocalHash != contentHash([]byte("local")) {
+		t.Errorf("unexpected conflict %+v", cf)
+	}
+}
@@ -0,0 +1,85 @@
+package lockbook
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ShareInfo summarizes how a file is shared from one user's point of view.
+type ShareInfo struct {
+	// SharedBy is who shared the file with the user (empty if it isn't shared with them).
+	SharedBy string
+	// WithMe is whether the file is shared with the user.
+	WithMe bool
+	// SharedWith holds the other users the file is shared with, sorted by name.
+	SharedWith []string
+}
+
+// GetShareInfo summarizes a file's shares from the point of view of the given user.
+func GetShareInfo(shares []Share, username string) ShareInfo {
+	var info ShareInfo
+	for _, sh := range shares {
+		if sh.SharedWith == username {
+			info.SharedBy = sh.SharedBy
+			info.WithMe = true
+		} else {
+			info.SharedWith = append(info.SharedWith, sh.SharedWith)
+		}
+	}
+	sort.Strings(info.SharedWith)
+	return info
+}
+
+// SharedFile is a single share of a file.
+type SharedFile struct {
+	File  File
+	Path  string
+	Share Share
+	// InSharedFolder is whether one of the file's ancestors is shared as well.
+	InSharedFolder bool
+}
+
+// ListShares returns every share of every file, sorted by path. The path of a file that
+// isn't in the user's tree (such as one shared with them that hasn't been linked to yet)
+// is just its name.
+func ListShares(core Core) ([]SharedFile, error) {
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return nil, fmt.Errorf("listing metadatas: %w", err)
+	}
+	byID := make(map[FileID]*File, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	var shared []SharedFile
+	for i := range files {
+		f := &files[i]
+		if len(f.Shares) == 0 {
+			continue
+		}
+		p, err := core.PathByID(f.ID)
+		if err != nil {
+			p = f.Name
+		}
+		inShared := hasSharedAncestor(byID, f)
+		for _, sh := range f.Shares {
+			shared = append(shared, SharedFile{File: *f, Path: p, Share: sh, InSharedFolder: inShared})
+		}
+	}
+	sort.SliceStable(shared, func(i, j int) bool { return shared[i].Path < shared[j].Path })
+	return shared, nil
+}
+
+func hasSharedAncestor(byID map[FileID]*File, f *File) bool {
+	for !f.IsRoot() {
+		parent, ok := byID[f.Parent]
+		if !ok {
+			return false
+		}
+		if len(parent.Shares) > 0 {
+			return true
+		}
+		f = parent
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package lockbook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyncStage is what a sync is doing when it reports progress.
+type SyncStage int
+
+const (
+	SyncStageUnknown SyncStage = iota
+	SyncStagePullMetadata
+	SyncStagePushMetadata
+	SyncStagePullDocument
+	SyncStagePushDocument
+)
+
+func (s SyncStage) String() string {
+	switch s {
+	case SyncStagePullMetadata:
+		return "pulling metadata"
+	case SyncStagePushMetadata:
+		return "pushing metadata"
+	case SyncStagePullDocument:
+		return "pulling document"
+	case SyncStagePushDocument:
+		return "pushing document"
+	default:
+		return "syncing"
+	}
+}
+
+// IsPull reports whether the stage brings changes down from the server.
+func (s SyncStage) IsPull() bool {
+	return s == SyncStagePullMetadata || s == SyncStagePullDocument
+}
+
+// WorkSyncer is implemented by cores that look at the pending work before syncing. So
+// that a stack of them only calculates the work once per sync, the outermost one
+// calculates it in `SyncAll` and passes it down with `SyncWithWork`.
+type WorkSyncer interface {
+	// SyncWork syncs like `Core.SyncAll` given the work calculated right before.
+	SyncWork(work WorkCalculated, fn func(SyncProgress)) error
+}
+
+// SyncWithWork syncs the core with the given (already calculated) work if it's a
+// `WorkSyncer`, or just syncs it otherwise.
+func SyncWithWork(core Core, work WorkCalculated, fn func(SyncProgress)) error {
+	if ws, ok := core.(WorkSyncer); ok {
+		return ws.SyncWork(work, fn)
+	}
+	return core.SyncAll(fn)
+}
+
+// parseSyncMsg returns the stage described by the core's progress message along with the
+// name of the document involved (if any). The C API only reports a message, so this
+// relies on the core's wording, which isn't part of its API: documents are reported as
+// "pulling: <name>" or "pushing: <name>" and everything else as "pulling file tree
+// updates" and the like. A message in any other form is `SyncStageUnknown`.
+func parseSyncMsg(msg string) (SyncStage, string) {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(lower, "pulling: "):
+		return SyncStagePullDocument, msg[len("pulling: "):]
+	case strings.HasPrefix(lower, "pushing: "):
+		return SyncStagePushDocument, msg[len("pushing: "):]
+	case strings.HasPrefix(lower, "pulling"):
+		return SyncStagePullMetadata, ""
+	case strings.HasPrefix(lower, "pushing"):
+		return SyncStagePushMetadata, ""
+	default:
+		return SyncStageUnknown, ""
+	}
+}
+
+// syncUnit is a work unit along with what's known about its file before the sync.
+type syncUnit struct {
+	WorkUnit
+	name string
+	path string
+	used bool
+}
+
+// SyncAllDetailed syncs like `Core.SyncAll` except that the progress of each document
+// includes its ID and path. The core only reports documents by name, so they're matched
+// by name against the work calculated before the sync (the core can't be called while
+// it's syncing). When more than one file being synced in the same direction has the
+// name, the first unclaimed one is used and the update is marked `Ambiguous`.
+//
+// The returned updates are the ones for documents. Files that only existed on the
+// server before the sync are resolved afterwards, so their ID and path are set in the
+// returned updates even if they weren't when passed to `fn`. Looking up these details
+// never fails the sync.
+func SyncAllDetailed(core Core, fn func(SyncProgress)) ([]SyncProgress, error) {
+	work, err := core.CalculateWork()
+	if err != nil {
+		return nil, fmt.Errorf("calculating work: %w", err)
+	}
+	units := make([]syncUnit, len(work.WorkUnits))
+	for i, wu := range work.WorkUnits {
+		u := syncUnit{WorkUnit: wu}
+		if f, err := core.FileByID(wu.ID); err == nil {
+			u.name = f.Name
+			if p, err := core.PathByID(wu.ID); err == nil {
+				u.path = p
+			}
+		}
+		units[i] = u
+	}
+
+	var docs []SyncProgress
+	err = SyncWithWork(core, work, func(sp SyncProgress) {
+		if sp.Name != "" {
+			sp.Path = sp.Name
+			u, ambiguous := claimSyncUnit(units, sp.Stage, sp.Name)
+			if u != nil {
+				sp.FileID = u.ID
+				if u.path != "" {
+					sp.Path = u.path
+				}
+			}
+			sp.Ambiguous = ambiguous
+			docs = append(docs, sp)
+		}
+		if fn != nil {
+			fn(sp)
+		}
+	})
+	for i := range docs {
+		sp := &docs[i]
+		// Now that the sync is done, look up the documents that weren't known locally.
+		if sp.FileID.IsNil() {
+			sp.FileID, sp.Path, sp.Ambiguous = resolveSyncedName(core, units, sp.Name)
+		}
+	}
+	return docs, err
+}
+
+// claimSyncUnit returns the first unclaimed work unit in the direction of the given stage
+// whose file has the given name, and marks it as claimed. It also reports whether other
+// unclaimed units had the same name.
+func claimSyncUnit(units []syncUnit, stage SyncStage, name string) (*syncUnit, bool) {
+	typ := WorkUnitTypeLocal
+	if stage.IsPull() {
+		typ = WorkUnitTypeServer
+	}
+	var claimed *syncUnit
+	for i := range units {
+		u := &units[i]
+		if u.used || u.Type != typ || u.name != name {
+			continue
+		}
+		if claimed != nil {
+			return claimed, true
+		}
+		u.used = true
+		claimed = u
+	}
+	return claimed, false
+}
+
+// resolveSyncedName finds an unclaimed server work unit whose file (which should now be
+// local) has the given name. If there isn't one, the name is returned as the path. It
+// also reports whether other unclaimed units had the same name.
+func resolveSyncedName(core Core, units []syncUnit, name string) (FileID, string, bool) {
+	var claimed *syncUnit
+	ambiguous := false
+	for i := range units {
+		u := &units[i]
+		if u.used || u.Type != WorkUnitTypeServer {
+			continue
+		}
+		if f, err := core.FileByID(u.ID); err != nil || f.Name != name {
+			continue
+		}
+		if claimed != nil {
+			ambiguous = true
+			break
+		}
+		claimed = u
+	}
+	if claimed == nil {
+		return FileID{}, name, false
+	}
+	claimed.used = true
+	p, err := core.PathByID(claimed.ID)
+	if err != nil {
+		p = name
+	}
+	return claimed.ID, p, ambiguous
+}
@@ -0,0 +1,32 @@
+package lockbook
+
+import "testing"
+
+// TestParseSyncMsg pins down the wording of the core's progress messages that
+// `parseSyncMsg` relies on, since it isn't part of the core's API.
+func TestParseSyncMsg(t *testing.T) {
+	tests := []struct {
+		msg   string
+		stage SyncStage
+		name  string
+	}{
+		{"pulling: notes.md", SyncStagePullDocument, "notes.md"},
+		{"pushing: notes.md", SyncStagePushDocument, "notes.md"},
+		{"Pulling: Notes.md", SyncStagePullDocument, "Notes.md"},
+		// The name is everything after the prefix, including any spaces or colons.
+		{"pushing: a: b .md", SyncStagePushDocument, "a: b .md"},
+		{"pulling file tree updates", SyncStagePullMetadata, ""},
+		{"Pulling files", SyncStagePullMetadata, ""},
+		{"pushing file tree updates", SyncStagePushMetadata, ""},
+		{"pushing", SyncStagePushMetadata, ""},
+		{"", SyncStageUnknown, ""},
+		{"syncing", SyncStageUnknown, ""},
+		{"downloading: notes.md", SyncStageUnknown, ""},
+	}
+	for _, tt := range tests {
+		stage, name := parseSyncMsg(tt.msg)
+		if stage != tt.stage || name != tt.name {
+			t.Errorf("parseSyncMsg(%q) = %s, %q; want %s, %q", tt.msg, stage, name, tt.stage, tt.name)
+		}
+	}
+}
@@ -0,0 +1,769 @@
+package lockbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceEntry is one line of a trace written by a `RecordingCore`: a single call to the
+// core along with what it returned.
+type TraceEntry struct {
+	Seq    int             `json:"seq"`
+	Method string          `json:"method"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	// Events are the values passed to the callback of a method such as `SyncAll`, in
+	// order.
+	Events []json.RawMessage `json:"events,omitempty"`
+	Err    *TraceError       `json:"err,omitempty"`
+	// Redacted means document content or account secrets in the args or result were
+	// replaced with placeholders.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// TraceError is an error returned by a traced call.
+type TraceError struct {
+	Code  ErrorCode `json:"code"`
+	Msg   string    `json:"msg"`
+	Trace string    `json:"trace,omitempty"`
+	// Plain means the error wasn't an `*Error`, so only its message was kept.
+	Plain bool `json:"plain,omitempty"`
+}
+
+func newTraceError(err error) *TraceError {
+	if err == nil {
+		return nil
+	}
+	var lbErr *Error
+	if errors.As(err, &lbErr) {
+		return &TraceError{Code: lbErr.Code, Msg: lbErr.Msg, Trace: lbErr.Trace}
+	}
+	return &TraceError{Msg: err.Error(), Plain: true}
+}
+
+func (e *TraceError) err() error {
+	if e == nil {
+		return nil
+	}
+	if e.Plain {
+		return errors.New(e.Msg)
+	}
+	return &Error{Code: e.Code, Msg: e.Msg, Trace: e.Trace}
+}
+
+// RecordOptions configures `Recorder`.
+type RecordOptions struct {
+	// RedactContent leaves document content (and exported drawings) out of the trace,
+	// keeping only its size.
+	RedactContent bool
+	// RedactAccount leaves account strings and payment details out of the trace.
+	RedactAccount bool
+}
+
+// docContent is document content, which can be redacted from traces.
+type docContent []byte
+
+// accountSecret is an account string or payment details, which can be redacted from
+// traces.
+type accountSecret struct{ v any }
+
+// redactedValue stands in for a redacted value in a trace.
+type redactedValue struct {
+	Redacted int `json:"redacted"`
+}
+
+// UnmarshalJSON accepts either the content or a placeholder for redacted content, in
+// which case the content is that many 'x' bytes.
+func (d *docContent) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte("{")) {
+		var r redactedValue
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		*d = bytes.Repeat([]byte{'x'}, r.Redacted)
+		return nil
+	}
+	var b []byte
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	*d = b
+	return nil
+}
+
+// secretString is a string result that may have been redacted.
+type secretString string
+
+func (s *secretString) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte("{")) {
+		*s = "REDACTED"
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(s))
+}
+
+// redact returns what's written to a trace in place of the given value, and whether that
+// was a redaction.
+func (o RecordOptions) redact(v any) (any, bool) {
+	switch v := v.(type) {
+	case docContent:
+		if o.RedactContent {
+			return redactedValue{Redacted: len(v)}, true
+		}
+		return []byte(v), false
+	case accountSecret:
+		if o.RedactAccount {
+			return redactedValue{}, true
+		}
+		return v.v, false
+	default:
+		return v, false
+	}
+}
+
+// encodeArgs returns the JSON array of a call's arguments, and whether any were redacted.
+func (o RecordOptions) encodeArgs(args []any) (json.RawMessage, bool, error) {
+	if len(args) == 0 {
+		return nil, false, nil
+	}
+	vals := make([]any, len(args))
+	redacted := false
+	for i, a := range args {
+		v, r := o.redact(a)
+		vals[i] = v
+		redacted = redacted || r
+	}
+	data, err := json.Marshal(vals)
+	return data, redacted, err
+}
+
+// RecordingCore is a core that writes each call it makes to the wrapped core to a JSON
+// lines trace that a `ReplayCore` can replay.
+type RecordingCore struct {
+	Core
+
+	opts RecordOptions
+	mu   sync.Mutex
+	enc  *json.Encoder
+	seq  int
+	err  error
+}
+
+// Recorder returns a core that writes a trace of every call to the given core to `w`.
+// Each entry is written once the call returns.
+func Recorder(core Core, w io.Writer, opts RecordOptions) *RecordingCore {
+	return &RecordingCore{Core: core, opts: opts, enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error encountered while writing the trace, if any. Calls are
+// still made and returned as usual after the trace can't be written.
+func (c *RecordingCore) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// record writes an entry for a call. The result is only written if the call succeeded.
+func (c *RecordingCore) record(method string, args []any, result any, events []any, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = c.writeEntry(method, args, result, events, err)
+}
+
+func (c *RecordingCore) writeEntry(method string, args []any, result any, events []any, callErr error) error {
+	c.seq++
+	e := TraceEntry{Seq: c.seq, Method: method, Err: newTraceError(callErr)}
+	var err error
+	if e.Args, e.Redacted, err = c.opts.encodeArgs(args); err != nil {
+		return fmt.Errorf("encoding %s args: %w", method, err)
+	}
+	if result != nil && callErr == nil {
+		v, redacted := c.opts.redact(result)
+		if e.Result, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("encoding %s result: %w", method, err)
+		}
+		e.Redacted = e.Redacted || redacted
+	}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return fmt.Errorf("encoding %s event: %w", method, err)
+		}
+		e.Events = append(e.Events, data)
+	}
+	if err := c.enc.Encode(e); err != nil {
+		return fmt.Errorf("writing trace entry: %w", err)
+	}
+	return nil
+}
+
+func (c *RecordingCore) WriteablePath() string {
+	v := c.Core.WriteablePath()
+	c.record("WriteablePath", nil, v, nil, nil)
+	return v
+}
+
+func (c *RecordingCore) GetAccount() (Account, error) {
+	v, err := c.Core.GetAccount()
+	c.record("GetAccount", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) CreateAccount(uname, apiURL string, welcome bool) (Account, error) {
+	v, err := c.Core.CreateAccount(uname, apiURL, welcome)
+	c.record("CreateAccount", []any{uname, apiURL, welcome}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) ImportAccount(acctStr string) (Account, error) {
+	v, err := c.Core.ImportAccount(acctStr)
+	c.record("ImportAccount", []any{accountSecret{acctStr}}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) ExportAccount() (string, error) {
+	v, err := c.Core.ExportAccount()
+	c.record("ExportAccount", nil, accountSecret{v}, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) FileByID(id FileID) (File, error) {
+	v, err := c.Core.FileByID(id)
+	c.record("FileByID", []any{id}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) FileByPath(lbPath string) (File, error) {
+	v, err := c.Core.FileByPath(lbPath)
+	c.record("FileByPath", []any{lbPath}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetRoot() (File, error) {
+	v, err := c.Core.GetRoot()
+	c.record("GetRoot", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetChildren(id FileID) ([]File, error) {
+	v, err := c.Core.GetChildren(id)
+	c.record("GetChildren", []any{id}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetAndGetChildrenRecursively(id FileID) ([]File, error) {
+	v, err := c.Core.GetAndGetChildrenRecursively(id)
+	c.record("GetAndGetChildrenRecursively", []any{id}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) ListMetadatas() ([]File, error) {
+	v, err := c.Core.ListMetadatas()
+	c.record("ListMetadatas", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) PathByID(id FileID) (string, error) {
+	v, err := c.Core.PathByID(id)
+	c.record("PathByID", []any{id}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) ReadDocument(id FileID) ([]byte, error) {
+	v, err := c.Core.ReadDocument(id)
+	c.record("ReadDocument", []any{id}, docContent(v), nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) WriteDocument(id FileID, data []byte) error {
+	err := c.Core.WriteDocument(id, data)
+	c.record("WriteDocument", []any{id, docContent(data)}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) CreateFile(name string, parentID FileID, typ FileType) (File, error) {
+	v, err := c.Core.CreateFile(name, parentID, typ)
+	c.record("CreateFile", []any{name, parentID, fileTypeKey(typ)}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) CreateFileAtPath(lbPath string) (File, error) {
+	v, err := c.Core.CreateFileAtPath(lbPath)
+	c.record("CreateFileAtPath", []any{lbPath}, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) DeleteFile(id FileID) error {
+	err := c.Core.DeleteFile(id)
+	c.record("DeleteFile", []any{id}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) RenameFile(id FileID, newName string) error {
+	err := c.Core.RenameFile(id, newName)
+	c.record("RenameFile", []any{id, newName}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) MoveFile(srcID, destID FileID) error {
+	err := c.Core.MoveFile(srcID, destID)
+	c.record("MoveFile", []any{srcID, destID}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) ImportFile(src string, dest FileID, fn func(ImportFileInfo)) error {
+	var events []any
+	err := c.Core.ImportFile(src, dest, func(e ImportFileInfo) {
+		events = append(events, e)
+		if fn != nil {
+			fn(e)
+		}
+	})
+	c.record("ImportFile", []any{src, dest}, nil, events, err)
+	return err
+}
+
+func (c *RecordingCore) ExportFile(id FileID, dest string, fn func(ExportFileInfo)) error {
+	var events []any
+	err := c.Core.ExportFile(id, dest, func(e ExportFileInfo) {
+		events = append(events, e)
+		if fn != nil {
+			fn(e)
+		}
+	})
+	c.record("ExportFile", []any{id, dest}, nil, events, err)
+	return err
+}
+
+func (c *RecordingCore) ExportDrawing(id FileID, imgFmt ImageFormat) ([]byte, error) {
+	v, err := c.Core.ExportDrawing(id, imgFmt)
+	c.record("ExportDrawing", []any{id, imgFmt}, docContent(v), nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) ExportDrawingToDisk(id FileID, imgFmt ImageFormat, dest string) error {
+	err := c.Core.ExportDrawingToDisk(id, imgFmt, dest)
+	c.record("ExportDrawingToDisk", []any{id, imgFmt, dest}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) GetLastSynced() (time.Time, error) {
+	v, err := c.Core.GetLastSynced()
+	c.record("GetLastSynced", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetLastSyncedHumanString() (string, error) {
+	v, err := c.Core.GetLastSyncedHumanString()
+	c.record("GetLastSyncedHumanString", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetUsage() (UsageMetrics, error) {
+	v, err := c.Core.GetUsage()
+	c.record("GetUsage", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) GetUncompressedUsage() (UsageItemMetric, error) {
+	v, err := c.Core.GetUncompressedUsage()
+	c.record("GetUncompressedUsage", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) CalculateWork() (WorkCalculated, error) {
+	v, err := c.Core.CalculateWork()
+	c.record("CalculateWork", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) SyncAll(fn func(SyncProgress)) error {
+	var events []any
+	err := c.Core.SyncAll(func(e SyncProgress) {
+		events = append(events, e)
+		if fn != nil {
+			fn(e)
+		}
+	})
+	c.record("SyncAll", nil, nil, events, err)
+	return err
+}
+
+func (c *RecordingCore) ShareFile(id FileID, uname string, mode ShareMode) error {
+	err := c.Core.ShareFile(id, uname, mode)
+	c.record("ShareFile", []any{id, uname, mode}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) GetPendingShares() ([]File, error) {
+	v, err := c.Core.GetPendingShares()
+	c.record("GetPendingShares", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) DeletePendingShare(id FileID) error {
+	err := c.Core.DeletePendingShare(id)
+	c.record("DeletePendingShare", []any{id}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) GetSubscriptionInfo() (SubscriptionInfo, error) {
+	v, err := c.Core.GetSubscriptionInfo()
+	c.record("GetSubscriptionInfo", nil, v, nil, err)
+	return v, err
+}
+
+func (c *RecordingCore) UpgradeViaStripe(card *CreditCard) error {
+	err := c.Core.UpgradeViaStripe(card)
+	c.record("UpgradeViaStripe", []any{accountSecret{card}}, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) CancelSubscription() error {
+	err := c.Core.CancelSubscription()
+	c.record("CancelSubscription", nil, nil, nil, err)
+	return err
+}
+
+func (c *RecordingCore) Validate() ([]string, error) {
+	v, err := c.Core.Validate()
+	c.record("Validate", nil, v, nil, err)
+	return v, err
+}
+
+// ReplayCore is a core that answers calls from a trace written by a `RecordingCore`
+// instead of from a real core, so no server or data directory is needed.
+//
+// A call is answered by the earliest recorded call to the same method with the same
+// arguments that hasn't been used yet. This keeps replays deterministic even if calls
+// from different goroutines arrive in a different order than when they were recorded.
+// A call with no such entry fails with a `CodeUnexpected` error.
+type ReplayCore struct {
+	// Dir, if set, is returned by `WriteablePath` instead of the recorded path, so that
+	// anything written next to the core's data doesn't end up in the recorded location.
+	Dir string
+
+	mu      sync.Mutex
+	pending map[string][]*TraceEntry
+}
+
+// Replayer reads a trace written by a `RecordingCore` and returns a core that replays it.
+func Replayer(r io.Reader) (*ReplayCore, error) {
+	c := &ReplayCore{pending: make(map[string][]*TraceEntry)}
+	dec := json.NewDecoder(r)
+	for {
+		var e TraceEntry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading trace entry: %w", err)
+		}
+		c.pending[e.Method] = append(c.pending[e.Method], &e)
+	}
+	return c, nil
+}
+
+// Remaining returns how many recorded calls haven't been replayed yet.
+func (c *ReplayCore) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, entries := range c.pending {
+		n += len(entries)
+	}
+	return n
+}
+
+// next removes and returns the entry answering a call.
+func (c *ReplayCore) next(method string, args []any) (*TraceEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var plain, redacted json.RawMessage
+	var err error
+	if plain, _, err = (RecordOptions{}).encodeArgs(args); err != nil {
+		return nil, fmt.Errorf("encoding %s args: %w", method, err)
+	}
+	if redacted, _, err = (RecordOptions{RedactContent: true, RedactAccount: true}).encodeArgs(args); err != nil {
+		return nil, fmt.Errorf("encoding %s args: %w", method, err)
+	}
+	entries := c.pending[method]
+	for i, e := range entries {
+		want := plain
+		if e.Redacted {
+			want = redacted
+		}
+		if bytes.Equal(e.Args, want) {
+			c.pending[method] = append(entries[:i:i], entries[i+1:]...)
+			return e, nil
+		}
+	}
+	return nil, &Error{
+		Code: CodeUnexpected,
+		Msg:  fmt.Sprintf("replay: no recorded %s call with args %s", method, plain),
+	}
+}
+
+// replay answers a call with its recorded error or result, which is decoded into
+// `result` (if it isn't nil).
+func (c *ReplayCore) replay(method string, result any, args ...any) error {
+	e, err := c.next(method, args)
+	if err != nil {
+		return err
+	}
+	if e.Err != nil {
+		return e.Err.err()
+	}
+	if result != nil && len(e.Result) > 0 {
+		if err := json.Unmarshal(e.Result, result); err != nil {
+			return fmt.Errorf("replay: decoding %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// replayEvents is like `replay` for methods that take a callback. Each recorded event is
+// passed to `fn` before the recorded error is returned.
+func (c *ReplayCore) replayEvents(method string, fn func(json.RawMessage) error, args ...any) error {
+	e, err := c.next(method, args)
+	if err != nil {
+		return err
+	}
+	for _, ev := range e.Events {
+		if err := fn(ev); err != nil {
+			return fmt.Errorf("replay: decoding %s event: %w", method, err)
+		}
+	}
+	return e.Err.err()
+}
+
+func (c *ReplayCore) WriteablePath() string {
+	if c.Dir != "" {
+		return c.Dir
+	}
+	var v string
+	c.replay("WriteablePath", &v)
+	return v
+}
+
+func (c *ReplayCore) GetAccount() (Account, error) {
+	var v Account
+	err := c.replay("GetAccount", &v)
+	return v, err
+}
+
+func (c *ReplayCore) CreateAccount(uname, apiURL string, welcome bool) (Account, error) {
+	var v Account
+	err := c.replay("CreateAccount", &v, uname, apiURL, welcome)
+	return v, err
+}
+
+func (c *ReplayCore) ImportAccount(acctStr string) (Account, error) {
+	var v Account
+	err := c.replay("ImportAccount", &v, accountSecret{acctStr})
+	return v, err
+}
+
+func (c *ReplayCore) ExportAccount() (string, error) {
+	var v secretString
+	err := c.replay("ExportAccount", &v)
+	return string(v), err
+}
+
+func (c *ReplayCore) FileByID(id FileID) (File, error) {
+	var v File
+	err := c.replay("FileByID", &v, id)
+	return v, err
+}
+
+func (c *ReplayCore) FileByPath(lbPath string) (File, error) {
+	var v File
+	err := c.replay("FileByPath", &v, lbPath)
+	return v, err
+}
+
+func (c *ReplayCore) GetRoot() (File, error) {
+	var v File
+	err := c.replay("GetRoot", &v)
+	return v, err
+}
+
+func (c *ReplayCore) GetChildren(id FileID) ([]File, error) {
+	var v []File
+	err := c.replay("GetChildren", &v, id)
+	return v, err
+}
+
+func (c *ReplayCore) GetAndGetChildrenRecursively(id FileID) ([]File, error) {
+	var v []File
+	err := c.replay("GetAndGetChildrenRecursively", &v, id)
+	return v, err
+}
+
+func (c *ReplayCore) ListMetadatas() ([]File, error) {
+	var v []File
+	err := c.replay("ListMetadatas", &v)
+	return v, err
+}
+
+func (c *ReplayCore) PathByID(id FileID) (string, error) {
+	var v string
+	err := c.replay("PathByID", &v, id)
+	return v, err
+}
+
+func (c *ReplayCore) ReadDocument(id FileID) ([]byte, error) {
+	var v docContent
+	err := c.replay("ReadDocument", &v, id)
+	return []byte(v), err
+}
+
+func (c *ReplayCore) WriteDocument(id FileID, data []byte) error {
+	return c.replay("WriteDocument", nil, id, docContent(data))
+}
+
+func (c *ReplayCore) CreateFile(name string, parentID FileID, typ FileType) (File, error) {
+	var v File
+	err := c.replay("CreateFile", &v, name, parentID, fileTypeKey(typ))
+	return v, err
+}
+
+func (c *ReplayCore) CreateFileAtPath(lbPath string) (File, error) {
+	var v File
+	err := c.replay("CreateFileAtPath", &v, lbPath)
+	return v, err
+}
+
+func (c *ReplayCore) DeleteFile(id FileID) error {
+	return c.replay("DeleteFile", nil, id)
+}
+
+func (c *ReplayCore) RenameFile(id FileID, newName string) error {
+	return c.replay("RenameFile", nil, id, newName)
+}
+
+func (c *ReplayCore) MoveFile(srcID, destID FileID) error {
+	return c.replay("MoveFile", nil, srcID, destID)
+}
+
+func (c *ReplayCore) ImportFile(src string, dest FileID, fn func(ImportFileInfo)) error {
+	return c.replayEvents("ImportFile", func(raw json.RawMessage) error {
+		var e ImportFileInfo
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(e)
+		}
+		return nil
+	}, src, dest)
+}
+
+func (c *ReplayCore) ExportFile(id FileID, dest string, fn func(ExportFileInfo)) error {
+	return c.replayEvents("ExportFile", func(raw json.RawMessage) error {
+		var e ExportFileInfo
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(e)
+		}
+		return nil
+	}, id, dest)
+}
+
+func (c *ReplayCore) ExportDrawing(id FileID, imgFmt ImageFormat) ([]byte, error) {
+	var v docContent
+	err := c.replay("ExportDrawing", &v, id, imgFmt)
+	return []byte(v), err
+}
+
+func (c *ReplayCore) ExportDrawingToDisk(id FileID, imgFmt ImageFormat, dest string) error {
+	return c.replay("ExportDrawingToDisk", nil, id, imgFmt, dest)
+}
+
+func (c *ReplayCore) GetLastSynced() (time.Time, error) {
+	var v time.Time
+	err := c.replay("GetLastSynced", &v)
+	return v, err
+}
+
+func (c *ReplayCore) GetLastSyncedHumanString() (string, error) {
+	var v string
+	err := c.replay("GetLastSyncedHumanString", &v)
+	return v, err
+}
+
+func (c *ReplayCore) GetUsage() (UsageMetrics, error) {
+	var v UsageMetrics
+	err := c.replay("GetUsage", &v)
+	return v, err
+}
+
+func (c *ReplayCore) GetUncompressedUsage() (UsageItemMetric, error) {
+	var v UsageItemMetric
+	err := c.replay("GetUncompressedUsage", &v)
+	return v, err
+}
+
+func (c *ReplayCore) CalculateWork() (WorkCalculated, error) {
+	var v WorkCalculated
+	err := c.replay("CalculateWork", &v)
+	return v, err
+}
+
+func (c *ReplayCore) SyncAll(fn func(SyncProgress)) error {
+	return c.replayEvents("SyncAll", func(raw json.RawMessage) error {
+		var e SyncProgress
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(e)
+		}
+		return nil
+	})
+}
+
+func (c *ReplayCore) ShareFile(id FileID, uname string, mode ShareMode) error {
+	return c.replay("ShareFile", nil, id, uname, mode)
+}
+
+func (c *ReplayCore) GetPendingShares() ([]File, error) {
+	var v []File
+	err := c.replay("GetPendingShares", &v)
+	return v, err
+}
+
+func (c *ReplayCore) DeletePendingShare(id FileID) error {
+	return c.replay("DeletePendingShare", nil, id)
+}
+
+func (c *ReplayCore) GetSubscriptionInfo() (SubscriptionInfo, error) {
+	var v SubscriptionInfo
+	err := c.replay("GetSubscriptionInfo", &v)
+	return v, err
+}
+
+func (c *ReplayCore) UpgradeViaStripe(card *CreditCard) error {
+	return c.replay("UpgradeViaStripe", nil, accountSecret{card})
+}
+
+func (c *ReplayCore) CancelSubscription() error {
+	return c.replay("CancelSubscription", nil)
+}
+
+func (c *ReplayCore) Validate() ([]string, error) {
+	var v []string
+	err := c.replay("Validate", &v)
+	return v, err
+}
@@ -0,0 +1,109 @@
+package lockbook
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// syncingCore is a fake core whose `SyncAll` reports a fixed series of progress events.
+type syncingCore struct {
+	*fakeCore
+	events []SyncProgress
+}
+
+func (c *syncingCore) SyncAll(fn func(SyncProgress)) error {
+	for _, sp := range c.events {
+		if fn != nil {
+			fn(sp)
+		}
+	}
+	return nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	core := &syncingCore{
+		fakeCore: newFakeCore(t),
+		events: []SyncProgress{
+			{Total: 2, Progress: 1, Msg: "pushing: a.md"},
+			{Total: 2, Progress: 2, Msg: "pulling: b.md"},
+		},
+	}
+	var trace bytes.Buffer
+	rec := Recorder(core, &trace, RecordOptions{RedactContent: true})
+
+	// Record a short session.
+	root, err := rec.GetRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := rec.CreateFileAtPath("/notes/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.WriteDocument(doc.ID, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.ReadDocument(doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	missing := uuid.Must(uuid.NewV4())
+	if _, err := rec.FileByID(missing); err == nil {
+		t.Fatal("FileByID of a missing file: expected an error")
+	}
+	var recorded []SyncProgress
+	if err := rec.SyncAll(func(sp SyncProgress) { recorded = append(recorded, sp) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("writing the trace: %v", err)
+	}
+	if strings.Contains(trace.String(), "secret") || strings.Contains(trace.String(), "c2VjcmV0") {
+		t.Error("the trace contains redacted document content")
+	}
+
+	// Replay it, with the calls in a different order.
+	rp, err := Replayer(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed []SyncProgress
+	if err := rp.SyncAll(func(sp SyncProgress) { replayed = append(replayed, sp) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed sync events %+v, want %+v", replayed, recorded)
+	}
+	if f, err := rp.GetRoot(); err != nil || f.ID != root.ID || !f.IsDir() {
+		t.Errorf("GetRoot = %+v, %v", f, err)
+	}
+	if f, err := rp.CreateFileAtPath("/notes/a.md"); err != nil || f.ID != doc.ID || f.Name != "a.md" {
+		t.Errorf("CreateFileAtPath = %+v, %v", f, err)
+	}
+	_, err = rp.FileByID(missing)
+	var lbErr *Error
+	if !errors.As(err, &lbErr) || lbErr.Code != CodeFileNonexistent {
+		t.Errorf("FileByID of a missing file = %v, want CodeFileNonexistent", err)
+	}
+	// Redacted content is replayed as placeholder bytes of the same length, and redacted
+	// arguments match any content of that length.
+	if data, err := rp.ReadDocument(doc.ID); err != nil || string(data) != "xxxxxx" {
+		t.Errorf("ReadDocument = %q, %v", data, err)
+	}
+	if err := rp.WriteDocument(doc.ID, []byte("xxxxxx")); err != nil {
+		t.Errorf("WriteDocument: %v", err)
+	}
+	if n := rp.Remaining(); n != 0 {
+		t.Errorf("%d recorded calls weren't replayed", n)
+	}
+
+	// Each recorded call only answers once.
+	_, err = rp.GetRoot()
+	if !errors.As(err, &lbErr) || lbErr.Code != CodeUnexpected {
+		t.Errorf("GetRoot after it was replayed = %v, want CodeUnexpected", err)
+	}
+}
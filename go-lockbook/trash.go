@@ -0,0 +1,342 @@
+package lockbook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TrashDirName is the name of the folder (within root) that deleted files are moved
+	// into when the trash is enabled.
+	TrashDirName   = ".trash"
+	trashIndexName = ".index.json"
+)
+
+// ErrTrashDisabled is returned when opening the trash if it hasn't been enabled.
+var ErrTrashDisabled = errors.New("the trash isn't enabled")
+
+// TrashEntry records where a trashed file came from.
+type TrashEntry struct {
+	ID         FileID    `json:"id"`
+	Name       string    `json:"name"`
+	OrigParent FileID    `json:"orig_parent"`
+	OrigPath   string    `json:"orig_path"`
+	Deleted    time.Time `json:"deleted"`
+}
+
+// Trash is an opt-in soft delete. Files are moved into the `/.trash/` folder and an index
+// document in that folder keeps track of each file's original location. Since everything
+// is a regular lockbook file, the trash syncs like anything else.
+type Trash struct {
+	core Core
+	Dir  File
+}
+
+// OpenTrash returns the trash if it's enabled, or `ErrTrashDisabled` if it isn't.
+func OpenTrash(core Core) (*Trash, error) {
+	root, err := core.GetRoot()
+	if err != nil {
+		return nil, fmt.Errorf("getting root: %w", err)
+	}
+	dir, exists, err := MaybeFileByPath(core, "/"+TrashDirName+"/")
+	if err != nil {
+		return nil, fmt.Errorf("file by path: %w", err)
+	}
+	if !exists || !dir.IsDir() || dir.Parent != root.ID {
+		return nil, ErrTrashDisabled
+	}
+	return &Trash{core: core, Dir: dir}, nil
+}
+
+// EnableTrash creates the trash folder if it doesn't exist yet and returns the trash.
+func EnableTrash(core Core) (*Trash, error) {
+	t, err := OpenTrash(core)
+	if !errors.Is(err, ErrTrashDisabled) {
+		return t, err
+	}
+	dir, err := core.CreateFileAtPath("/" + TrashDirName + "/")
+	if err != nil {
+		return nil, fmt.Errorf("creating trash folder: %w", err)
+	}
+	return &Trash{core: core, Dir: dir}, nil
+}
+
+// IsTrashUnsupported reports whether an error from moving a file into the trash means it
+// can't be trashed at all (such as a file within a folder someone else shared). These
+// files can only be deleted permanently.
+func IsTrashUnsupported(err error) bool {
+	var lbErr *Error
+	if !errors.As(err, &lbErr) {
+		return false
+	}
+	switch lbErr.Code {
+	case CodeInsufficientPermission, CodeLinkInSharedFolder:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *Trash) index() ([]TrashEntry, error) {
+	f, exists, err := MaybeFileByPath(t.core, "/"+TrashDirName+"/"+trashIndexName)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := t.core.ReadDocument(f.ID)
+	if err != nil {
+		return nil, fmt.Errorf("reading trash index: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var entries []TrashEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("decoding trash index: %w", err)
+	}
+	return entries, nil
+}
+
+func (t *Trash) writeIndex(entries []TrashEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := WriteFileAtPath(t.core, "/"+TrashDirName+"/"+trashIndexName, data); err != nil {
+		return fmt.Errorf("writing trash index: %w", err)
+	}
+	return nil
+}
+
+// Contains reports whether the file is directly within the trash.
+func (t *Trash) Contains(f *File) bool {
+	return f.Parent == t.Dir.ID && f.Name != trashIndexName
+}
+
+// List returns the files in the trash from most to least recently deleted. Files that
+// ended up in the trash folder without going through `Move` are included with an unknown
+// origin.
+func (t *Trash) List() ([]TrashEntry, error) {
+	entries, err := t.index()
+	if err != nil {
+		return nil, err
+	}
+	children, err := t.core.GetChildren(t.Dir.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting trash contents: %w", err)
+	}
+	byID := make(map[FileID]*TrashEntry, len(entries))
+	for i := range entries {
+		byID[entries[i].ID] = &entries[i]
+	}
+	list := make([]TrashEntry, 0, len(children))
+	for _, ch := range children {
+		if ch.Name == trashIndexName {
+			continue
+		}
+		if e, ok := byID[ch.ID]; ok {
+			list = append(list, *e)
+			continue
+		}
+		list = append(list, TrashEntry{ID: ch.ID, Name: ch.Name, Deleted: ch.Lastmod})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Deleted.After(list[j].Deleted) })
+	return list, nil
+}
+
+// Move puts a file in the trash. If the trash already holds a file with the same name,
+// the file is renamed while it's in the trash and gets its name back when restored.
+func (t *Trash) Move(id FileID) (TrashEntry, error) {
+	f, err := t.core.FileByID(id)
+	if err != nil {
+		return TrashEntry{}, fmt.Errorf("file by id %q: %w", id, err)
+	}
+	if f.ID == t.Dir.ID || f.IsRoot() {
+		return TrashEntry{}, fmt.Errorf("%q can't be moved to the trash", f.Name)
+	}
+	if f.Parent == t.Dir.ID {
+		return TrashEntry{}, fmt.Errorf("%q is already in the trash", f.Name)
+	}
+	p, err := t.core.PathByID(id)
+	if err != nil {
+		return TrashEntry{}, fmt.Errorf("path by id %q: %w", id, err)
+	}
+	entries, err := t.index()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	name, err := availableName(t.core, t.Dir.ID, f.Name)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if name != f.Name {
+		if err := t.core.RenameFile(id, name); err != nil {
+			return TrashEntry{}, fmt.Errorf("renaming %q: %w", f.Name, err)
+		}
+	}
+	if err := t.core.MoveFile(id, t.Dir.ID); err != nil {
+		if name != f.Name {
+			_ = t.core.RenameFile(id, f.Name)
+		}
+		return TrashEntry{}, fmt.Errorf("moving %q to the trash: %w", p, err)
+	}
+
+	e := TrashEntry{
+		ID:         id,
+		Name:       f.Name,
+		OrigParent: f.Parent,
+		OrigPath:   p,
+		Deleted:    time.Now(),
+	}
+	if err := t.writeIndex(append(removeTrashEntry(entries, id), e)); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// Restore moves a file out of the trash back to where it was deleted from and returns
+// its new path. The file goes to root if its original folder no longer exists, and it
+// gets a new name if its original name has been taken.
+func (t *Trash) Restore(id FileID) (string, error) {
+	f, err := t.core.FileByID(id)
+	if err != nil {
+		return "", fmt.Errorf("file by id %q: %w", id, err)
+	}
+	if !t.Contains(&f) {
+		return "", fmt.Errorf("%q isn't in the trash", f.Name)
+	}
+	entries, err := t.index()
+	if err != nil {
+		return "", err
+	}
+	e := TrashEntry{Name: f.Name}
+	for i := range entries {
+		if entries[i].ID == id {
+			e = entries[i]
+			break
+		}
+	}
+
+	dest, err := t.restoreDest(e.OrigParent)
+	if err != nil {
+		return "", err
+	}
+	name, err := availableName(t.core, dest, e.Name)
+	if err != nil {
+		return "", err
+	}
+	if name != f.Name {
+		if err := t.core.RenameFile(id, name); err != nil {
+			return "", fmt.Errorf("renaming %q: %w", f.Name, err)
+		}
+	}
+	if err := t.core.MoveFile(id, dest); err != nil {
+		return "", fmt.Errorf("moving %q out of the trash: %w", name, err)
+	}
+	if err := t.writeIndex(removeTrashEntry(entries, id)); err != nil {
+		return "", err
+	}
+	return t.core.PathByID(id)
+}
+
+// restoreDest returns the original parent if it still exists outside of the trash, and
+// root otherwise.
+func (t *Trash) restoreDest(parent FileID) (FileID, error) {
+	if !parent.IsNil() {
+		f, err := t.core.FileByID(parent)
+		var lbErr *Error
+		switch {
+		case err == nil:
+			p, err := t.core.PathByID(f.ID)
+			if err == nil && !strings.HasPrefix(p, "/"+TrashDirName+"/") {
+				return f.ID, nil
+			}
+		case !errors.As(err, &lbErr) || lbErr.Code != CodeFileNonexistent:
+			return FileID{}, fmt.Errorf("file by id %q: %w", parent, err)
+		}
+	}
+	root, err := t.core.GetRoot()
+	if err != nil {
+		return FileID{}, fmt.Errorf("getting root: %w", err)
+	}
+	return root.ID, nil
+}
+
+// Delete permanently deletes a file that's in the trash.
+func (t *Trash) Delete(id FileID) error {
+	entries, err := t.index()
+	if err != nil {
+		return err
+	}
+	if err := t.core.DeleteFile(id); err != nil {
+		return fmt.Errorf("deleting %q: %w", id, err)
+	}
+	return t.writeIndex(removeTrashEntry(entries, id))
+}
+
+// Empty permanently deletes the files in the trash that were deleted longer ago than
+// `olderThan` (or all of them if it's zero) and returns the deleted entries.
+func (t *Trash) Empty(olderThan time.Duration) ([]TrashEntry, error) {
+	list, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := t.index()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var deleted []TrashEntry
+	for _, e := range list {
+		if olderThan > 0 && e.Deleted.After(cutoff) {
+			continue
+		}
+		if err := t.core.DeleteFile(e.ID); err != nil {
+			return deleted, fmt.Errorf("deleting %q: %w", e.Name, err)
+		}
+		entries = removeTrashEntry(entries, e.ID)
+		deleted = append(deleted, e)
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	return deleted, t.writeIndex(entries)
+}
+
+func removeTrashEntry(entries []TrashEntry, id FileID) []TrashEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// availableName returns the name itself if no child of the parent has it, otherwise the
+// name with the first free " (n)" suffix before its extension.
+func availableName(core Core, parent FileID, name string) (string, error) {
+	children, err := core.GetChildren(parent)
+	if err != nil {
+		return "", fmt.Errorf("getting children of %q: %w", parent, err)
+	}
+	taken := make(map[string]bool, len(children))
+	for _, ch := range children {
+		taken[ch.Name] = true
+	}
+	if !taken[name] {
+		return name, nil
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		if v := fmt.Sprintf("%s (%d)%s", base, n, ext); !taken[v] {
+			return v, nil
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package lockbook
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tree is an in-memory index of file metadata for looking up children, parents and
+// paths without calling into the core for each file. Files shared with the user are
+// placed wherever the user's links to them are.
+type Tree struct {
+	root     *File
+	files    map[FileID]*File
+	children map[FileID][]*File
+	// links maps a link target's ID to the link.
+	links map[FileID]*File
+}
+
+// NewTree indexes the given files, such as the result of `ListMetadatas` or
+// `GetAndGetChildrenRecursively`. Children are kept in `SortFiles` order.
+func NewTree(files []File) *Tree {
+	sorted := make([]File, len(files))
+	copy(sorted, files)
+	SortFiles(sorted)
+	t := &Tree{
+		files:    make(map[FileID]*File, len(sorted)),
+		children: make(map[FileID][]*File, len(sorted)),
+		links:    make(map[FileID]*File),
+	}
+	for i := range sorted {
+		f := &sorted[i]
+		t.files[f.ID] = f
+		if f.IsRoot() {
+			t.root = f
+		} else {
+			t.children[f.Parent] = append(t.children[f.Parent], f)
+		}
+		if link, ok := f.Type.(FileTypeLink); ok {
+			t.links[link.Target] = f
+		}
+	}
+	return t
+}
+
+// LoadTree returns a tree of all the user's files.
+func LoadTree(core Core) (*Tree, error) {
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return nil, fmt.Errorf("listing metadatas: %w", err)
+	}
+	return NewTree(files), nil
+}
+
+// Len returns the number of files in the tree.
+func (t *Tree) Len() int {
+	return len(t.files)
+}
+
+// Root returns the root folder, if it's in the tree.
+func (t *Tree) Root() (File, bool) {
+	if t.root == nil {
+		return File{}, false
+	}
+	return *t.root, true
+}
+
+// File returns the file with the given ID, if it's in the tree.
+func (t *Tree) File(id FileID) (File, bool) {
+	f, ok := t.files[id]
+	if !ok {
+		return File{}, false
+	}
+	return *f, true
+}
+
+// Children returns the files directly within a folder.
+func (t *Tree) Children(id FileID) []File {
+	children := t.children[id]
+	files := make([]File, len(children))
+	for i, ch := range children {
+		files[i] = *ch
+	}
+	return files
+}
+
+// parent returns the folder a file appears in along with the name it appears under,
+// which is the link's if the file is reached through a link.
+func (t *Tree) parent(f *File) (*File, string, bool) {
+	if link, ok := t.links[f.ID]; ok {
+		p, ok := t.files[link.Parent]
+		return p, link.Name, ok
+	}
+	p, ok := t.files[f.Parent]
+	return p, f.Name, ok
+}
+
+// Ancestors returns the folders containing a file, starting from root. It stops early
+// at the first folder that isn't in the tree.
+func (t *Tree) Ancestors(id FileID) []File {
+	f, ok := t.files[id]
+	if !ok {
+		return nil
+	}
+	var ancestors []File
+	for i := 0; !f.IsRoot() && i < len(t.files); i++ {
+		p, _, ok := t.parent(f)
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, *p)
+		f = p
+	}
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+	return ancestors
+}
+
+// Path returns a file's path. Folder paths end with a slash. It's an error if any of the
+// file's ancestors up to root aren't in the tree.
+func (t *Tree) Path(id FileID) (string, error) {
+	f, ok := t.files[id]
+	if !ok {
+		return "", fmt.Errorf("file %q isn't in the tree", id)
+	}
+	if f.IsRoot() {
+		return "/", nil
+	}
+	var names []string
+	for i := 0; !f.IsRoot(); i++ {
+		p, name, ok := t.parent(f)
+		if !ok || i == len(t.files) {
+			return "", fmt.Errorf("an ancestor of %q isn't in the tree", id)
+		}
+		names = append(names, name)
+		f = p
+	}
+	var b strings.Builder
+	for i := len(names) - 1; i >= 0; i-- {
+		b.WriteByte('/')
+		b.WriteString(names[i])
+	}
+	if t.files[id].IsDir() {
+		b.WriteByte('/')
+	}
+	return b.String(), nil
+}
+
+// ResolveLink is like the package level `ResolveLink` except it only looks within the
+// tree.
+func (t *Tree) ResolveLink(f File) (File, error) {
+	for i := 0; ; i++ {
+		link, ok := f.Type.(FileTypeLink)
+		if !ok {
+			return f, nil
+		}
+		if i == maxLinkDepth {
+			return File{}, fmt.Errorf("too many levels of links resolving %q", f.Name)
+		}
+		target, ok := t.files[link.Target]
+		if !ok {
+			return File{}, &Error{
+				Code: CodeLinkTargetNonexistent,
+				Msg:  fmt.Sprintf("the target of link %q doesn't exist", f.Name),
+			}
+		}
+		f = *target
+	}
+}
+
+// DescribeLink is like the package level `DescribeLink` except it only looks within the
+// tree.
+func (t *Tree) DescribeLink(f File) (LinkTarget, error) {
+	target, err := t.ResolveLink(f)
+	if err != nil {
+		return LinkTarget{}, err
+	}
+	lt := LinkTarget{File: target, Path: target.Name}
+	if p, err := t.Path(target.ID); err == nil {
+		lt.Path = p
+	}
+	if len(target.Shares) > 0 {
+		lt.Owner = target.Shares[0].SharedBy
+	}
+	return lt, nil
+}
+
+// SubtreeSize returns the number of files within a folder, at any depth. Links aren't
+// followed.
+func (t *Tree) SubtreeSize(id FileID) int {
+	n := 0
+	for _, ch := range t.children[id] {
+		n += 1 + t.SubtreeSize(ch.ID)
+	}
+	return n
+}
+
+// lookupPath returns the file at the given path, following links to folders along the
+// way. It reports false if any part of the path isn't in the tree.
+func (t *Tree) lookupPath(p string) (File, bool) {
+	f := t.root
+	if f == nil {
+		return File{}, false
+	}
+	names := strings.Split(strings.Trim(p, "/"), "/")
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		var next *File
+		for _, ch := range t.children[f.ID] {
+			if ch.Name == name {
+				next = ch
+				break
+			}
+		}
+		if next == nil {
+			return File{}, false
+		}
+		if link, ok := next.Type.(FileTypeLink); ok && i < len(names)-1 {
+			if next, ok = t.files[link.Target]; !ok {
+				return File{}, false
+			}
+		}
+		f = next
+	}
+	return *f, true
+}
+
+// put adds a file to the tree or replaces the file with the same ID.
+func (t *Tree) put(f File) {
+	if old, ok := t.files[f.ID]; ok {
+		t.unlink(old)
+	}
+	nf := &f
+	t.files[f.ID] = nf
+	if f.IsRoot() {
+		t.root = nf
+		return
+	}
+	siblings := t.children[f.Parent]
+	i := sort.Search(len(siblings), func(i int) bool { return fileLess(nf, siblings[i]) })
+	siblings = append(siblings, nil)
+	copy(siblings[i+1:], siblings[i:])
+	siblings[i] = nf
+	t.children[f.Parent] = siblings
+	if link, ok := f.Type.(FileTypeLink); ok {
+		t.links[link.Target] = nf
+	}
+}
+
+// remove deletes a file and everything within it from the tree.
+func (t *Tree) remove(id FileID) {
+	f, ok := t.files[id]
+	if !ok {
+		return
+	}
+	for _, ch := range t.children[id] {
+		t.remove(ch.ID)
+	}
+	t.unlink(f)
+	delete(t.files, id)
+	delete(t.children, id)
+}
+
+// unlink removes a file from its parent's children and the link index.
+func (t *Tree) unlink(f *File) {
+	siblings := t.children[f.Parent]
+	for i, sib := range siblings {
+		if sib.ID == f.ID {
+			t.children[f.Parent] = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if link, ok := f.Type.(FileTypeLink); ok && t.links[link.Target] == f {
+		delete(t.links, link.Target)
+	}
+}
+
+// SkipDir can be returned by a `WalkFunc` to skip the contents of the folder it was
+// called with.
+var SkipDir = errors.New("skip this folder")
+
+// WalkFunc is called by `Walk` for each file. Folder paths end with a slash. When a link
+// is followed, `f` is its target but the path is still the link's. If a link can't be
+// followed, `f` is the link itself and `err` says why.
+type WalkFunc func(path string, f File, err error) error
+
+// WalkOptions configures `Walk`.
+type WalkOptions struct {
+	// Depth limits how many levels below the starting file are visited. Zero means there's
+	// no limit.
+	Depth int
+	// FollowLinks makes `Walk` treat links as the files they point to, descending into
+	// linked folders.
+	FollowLinks bool
+}
+
+// Walk calls `fn` for the file with the given ID and everything within it (pre-order,
+// with siblings in `SortFiles` order). Returning `SkipDir` from `fn` for a folder skips
+// its contents, and returning any other error stops the walk with that error.
+func (t *Tree) Walk(id FileID, opts WalkOptions, fn WalkFunc) error {
+	f, ok := t.files[id]
+	if !ok {
+		return fmt.Errorf("file %q isn't in the tree", id)
+	}
+	p, err := t.Path(id)
+	if err != nil {
+		return err
+	}
+	w := walker{tree: t, opts: opts, fn: fn, ancestors: make(map[FileID]bool)}
+	err = w.walk(p, *f, 0)
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+// Walk loads the tree of all the user's files and walks it from the given file. See
+// `Tree.Walk`.
+func Walk(core Core, id FileID, opts WalkOptions, fn WalkFunc) error {
+	t, err := LoadTree(core)
+	if err != nil {
+		return err
+	}
+	return t.Walk(id, opts, fn)
+}
+
+type walker struct {
+	tree      *Tree
+	opts      WalkOptions
+	fn        WalkFunc
+	ancestors map[FileID]bool
+}
+
+func (w *walker) walk(p string, f File, depth int) error {
+	if _, isLink := f.Type.(FileTypeLink); isLink && w.opts.FollowLinks {
+		target, err := w.tree.ResolveLink(f)
+		if err != nil {
+			return w.fn(p, f, err)
+		}
+		f = target
+		if f.IsDir() && !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+	}
+	if err := w.fn(p, f, nil); err != nil || !f.IsDir() {
+		return err
+	}
+	if w.opts.Depth > 0 && depth == w.opts.Depth {
+		return nil
+	}
+	// Links can make a folder reachable from within itself.
+	if w.ancestors[f.ID] {
+		return nil
+	}
+	w.ancestors[f.ID] = true
+	defer delete(w.ancestors, f.ID)
+
+	for _, ch := range w.tree.children[f.ID] {
+		chPath := p + ch.Name
+		if ch.IsDir() {
+			chPath += "/"
+		}
+		if err := w.walk(chPath, *ch, depth+1); err != nil && err != SkipDir {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package lockbook
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// testTree holds the files for a tree being built up by a test.
+type testTree struct {
+	root  FileID
+	files []File
+}
+
+func newTestTree() *testTree {
+	id := uuid.Must(uuid.NewV4())
+	return &testTree{
+		root:  id,
+		files: []File{{ID: id, Parent: id, Name: "me", Type: FileTypeFolder{}}},
+	}
+}
+
+func (tt *testTree) add(parent FileID, name string, typ FileType) FileID {
+	id := uuid.Must(uuid.NewV4())
+	tt.files = append(tt.files, File{ID: id, Parent: parent, Name: name, Type: typ})
+	return id
+}
+
+func (tt *testTree) dir(parent FileID, name string) FileID {
+	return tt.add(parent, name, FileTypeFolder{})
+}
+
+func (tt *testTree) doc(parent FileID, name string) FileID {
+	return tt.add(parent, name, FileTypeDocument{})
+}
+
+func (tt *testTree) link(parent FileID, name string, target FileID) FileID {
+	return tt.add(parent, name, FileTypeLink{Target: target})
+}
+
+func TestTreePaths(t *testing.T) {
+	tt := newTestTree()
+	notes := tt.dir(tt.root, "notes")
+	old := tt.dir(notes, "old")
+	b := tt.doc(notes, "b.md")
+	a := tt.doc(notes, "a.md")
+	d := tt.doc(old, "d.md")
+	// A folder shared by someone else is outside of the user's tree, and it appears
+	// wherever the user's link to it is.
+	shared := tt.dir(uuid.Must(uuid.NewV4()), "their-folder")
+	s := tt.doc(shared, "s.md")
+	tt.link(notes, "from-them", shared)
+	tree := NewTree(tt.files)
+
+	if n := tree.Len(); n != len(tt.files) {
+		t.Errorf("Len() = %d, want %d", n, len(tt.files))
+	}
+	paths := map[FileID]string{
+		tt.root: "/",
+		notes:   "/notes/",
+		old:     "/notes/old/",
+		a:       "/notes/a.md",
+		d:       "/notes/old/d.md",
+		shared:  "/notes/from-them/",
+		s:       "/notes/from-them/s.md",
+	}
+	for id, want := range paths {
+		got, err := tree.Path(id)
+		if err != nil {
+			t.Errorf("Path(%s): %v", want, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Path(%s) = %q", want, got)
+		}
+	}
+	if _, err := tree.Path(uuid.Must(uuid.NewV4())); err == nil {
+		t.Error("Path of a file not in the tree: expected an error")
+	}
+
+	var names []string
+	for _, f := range tree.Children(notes) {
+		names = append(names, f.Name)
+	}
+	// Folders come first, then everything else by name.
+	if want := []string{"old", "a.md", "b.md", "from-them"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Children(notes) = %q, want %q", names, want)
+	}
+	names = nil
+	for _, f := range tree.Ancestors(s) {
+		names = append(names, f.Name)
+	}
+	if want := []string{"me", "notes", "their-folder"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Ancestors(s) = %q, want %q", names, want)
+	}
+	if n := tree.SubtreeSize(notes); n != 5 {
+		t.Errorf("SubtreeSize(notes) = %d, want 5", n)
+	}
+
+	for p, want := range map[string]FileID{
+		"/notes/old/d.md":       d,
+		"notes/b.md":            b,
+		"/notes/from-them/s.md": s,
+		"/":                     tt.root,
+	} {
+		f, ok := tree.lookupPath(p)
+		if !ok || f.ID != want {
+			t.Errorf("lookupPath(%q) = %v, %t", p, f.ID, ok)
+		}
+	}
+	if _, ok := tree.lookupPath("/notes/nope.md"); ok {
+		t.Error("lookupPath of a missing file: expected false")
+	}
+}
+
+func TestTreeResolveLink(t *testing.T) {
+	tt := newTestTree()
+	doc := tt.doc(tt.root, "doc.md")
+	l1 := tt.link(tt.root, "l1", doc)
+	l2 := tt.link(tt.root, "l2", l1)
+	dangling := tt.link(tt.root, "dangling", uuid.Must(uuid.NewV4()))
+	tree := NewTree(tt.files)
+
+	f, _ := tree.File(l2)
+	target, err := tree.ResolveLink(f)
+	if err != nil || target.ID != doc {
+		t.Errorf("ResolveLink(l2) = %v, %v; want doc.md", target.Name, err)
+	}
+	f, _ = tree.File(dangling)
+	_, err = tree.ResolveLink(f)
+	var lbErr *Error
+	if !errors.As(err, &lbErr) || lbErr.Code != CodeLinkTargetNonexistent {
+		t.Errorf("ResolveLink(dangling) = %v, want CodeLinkTargetNonexistent", err)
+	}
+}
+
+func TestTreePutRemove(t *testing.T) {
+	tt := newTestTree()
+	notes := tt.dir(tt.root, "notes")
+	a := tt.doc(notes, "a.md")
+	tree := NewTree(tt.files)
+
+	c := File{ID: uuid.Must(uuid.NewV4()), Parent: notes, Name: "c.md", Type: FileTypeDocument{}}
+	tree.put(c)
+	tree.put(File{ID: uuid.Must(uuid.NewV4()), Parent: notes, Name: "b.md", Type: FileTypeDocument{}})
+	var names []string
+	for _, f := range tree.Children(notes) {
+		names = append(names, f.Name)
+	}
+	if want := []string{"a.md", "b.md", "c.md"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("children after put = %q, want %q", names, want)
+	}
+	// Putting an existing file again moves it.
+	c.Parent = tt.root
+	tree.put(c)
+	if p, _ := tree.Path(c.ID); p != "/c.md" {
+		t.Errorf("Path after moving = %q, want /c.md", p)
+	}
+	tree.remove(notes)
+	if _, ok := tree.File(a); ok {
+		t.Error("a.md is still in the tree after removing its folder")
+	}
+	if n := tree.SubtreeSize(tt.root); n != 1 {
+		t.Errorf("SubtreeSize(root) after remove = %d, want 1", n)
+	}
+}
+
+func TestTreeWalk(t *testing.T) {
+	tt := newTestTree()
+	notes := tt.dir(tt.root, "notes")
+	old := tt.dir(notes, "old")
+	tt.doc(old, "d.md")
+	tt.doc(notes, "a.md")
+	work := tt.dir(uuid.Must(uuid.NewV4()), "work")
+	tt.doc(work, "w.md")
+	tt.link(notes, "to-work", work)
+	// A link to one of its own ancestors.
+	tt.link(old, "up", tt.root)
+	tt.link(tt.root, "dangling", uuid.Must(uuid.NewV4()))
+	tree := NewTree(tt.files)
+
+	walk := func(id FileID, opts WalkOptions, skip string) []string {
+		t.Helper()
+		var visited []string
+		err := tree.Walk(id, opts, func(p string, f File, err error) error {
+			if err != nil {
+				p += " (error)"
+			}
+			visited = append(visited, p)
+			if p == skip {
+				return SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk: %v", err)
+		}
+		return visited
+	}
+
+	tests := []struct {
+		name string
+		id   FileID
+		opts WalkOptions
+		skip string
+		want []string
+	}{
+		{
+			name: "all",
+			id:   tt.root,
+			want: []string{
+				"/", "/notes/", "/notes/old/", "/notes/old/d.md", "/notes/old/up",
+				"/notes/a.md", "/notes/to-work", "/dangling",
+			},
+		},
+		{
+			name: "depth",
+			id:   tt.root,
+			opts: WalkOptions{Depth: 1},
+			want: []string{"/", "/notes/", "/dangling"},
+		},
+		{
+			name: "skip",
+			id:   notes,
+			skip: "/notes/old/",
+			want: []string{"/notes/", "/notes/old/", "/notes/a.md", "/notes/to-work"},
+		},
+		{
+			name: "follow links",
+			id:   tt.root,
+			opts: WalkOptions{FollowLinks: true},
+			want: []string{
+				"/", "/notes/", "/notes/old/", "/notes/old/d.md",
+				// The link back up is visited but not descended into again.
+				"/notes/old/up/",
+				"/notes/a.md", "/notes/to-work/", "/notes/to-work/w.md",
+				"/dangling (error)",
+			},
+		},
+		{
+			name: "from a linked folder",
+			id:   work,
+			want: []string{"/notes/to-work/", "/notes/to-work/w.md"},
+		},
+	}
+	for _, tc := range tests {
+		if got := walk(tc.id, tc.opts, tc.skip); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: visited %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err := tree.Walk(tt.root, WalkOptions{}, func(string, File, error) error {
+		n++
+		if n == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || n != 3 {
+		t.Errorf("Walk returning an error stopped after %d files with %v", n, err)
+	}
+}
@@ -0,0 +1,96 @@
+package lockbook
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UsageNode is a file along with the storage it uses. For folders, `Size` includes
+// everything within the folder.
+type UsageNode struct {
+	File     File
+	Path     string
+	Size     uint64
+	Children []*UsageNode
+}
+
+// UsageTree aggregates the per-file usage reported by `GetUsage` up the folder hierarchy
+// starting at the given file. Children are sorted from largest to smallest.
+func UsageTree(core Core, id FileID) (*UsageNode, error) {
+	u, err := core.GetUsage()
+	if err != nil {
+		return nil, fmt.Errorf("getting usage: %w", err)
+	}
+	start, err := core.FileByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("file by id %q: %w", id, err)
+	}
+	startPath, err := core.PathByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("path by id %q: %w", id, err)
+	}
+	files, err := core.GetAndGetChildrenRecursively(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting files under %q: %w", startPath, err)
+	}
+	return AggregateUsage(start, startPath, files, u.Usages), nil
+}
+
+// AggregateUsage builds the usage tree of the start file (at `startPath`) out of the
+// files within it and the per-file usage. Files without any reported usage count as
+// zero bytes.
+func AggregateUsage(start File, startPath string, files []File, usages []FileUsage) *UsageNode {
+	sizes := make(map[FileID]uint64, len(usages))
+	for _, fu := range usages {
+		sizes[fu.FileID] = fu.SizeBytes
+	}
+	children := make(map[FileID][]File, len(files))
+	for _, f := range files {
+		if f.ID != start.ID {
+			children[f.Parent] = append(children[f.Parent], f)
+		}
+	}
+	var build func(f File, p string) *UsageNode
+	build = func(f File, p string) *UsageNode {
+		n := &UsageNode{File: f, Path: p, Size: sizes[f.ID]}
+		for _, ch := range children[f.ID] {
+			chPath := p + ch.Name
+			if ch.IsDir() {
+				chPath += "/"
+			}
+			chNode := build(ch, chPath)
+			n.Size += chNode.Size
+			n.Children = append(n.Children, chNode)
+		}
+		sort.Slice(n.Children, func(i, j int) bool {
+			a, b := n.Children[i], n.Children[j]
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+			return a.Path < b.Path
+		})
+		return n
+	}
+	return build(start, startPath)
+}
+
+// Largest returns up to `num` of the largest documents within the node, from largest to
+// smallest.
+func (n *UsageNode) Largest(num int) []*UsageNode {
+	var docs []*UsageNode
+	var walk func(n *UsageNode)
+	walk = func(n *UsageNode) {
+		if !n.File.IsDir() {
+			docs = append(docs, n)
+		}
+		for _, ch := range n.Children {
+			walk(ch)
+		}
+	}
+	walk(n)
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].Size > docs[j].Size })
+	if num >= 0 && len(docs) > num {
+		docs = docs[:num]
+	}
+	return docs
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+const (
+	backupPassphraseEnv = "LOCKBOOK_BACKUP_PASSPHRASE"
+	backupRepoEnv       = "LOCKBOOK_BACKUP_REPO"
+	snapshotIDLayout    = "20060102T150405.000Z"
+)
+
+// Encrypted local backups and point-in-time restore.
+//
+// Backups are stored in a repository directory which holds deduplicated, encrypted
+// document contents and a snapshot of all file metadata for each backup. The passphrase
+// is read from $LOCKBOOK_BACKUP_PASSPHRASE or prompted for.
+type backupCmd struct {
+	create  *backupCreateCmd
+	list    *backupListCmd
+	diff    *backupDiffCmd
+	restore *backupRestoreCmd
+}
+
+func (b *backupCmd) run(core lockbook.Core) error {
+	switch {
+	case b.create != nil:
+		return b.create.run(core)
+	case b.list != nil:
+		return b.list.run()
+	case b.diff != nil:
+		return b.diff.run(core)
+	case b.restore != nil:
+		return b.restore.run(core)
+	default:
+		return nil
+	}
+}
+
+// Snapshot all files into a backup repository (which is created if needed).
+type backupCreateCmd struct {
+	// Don't list each newly stored document.
+	//
+	// clap:opt quiet,q
+	quiet bool
+	// The backup repository directory.
+	//
+	// clap:arg_required
+	dir string
+}
+
+func (c *backupCreateCmd) run(core lockbook.Core) error {
+	var r *backupRepo
+	if _, err := os.Stat(backupConfigPath(c.dir)); err == nil {
+		if r, err = openBackupRepoPrompt(c.dir); err != nil {
+			return err
+		}
+	} else {
+		pass, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+		if r, err = initBackupRepo(c.dir, pass); err != nil {
+			return fmt.Errorf("creating repository: %w", err)
+		}
+		fmt.Printf("created backup repository at %s\n", c.dir)
+	}
+
+	acct, err := core.GetAccount()
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return fmt.Errorf("listing metadatas: %w", err)
+	}
+	// Documents that haven't changed since the last snapshot don't need to be read.
+	prev := map[lockbook.FileID]snapFile{}
+	if ids, err := r.snapshotIDs(); err == nil && len(ids) > 0 {
+		last, err := r.getSnapshot(ids[len(ids)-1])
+		if err != nil {
+			return err
+		}
+		for _, f := range last.Files {
+			prev[f.ID] = f
+		}
+	}
+
+	now := time.Now().UTC()
+	s := &snapshot{
+		ID:       now.Format(snapshotIDLayout),
+		Time:     now,
+		Username: acct.Username,
+		Files:    make([]snapFile, 0, len(files)),
+	}
+	var newIDs []lockbook.FileID
+	for i := range files {
+		f := &files[i]
+		sf := snapFile{ID: f.ID, Parent: f.Parent, Name: f.Name, Lastmod: f.Lastmod}
+		switch typ := f.Type.(type) {
+		case lockbook.FileTypeFolder:
+			sf.Type = snapTypeFolder
+		case lockbook.FileTypeLink:
+			sf.Type = snapTypeLink
+			sf.LinkTarget = typ.Target
+		case lockbook.FileTypeDocument:
+			sf.Type = snapTypeDocument
+			if p, ok := prev[f.ID]; ok && p.Lastmod.Equal(f.Lastmod) && r.hasObject(p.Object) {
+				sf.Object, sf.Size = p.Object, p.Size
+				break
+			}
+			data, err := core.ReadDocument(f.ID)
+			if err != nil {
+				return fmt.Errorf("reading doc %q: %w", f.ID, err)
+			}
+			var isNew bool
+			sf.Object, isNew, err = r.putObject(data)
+			if err != nil {
+				return fmt.Errorf("storing doc %q: %w", f.ID, err)
+			}
+			sf.Size = int64(len(data))
+			if isNew {
+				s.Stats.NumNew++
+				s.Stats.BytesNew += sf.Size
+				newIDs = append(newIDs, f.ID)
+			}
+		}
+		if sf.Type == snapTypeDocument {
+			s.Stats.NumDocs++
+			s.Stats.BytesTotal += sf.Size
+		}
+		s.Files = append(s.Files, sf)
+	}
+	if err := r.putSnapshot(s); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if !c.quiet {
+		paths := snapPaths(s.Files)
+		for _, id := range newIDs {
+			fmt.Printf("+ %s\n", paths[id])
+		}
+	}
+	fmt.Printf("snapshot %s: %d documents (%d new, %s)\n",
+		s.ID, s.Stats.NumDocs, s.Stats.NumNew, humanBytes(s.Stats.BytesNew))
+	return nil
+}
+
+// List the snapshots in a backup repository.
+type backupListCmd struct {
+	// The backup repository directory (default $LOCKBOOK_BACKUP_REPO).
+	//
+	// clap:opt repo,r
+	repo string
+}
+
+func (c *backupListCmd) run() error {
+	r, err := openBackupRepoPrompt(backupRepoDir(c.repo))
+	if err != nil {
+		return err
+	}
+	ids, err := r.snapshotIDs()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "snapshot\ttime\tdocuments\tsize\tnew")
+	for _, id := range ids {
+		s, err := r.getSnapshot(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d (%s)\n", s.ID, s.Time.Local().Format("2006-01-02 15:04:05"),
+			s.Stats.NumDocs, humanBytes(s.Stats.BytesTotal), s.Stats.NumNew, humanBytes(s.Stats.BytesNew))
+	}
+	return tw.Flush()
+}
+
+// Show what changed between a snapshot and the current files (or another snapshot).
+//
+// Each line is marked as added (A), deleted (D), modified (M) or renamed / moved (R).
+type backupDiffCmd struct {
+	// The backup repository directory (default $LOCKBOOK_BACKUP_REPO).
+	//
+	// clap:opt repo,r
+	repo string
+	// The snapshot ID, ID prefix or "latest".
+	//
+	// clap:arg_required
+	snap string
+	// A later snapshot to compare against instead of the current files.
+	other string
+}
+
+func (c *backupDiffCmd) run(core lockbook.Core) error {
+	r, err := openBackupRepoPrompt(backupRepoDir(c.repo))
+	if err != nil {
+		return err
+	}
+	a, err := r.findSnapshot(c.snap)
+	if err != nil {
+		return err
+	}
+	var b *snapshot
+	if c.other != "" {
+		if b, err = r.findSnapshot(c.other); err != nil {
+			return err
+		}
+	} else if b, err = currentSnapshot(core, r, a); err != nil {
+		return err
+	}
+	for _, line := range diffSnapshots(a, b) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// currentSnapshot builds a snapshot of the current files without storing anything.
+// Documents unchanged since `base` reuse its object IDs instead of being read.
+func currentSnapshot(core lockbook.Core, r *backupRepo, base *snapshot) (*snapshot, error) {
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return nil, fmt.Errorf("listing metadatas: %w", err)
+	}
+	prev := make(map[lockbook.FileID]*snapFile, len(base.Files))
+	for i := range base.Files {
+		prev[base.Files[i].ID] = &base.Files[i]
+	}
+	s := &snapshot{Files: make([]snapFile, len(files))}
+	for i := range files {
+		f := &files[i]
+		sf := snapFile{ID: f.ID, Parent: f.Parent, Name: f.Name, Lastmod: f.Lastmod, Type: snapTypeLink}
+		switch f.Type.(type) {
+		case lockbook.FileTypeFolder:
+			sf.Type = snapTypeFolder
+		case lockbook.FileTypeDocument:
+			sf.Type = snapTypeDocument
+			if p, ok := prev[f.ID]; ok && p.Lastmod.Equal(f.Lastmod) {
+				sf.Object = p.Object
+				break
+			}
+			data, err := core.ReadDocument(f.ID)
+			if err != nil {
+				return nil, fmt.Errorf("reading doc %q: %w", f.ID, err)
+			}
+			sf.Object = r.objectID(data)
+		}
+		s.Files[i] = sf
+	}
+	return s, nil
+}
+
+func diffSnapshots(a, b *snapshot) []string {
+	aPaths, bPaths := snapPaths(a.Files), snapPaths(b.Files)
+	aFiles := make(map[lockbook.FileID]*snapFile, len(a.Files))
+	for i := range a.Files {
+		aFiles[a.Files[i].ID] = &a.Files[i]
+	}
+	type change struct{ path, line string }
+	var changes []change
+	seen := make(map[lockbook.FileID]bool, len(b.Files))
+	for i := range b.Files {
+		bf := &b.Files[i]
+		seen[bf.ID] = true
+		bp := bPaths[bf.ID]
+		af, ok := aFiles[bf.ID]
+		if !ok {
+			changes = append(changes, change{bp, "A " + bp})
+			continue
+		}
+		// Files within a renamed or moved folder aren't reported themselves.
+		if af.Name != bf.Name || af.Parent != bf.Parent {
+			changes = append(changes, change{bp, "R " + aPaths[af.ID] + " -> " + bp})
+		}
+		if af.Object != bf.Object {
+			changes = append(changes, change{bp, "M " + bp})
+		}
+	}
+	for i := range a.Files {
+		if af := &a.Files[i]; !seen[af.ID] {
+			ap := aPaths[af.ID]
+			changes = append(changes, change{ap, "D " + ap})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	lines := make([]string, len(changes))
+	for i := range changes {
+		lines[i] = changes[i].line
+	}
+	return lines
+}
+
+// Restore files from a snapshot into lockbook or onto disk.
+//
+// The target path (default "/") is restored within the destination along with
+// everything in it. Existing documents at the same paths are overwritten.
+type backupRestoreCmd struct {
+	// The backup repository directory (default $LOCKBOOK_BACKUP_REPO).
+	//
+	// clap:opt repo,r
+	repo string
+	// The lockbook folder path or ID to restore into (or a directory with --disk).
+	//
+	// clap:opt to
+	to string
+	// Restore onto disk instead of into lockbook.
+	//
+	// clap:opt disk
+	toDisk bool
+	// The snapshot ID, ID prefix or "latest".
+	//
+	// clap:arg_required
+	snap string
+	// The lockbook path within the snapshot to restore.
+	path string
+}
+
+// restoreEntry is a file to be restored along with its path relative to the
+// destination.
+type restoreEntry struct {
+	rel  string
+	file *snapFile
+}
+
+func (c *backupRestoreCmd) run(core lockbook.Core) error {
+	if c.to == "" {
+		return errors.New("a destination is required (--to)")
+	}
+	r, err := openBackupRepoPrompt(backupRepoDir(c.repo))
+	if err != nil {
+		return err
+	}
+	s, err := r.findSnapshot(c.snap)
+	if err != nil {
+		return err
+	}
+	entries, links, err := selectRestoreEntries(s, c.path)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		fmt.Fprintf(os.Stderr, "skipping link %s (links can't be restored)\n", l)
+	}
+	if c.toDisk {
+		return restoreToDisk(r, entries, c.to)
+	}
+	destID, err := idFromSomething(core, c.to)
+	if err != nil {
+		return fmt.Errorf("trying to get an id from %q: %w", c.to, err)
+	}
+	dest, err := core.FileByID(destID)
+	if err != nil {
+		return fmt.Errorf("file by id %q: %w", destID, err)
+	}
+	if !dest.IsDir() {
+		return fmt.Errorf("%s is not a folder", c.to)
+	}
+	return restoreToLockbook(core, r, entries, destID)
+}
+
+// selectRestoreEntries returns the file at the given path and everything in it with
+// paths relative to the file's parent, parents first. If it's root, only its contents
+// are selected. Links are left out, and their paths are returned separately.
+func selectRestoreEntries(s *snapshot, target string) ([]restoreEntry, []string, error) {
+	if target == "" {
+		target = "/"
+	}
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	paths := snapPaths(s.Files)
+	var base, prefix string
+	for i := range s.Files {
+		p := paths[s.Files[i].ID]
+		if p == target || p == target+"/" {
+			prefix = p
+			base = path.Dir(strings.TrimSuffix(p, "/"))
+			break
+		}
+	}
+	if prefix == "" {
+		return nil, nil, fmt.Errorf("%s isn't in snapshot %s", target, s.ID)
+	}
+	base = strings.TrimSuffix(base, "/") + "/"
+	if prefix == "/" {
+		base = "/"
+	}
+	var entries []restoreEntry
+	var links []string
+	for i := range s.Files {
+		f := &s.Files[i]
+		p := paths[f.ID]
+		if p == "/" || !(p == prefix || strings.HasPrefix(p, prefix) && strings.HasSuffix(prefix, "/")) {
+			continue
+		}
+		if f.Type == snapTypeLink {
+			links = append(links, p)
+			continue
+		}
+		entries = append(entries, restoreEntry{rel: strings.TrimSuffix(strings.TrimPrefix(p, base), "/"), file: f})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+	sort.Strings(links)
+	return entries, links, nil
+}
+
+func restoreToDisk(r *backupRepo, entries []restoreEntry, dir string) error {
+	for _, e := range entries {
+		fpath := filepath.Join(dir, filepath.FromSlash(e.rel))
+		if e.file.isDir() {
+			if err := os.MkdirAll(fpath, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := r.getObject(e.file.Object)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(fpath), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(fpath, data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Chtimes(fpath, e.file.Lastmod, e.file.Lastmod); err != nil {
+			return err
+		}
+		fmt.Println(fpath)
+	}
+	return nil
+}
+
+func restoreToLockbook(core lockbook.Core, r *backupRepo, entries []restoreEntry, destID lockbook.FileID) error {
+	dirIDs := map[string]lockbook.FileID{".": destID}
+	for _, e := range entries {
+		parentID, ok := dirIDs[path.Dir(e.rel)]
+		if !ok {
+			return fmt.Errorf("restoring %s: parent folder wasn't restored", e.rel)
+		}
+		var typ lockbook.FileType = lockbook.FileTypeDocument{}
+		if e.file.isDir() {
+			typ = lockbook.FileTypeFolder{}
+		}
+		f, err := createOrFindChild(core, parentID, e.file.Name, typ)
+		if err != nil {
+			return fmt.Errorf("restoring %s: %w", e.rel, err)
+		}
+		if e.file.isDir() {
+			dirIDs[e.rel] = f.ID
+			continue
+		}
+		data, err := r.getObject(e.file.Object)
+		if err != nil {
+			return err
+		}
+		if err := core.WriteDocument(f.ID, data); err != nil {
+			return fmt.Errorf("restoring %s: writing doc: %w", e.rel, err)
+		}
+		fmt.Println(e.rel)
+	}
+	return nil
+}
+
+// createOrFindChild creates a file or returns the existing one if the name is taken by a
+// file of the same type.
+func createOrFindChild(core lockbook.Core, parentID lockbook.FileID, name string, typ lockbook.FileType) (lockbook.File, error) {
+	f, err := core.CreateFile(name, parentID, typ)
+	if err == nil {
+		return f, nil
+	}
+	if lberr, ok := asLbErr(err); !ok || lberr.Code != lockbook.CodePathTaken {
+		return lockbook.File{}, err
+	}
+	children, err := core.GetChildren(parentID)
+	if err != nil {
+		return lockbook.File{}, fmt.Errorf("getting children: %w", err)
+	}
+	_, wantDir := typ.(lockbook.FileTypeFolder)
+	for i := range children {
+		ch := &children[i]
+		if ch.Name == name && ch.IsDir() == wantDir {
+			return *ch, nil
+		}
+	}
+	return lockbook.File{}, fmt.Errorf("%q already exists as a different type of file", name)
+}
+
+func backupRepoDir(v string) string {
+	if v != "" {
+		return v
+	}
+	return os.Getenv(backupRepoEnv)
+}
+
+func openBackupRepoPrompt(dir string) (*backupRepo, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no backup repository (use --repo or set $%s)", backupRepoEnv)
+	}
+	pass, err := readPassphrase("backup passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	return openBackupRepo(dir, pass)
+}
+
+func readNewPassphrase() ([]byte, error) {
+	pass, err := readPassphrase("new backup passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if os.Getenv(backupPassphraseEnv) != "" {
+		return pass, nil
+	}
+	again, err := readPassphrase("confirm passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(pass) != string(again) {
+		return nil, errors.New("passphrases don't match")
+	}
+	return pass, nil
+}
+
+// readPassphrase returns $LOCKBOOK_BACKUP_PASSPHRASE or prompts for a passphrase without
+// echoing it (when possible).
+func readPassphrase(prompt string) ([]byte, error) {
+	if v := os.Getenv(backupPassphraseEnv); v != "" {
+		return []byte(v), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	stty := exec.Command("stty", "-echo")
+	stty.Stdin = os.Stdin
+	if err := stty.Run(); err == nil {
+		defer func() {
+			stty := exec.Command("stty", "echo")
+			stty.Stdin = os.Stdin
+			_ = stty.Run()
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	scnr := bufio.NewScanner(os.Stdin)
+	if !scnr.Scan() {
+		if err := scnr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no passphrase provided")
+	}
+	pass := scnr.Bytes()
+	if len(pass) == 0 {
+		return nil, errors.New("the passphrase can't be empty")
+	}
+	return append([]byte(nil), pass...), nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	r, err := initBackupRepo(dir, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids [7]lockbook.FileID
+	for i := range ids {
+		ids[i] = uuid.Must(uuid.NewV4())
+	}
+	root, dir1, doc1, doc2, doc3, link, elsewhere := ids[0], ids[1], ids[2], ids[3], ids[4], ids[5], ids[6]
+	docs := map[lockbook.FileID][]byte{
+		doc1: []byte("# notes\n"),
+		doc2: []byte(strings.Repeat("same content\n", 100)),
+		doc3: []byte(strings.Repeat("same content\n", 100)),
+	}
+	lastmod := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	s := &snapshot{
+		ID:   lastmod.Format(snapshotIDLayout),
+		Time: lastmod,
+		Files: []snapFile{
+			{ID: root, Parent: root, Name: "user", Type: snapTypeFolder},
+			{ID: dir1, Parent: root, Name: "dir", Type: snapTypeFolder},
+			{ID: doc1, Parent: root, Name: "a.md", Type: snapTypeDocument, Lastmod: lastmod},
+			{ID: doc2, Parent: dir1, Name: "b.md", Type: snapTypeDocument, Lastmod: lastmod},
+			{ID: doc3, Parent: dir1, Name: "c.md", Type: snapTypeDocument, Lastmod: lastmod},
+			{ID: link, Parent: dir1, Name: "shared.md", Type: snapTypeLink, LinkTarget: elsewhere},
+		},
+	}
+	numNew := 0
+	for i := range s.Files {
+		f := &s.Files[i]
+		if f.Type != snapTypeDocument {
+			continue
+		}
+		var isNew bool
+		if f.Object, isNew, err = r.putObject(docs[f.ID]); err != nil {
+			t.Fatal(err)
+		}
+		if isNew {
+			numNew++
+		}
+	}
+	if numNew != 2 {
+		t.Errorf("stored %d new objects, want 2 (identical documents should share one)", numNew)
+	}
+	if err := r.putSnapshot(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.putSnapshot(s); err == nil {
+		t.Error("overwrote an existing snapshot")
+	}
+
+	// Nothing should be stored in the clear.
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "content") || strings.Contains(string(data), "b.md") {
+			t.Errorf("%s holds plaintext", p)
+		}
+		return nil
+	})
+
+	r, err = openBackupRepo(dir, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.findSnapshot("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Fatalf("got snapshot %+v, want %+v", got, s)
+	}
+	entries, links, err := selectRestoreEntries(got, "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, e := range entries {
+		rels = append(rels, e.rel)
+	}
+	if want := []string{"dir", "dir/b.md", "dir/c.md"}; !reflect.DeepEqual(rels, want) {
+		t.Errorf("restoring /dir selects %q, want %q", rels, want)
+	}
+	if want := []string{"/dir/shared.md"}; !reflect.DeepEqual(links, want) {
+		t.Errorf("restoring /dir skips links %q, want %q", links, want)
+	}
+
+	out := t.TempDir()
+	entries, _, err = selectRestoreEntries(got, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreToDisk(r, entries, out); err != nil {
+		t.Fatal(err)
+	}
+	for id, rel := range map[lockbook.FileID]string{doc1: "a.md", doc2: "dir/b.md", doc3: "dir/c.md"} {
+		fpath := filepath.Join(out, filepath.FromSlash(rel))
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(docs[id]) {
+			t.Errorf("restored %s as %q, want %q", rel, data, docs[id])
+		}
+		if info, err := os.Stat(fpath); err != nil || !info.ModTime().Equal(lastmod) {
+			t.Errorf("restored %s isn't dated %s", rel, lastmod)
+		}
+	}
+}
+
+func TestBackupWrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := initBackupRepo(dir, []byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openBackupRepo(dir, []byte("hunter3")); err == nil || err.Error() != "wrong passphrase" {
+		t.Errorf("opening with the wrong passphrase: got error %v", err)
+	}
+	if _, err := initBackupRepo(dir, []byte("hunter3")); err == nil {
+		t.Error("initialized a repository on top of an existing one")
+	}
+}
+
+func TestBackupCorruptedObjects(t *testing.T) {
+	r, err := initBackupRepo(t.TempDir(), []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idA, _, err := r.putObject([]byte("document a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, _, err := r.putObject([]byte("document b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedA, err := os.ReadFile(r.objectPath(idA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An object stored under another object's ID doesn't decrypt even though it's intact.
+	if err := os.WriteFile(r.objectPath(idB), sealedA, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := r.getObject(idB); err == nil {
+		t.Errorf("object %s swapped for %s was read as %q", idB, idA, data)
+	}
+
+	// Flipping any bit is caught.
+	for _, i := range []int{0, len(sealedA) / 2, len(sealedA) - 1} {
+		corrupt := append([]byte(nil), sealedA...)
+		corrupt[i] ^= 1
+		if err := os.WriteFile(r.objectPath(idA), corrupt, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := r.getObject(idA); err == nil {
+			t.Errorf("object with byte %d corrupted was read as %q", i, data)
+		}
+	}
+	if err := os.WriteFile(r.objectPath(idA), sealedA[:4], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.getObject(idA); err == nil {
+		t.Error("truncated object was read")
+	}
+}
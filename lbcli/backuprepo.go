@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// A backup repository is a directory with the following layout. Everything except the
+// config is encrypted with a key derived from a passphrase.
+//
+//	config.json          KDF parameters and a value to check the passphrase against
+//	objects/ab/abcd...   document contents, named by a keyed hash of the plaintext
+//	snapshots/<id>       the metadata of every file at a point in time
+const (
+	backupRepoVersion   = 1
+	backupKDFIterations = 600_000
+	backupCheckValue    = "lockbook-backup"
+)
+
+type backupConfig struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      []byte `json:"check"`
+}
+
+type backupRepo struct {
+	dir    string
+	aead   cipher.AEAD
+	idKey  []byte
+	cached map[string]bool
+}
+
+type snapshot struct {
+	ID       string        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Username string        `json:"username"`
+	Files    []snapFile    `json:"files"`
+	Stats    snapshotStats `json:"stats"`
+}
+
+type snapshotStats struct {
+	NumDocs    int   `json:"num_docs"`
+	NumNew     int   `json:"num_new"`
+	BytesTotal int64 `json:"bytes_total"`
+	BytesNew   int64 `json:"bytes_new"`
+}
+
+type snapFile struct {
+	ID         lockbook.FileID `json:"id"`
+	Parent     lockbook.FileID `json:"parent"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	LinkTarget lockbook.FileID `json:"link_target,omitempty"`
+	Lastmod    time.Time       `json:"lastmod"`
+	Object     string          `json:"object,omitempty"`
+	Size       int64           `json:"size,omitempty"`
+}
+
+const (
+	snapTypeDocument = "document"
+	snapTypeFolder   = "folder"
+	snapTypeLink     = "link"
+)
+
+func (f *snapFile) isDir() bool { return f.Type == snapTypeFolder }
+
+func backupConfigPath(dir string) string { return filepath.Join(dir, "config.json") }
+
+// initBackupRepo creates a new, empty repository in the given directory.
+func initBackupRepo(dir string, passphrase []byte) (*backupRepo, error) {
+	if _, err := os.Stat(backupConfigPath(dir)); err == nil {
+		return nil, fmt.Errorf("%s is already a backup repository", dir)
+	}
+	for _, sub := range []string{"objects", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	cfg := backupConfig{
+		Version:    backupRepoVersion,
+		KDF:        "pbkdf2-sha256",
+		Iterations: backupKDFIterations,
+		Salt:       make([]byte, 32),
+	}
+	if _, err := rand.Read(cfg.Salt); err != nil {
+		return nil, err
+	}
+	r, err := newBackupRepo(dir, &cfg, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Check, err = r.seal([]byte(backupCheckValue), nil); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(backupConfigPath(dir), data, 0o600); err != nil {
+		return nil, fmt.Errorf("writing config: %w", err)
+	}
+	return r, nil
+}
+
+// openBackupRepo opens an existing repository, failing if the passphrase is wrong.
+func openBackupRepo(dir string, passphrase []byte) (*backupRepo, error) {
+	data, err := os.ReadFile(backupConfigPath(dir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s is not a backup repository", dir)
+		}
+		return nil, err
+	}
+	var cfg backupConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+	if cfg.Version != backupRepoVersion || cfg.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported repository (version %d, kdf %q)", cfg.Version, cfg.KDF)
+	}
+	r, err := newBackupRepo(dir, &cfg, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := r.open(cfg.Check, nil); err != nil || string(check) != backupCheckValue {
+		return nil, errors.New("wrong passphrase")
+	}
+	return r, nil
+}
+
+func newBackupRepo(dir string, cfg *backupConfig, passphrase []byte) (*backupRepo, error) {
+	key := pbkdf2SHA256(passphrase, cfg.Salt, cfg.Iterations, 64)
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &backupRepo{dir: dir, aead: aead, idKey: key[32:], cached: map[string]bool{}}, nil
+}
+
+// pbkdf2SHA256 derives a key from a password as described in RFC 8018.
+func pbkdf2SHA256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	var blockNum [4]byte
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(blockNum[:], uint32(block))
+		prf.Write(blockNum[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// seal encrypts data with a random nonce which is prepended to the result. The
+// additional data (an object or snapshot ID) isn't stored, but the same has to be given to
+// `open`, which ties the ciphertext to the name it's stored under.
+func (r *backupRepo) seal(data, ad []byte) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize(), r.aead.NonceSize()+len(data)+r.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, data, ad), nil
+}
+
+func (r *backupRepo) open(data, ad []byte) ([]byte, error) {
+	n := r.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return r.aead.Open(nil, data[:n], data[n:], ad)
+}
+
+// objectID returns the content address of some plaintext. It's keyed so that the names
+// of objects don't reveal anything about their contents.
+func (r *backupRepo) objectID(data []byte) string {
+	h := hmac.New(sha256.New, r.idKey)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (r *backupRepo) objectPath(id string) string {
+	return filepath.Join(r.dir, "objects", id[:2], id)
+}
+
+func (r *backupRepo) hasObject(id string) bool {
+	if r.cached[id] {
+		return true
+	}
+	_, err := os.Stat(r.objectPath(id))
+	return err == nil
+}
+
+// putObject stores data (compressed and encrypted) unless it's already in the repository.
+// It returns the object's ID and whether it was new.
+func (r *backupRepo) putObject(data []byte) (string, bool, error) {
+	id := r.objectID(data)
+	if r.hasObject(id) {
+		return id, false, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return "", false, err
+	}
+	sealed, err := r.seal(buf.Bytes(), []byte(id))
+	if err != nil {
+		return "", false, err
+	}
+	if err := writeFileAtomic(r.objectPath(id), sealed); err != nil {
+		return "", false, err
+	}
+	r.cached[id] = true
+	return id, true, nil
+}
+
+func (r *backupRepo) getObject(id string) ([]byte, error) {
+	sealed, err := os.ReadFile(r.objectPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading object %s: %w", id, err)
+	}
+	compressed, err := r.open(sealed, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting object %s: %w", id, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing object %s: %w", id, err)
+	}
+	return io.ReadAll(zr)
+}
+
+func (r *backupRepo) putSnapshot(s *snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	sealed, err := r.seal(data, []byte(s.ID))
+	if err != nil {
+		return err
+	}
+	return writeFileExclusive(filepath.Join(r.dir, "snapshots", s.ID), sealed)
+}
+
+func (r *backupRepo) getSnapshot(id string) (*snapshot, error) {
+	sealed, err := os.ReadFile(filepath.Join(r.dir, "snapshots", id))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", id, err)
+	}
+	data, err := r.open(sealed, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting snapshot %s: %w", id, err)
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decoding snapshot %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// snapshotIDs returns the IDs of all snapshots from oldest to newest.
+func (r *backupRepo) snapshotIDs() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(r.dir, "snapshots"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasSuffix(e.Name(), ".tmp") {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// findSnapshot resolves a snapshot ID, an ID prefix or "latest".
+func (r *backupRepo) findSnapshot(v string) (*snapshot, error) {
+	ids, err := r.snapshotIDs()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("the repository has no snapshots")
+	}
+	if v == "latest" {
+		return r.getSnapshot(ids[len(ids)-1])
+	}
+	var matches []string
+	for _, id := range ids {
+		if strings.HasPrefix(id, v) {
+			matches = append(matches, id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no snapshot matching %q", v)
+	case 1:
+		return r.getSnapshot(matches[0])
+	default:
+		return nil, fmt.Errorf("%q matches %d snapshots", v, len(matches))
+	}
+}
+
+func writeFileAtomic(fpath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0o700); err != nil {
+		return err
+	}
+	tmp := fpath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fpath)
+}
+
+// writeFileExclusive is like writeFileAtomic, except it fails if the file already exists
+// rather than replacing it.
+func writeFileExclusive(fpath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0o700); err != nil {
+		return err
+	}
+	// Two backups started at once each get their own temporary file.
+	tmp, err := os.CreateTemp(filepath.Dir(fpath), filepath.Base(fpath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Link(tmp.Name(), fpath); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists", filepath.Base(fpath))
+		}
+		return err
+	}
+	return nil
+}
+
+// snapPaths returns the lockbook path of every file in a snapshot ("/" for root and a
+// trailing slash for folders).
+func snapPaths(files []snapFile) map[lockbook.FileID]string {
+	byID := make(map[lockbook.FileID]*snapFile, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	paths := make(map[lockbook.FileID]string, len(files))
+	var pathOf func(f *snapFile) string
+	pathOf = func(f *snapFile) string {
+		if p, ok := paths[f.ID]; ok {
+			return p
+		}
+		var p string
+		parent, ok := byID[f.Parent]
+		switch {
+		case f.ID == f.Parent:
+			p = "/"
+		case !ok:
+			p = "/" + f.Name
+		default:
+			p = pathOf(parent) + f.Name
+		}
+		if f.isDir() && !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+		paths[f.ID] = p
+		return p
+	}
+	for i := range files {
+		pathOf(&files[i])
+	}
+	return paths
+}
@@ -169,26 +169,232 @@ func (c *acctCmd) Parse(args []string) {
 	}
 }
 
-func (*catCmd) UsageHelp() string {
-	return `lbcli cat - Print a document's content
+func (*backupCreateCmd) UsageHelp() string {
+	return `lbcli backup create - Snapshot all files into a backup repository (which is created if needed)
 
 usage:
-   cat [options] <target>
+   create [options] <dir>
+
+options:
+   -quiet,q   Don't list each newly stored document
+   -h         Show this help message
+
+arguments:
+   <dir>   The backup repository directory`
+}
+
+func (c *backupCreateCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli backup create")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("quiet,q", clap.NewBool(&c.quiet))
+	p.Arg("<dir>", clap.NewString(&c.dir)).Require()
+	p.Parse(args)
+}
+
+func (*backupListCmd) UsageHelp() string {
+	return `lbcli backup list - List the snapshots in a backup repository
+
+usage:
+   list [options]
+
+options:
+   -repo,r  <arg>   The backup repository directory (default $LOCKBOOK_BACKUP_REPO)
+   -h               Show this help message`
+}
+
+func (c *backupListCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli backup list")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("repo,r", clap.NewString(&c.repo))
+	p.Parse(args)
+}
+
+func (*backupDiffCmd) UsageHelp() string {
+	return `lbcli backup diff - Show what changed between a snapshot and the current files (or another snapshot)
+
+overview:
+   Each line is marked as added (A), deleted (D), modified (M) or renamed / moved (R).
+
+usage:
+   diff [options] <snap> [other]
+
+options:
+   -repo,r  <arg>   The backup repository directory (default $LOCKBOOK_BACKUP_REPO)
+   -h               Show this help message
+
+arguments:
+   <snap>    The snapshot ID, ID prefix or "latest"
+   [other]   A later snapshot to compare against instead of the current files`
+}
+
+func (c *backupDiffCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli backup diff")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("repo,r", clap.NewString(&c.repo))
+	p.Arg("<snap>", clap.NewString(&c.snap)).Require()
+	p.Arg("[other]", clap.NewString(&c.other))
+	p.Parse(args)
+}
+
+func (*backupRestoreCmd) UsageHelp() string {
+	return `lbcli backup restore - Restore files from a snapshot into lockbook or onto disk
+
+overview:
+   The target path (default "/") is restored within the destination along with
+   everything in it. Existing documents at the same paths are overwritten.
+
+usage:
+   restore [options] <snap> [path]
+
+options:
+   -repo,r  <arg>   The backup repository directory (default $LOCKBOOK_BACKUP_REPO)
+   -to  <arg>       The lockbook folder path or ID to restore into (or a directory with --disk)
+   -disk            Restore onto disk instead of into lockbook
+   -h               Show this help message
+
+arguments:
+   <snap>   The snapshot ID, ID prefix or "latest"
+   [path]   The lockbook path within the snapshot to restore`
+}
+
+func (c *backupRestoreCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli backup restore")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("repo,r", clap.NewString(&c.repo))
+	p.Flag("to", clap.NewString(&c.to))
+	p.Flag("disk", clap.NewBool(&c.toDisk))
+	p.Arg("<snap>", clap.NewString(&c.snap)).Require()
+	p.Arg("[path]", clap.NewString(&c.path))
+	p.Parse(args)
+}
+
+func (*backupCmd) UsageHelp() string {
+	return `lbcli backup - Encrypted local backups and point-in-time restore
+
+overview:
+   Backups are stored in a repository directory which holds deduplicated, encrypted
+   document contents and a snapshot of all file metadata for each backup. The passphrase
+   is read from $LOCKBOOK_BACKUP_PASSPHRASE or prompted for.
+
+usage:
+   backup [options] <command>
 
 options:
    -h   Show this help message
 
+subcommands:
+   create    Snapshot all files into a backup repository (which is created if needed)
+   list      List the snapshots in a backup repository
+   diff      Show what changed between a snapshot and the current files (or another snapshot)
+   restore   Restore files from a snapshot into lockbook or onto disk`
+}
+
+func (c *backupCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli backup")
+	p.CustomUsage = c.UsageHelp
+	rest := p.Parse(args)
+
+	if len(rest) == 0 {
+		p.Fatalf("no subcommand provided")
+	}
+	switch rest[0] {
+	case "create":
+		c.create = &backupCreateCmd{}
+		c.create.Parse(rest[1:])
+	case "list":
+		c.list = &backupListCmd{}
+		c.list.Parse(rest[1:])
+	case "diff":
+		c.diff = &backupDiffCmd{}
+		c.diff.Parse(rest[1:])
+	case "restore":
+		c.restore = &backupRestoreCmd{}
+		c.restore.Parse(rest[1:])
+	default:
+		p.Fatalf("unknown subcommand '%s'", rest[0])
+	}
+}
+
+func (*conflictsListCmd) UsageHelp() string {
+	return `lbcli conflicts list - List the unresolved sync conflicts
+
+usage:
+   list [options]
+
+options:
+   -ids   Show full file IDs instead of prefixes
+   -h     Show this help message`
+}
+
+func (c *conflictsListCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli conflicts list")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("ids", clap.NewBool(&c.fullIDs))
+	p.Parse(args)
+}
+
+func (*conflictsResolveCmd) UsageHelp() string {
+	return `lbcli conflicts resolve - Resolve a sync conflict by keeping the synced version, the local copy or both
+
+usage:
+   resolve --keep <synced|local|both> <target>
+
+options:
+   -keep,k  <arg>   Which version to keep: 'synced' deletes the local copy, 'local' writes
+                    the local copy over the document and deletes the copy, and 'both' keeps
+                    both documents
+   -h               Show this help message
+
 arguments:
-   <target>   Lockbook file path or ID`
+   <target>   The ID, ID prefix or path of the conflicted document or its local copy`
 }
 
-func (c *catCmd) Parse(args []string) {
-	p := clap.NewCommandParser("lbcli cat")
+func (c *conflictsResolveCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli conflicts resolve")
 	p.CustomUsage = c.UsageHelp
+	p.Flag("keep,k", clap.NewString(&c.keep))
 	p.Arg("<target>", clap.NewString(&c.target)).Require()
 	p.Parse(args)
 }
 
+func (*conflictsCmd) UsageHelp() string {
+	return `lbcli conflicts - List and resolve documents that were changed both locally and on the server
+
+overview:
+   When a sync replaces local changes to a document, the local content from before the
+   sync is saved next to it as "name (conflict YYYY-MM-DD).md".
+
+usage:
+   conflicts [options] <command>
+
+options:
+   -h   Show this help message
+
+subcommands:
+   list      List the unresolved sync conflicts
+   resolve   Resolve a sync conflict by keeping the synced version, the local copy or both`
+}
+
+func (c *conflictsCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli conflicts")
+	p.CustomUsage = c.UsageHelp
+	rest := p.Parse(args)
+
+	if len(rest) == 0 {
+		p.Fatalf("no subcommand provided")
+	}
+	switch rest[0] {
+	case "list":
+		c.list = &conflictsListCmd{}
+		c.list.Parse(rest[1:])
+	case "resolve":
+		c.resolve = &conflictsResolveCmd{}
+		c.resolve.Parse(rest[1:])
+	default:
+		p.Fatalf("unknown subcommand '%s'", rest[0])
+	}
+}
+
 func (*debugFinfoCmd) UsageHelp() string {
 	return `lbcli debug finfo - View info about a target file
 
@@ -209,6 +415,31 @@ func (c *debugFinfoCmd) Parse(args []string) {
 	p.Parse(args)
 }
 
+func (*debugStatsCmd) UsageHelp() string {
+	return `lbcli debug stats - Show the core call stats of a running lbgui
+
+overview:
+   lbgui must be started with '-debug-addr'. To see the stats of an lbcli command instead,
+   set $LOCKBOOK_STATS when running it. Set $LOCKBOOK_SLOW_CALL to a duration (e.g. 200ms)
+   to log each call that takes longer.
+
+usage:
+   stats [options] <addr>
+
+options:
+   -h   Show this help message
+
+arguments:
+   <addr>   The address of lbgui's debug listener (e.g. localhost:6060)`
+}
+
+func (c *debugStatsCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli debug stats")
+	p.CustomUsage = c.UsageHelp
+	p.Arg("<addr>", clap.NewString(&c.addr)).Require()
+	p.Parse(args)
+}
+
 func (*debugValidateCmd) UsageHelp() string {
 	return `lbcli debug validate - Find invalid states within your lockbook
 
@@ -252,6 +483,7 @@ options:
 
 subcommands:
    finfo      View info about a target file
+   stats      Show the core call stats of a running lbgui
    validate   Find invalid states within your lockbook
    whoami     Print user information for this lockbook`
 }
@@ -268,6 +500,9 @@ func (c *debugCmd) Parse(args []string) {
 	case "finfo":
 		c.finfo = &debugFinfoCmd{}
 		c.finfo.Parse(rest[1:])
+	case "stats":
+		c.stats = &debugStatsCmd{}
+		c.stats.Parse(rest[1:])
 	case "validate":
 		c.validate = &debugValidateCmd{}
 		c.validate.Parse(rest[1:])
@@ -279,16 +514,78 @@ func (c *debugCmd) Parse(args []string) {
 	}
 }
 
+func (*diffCmd) UsageHelp() string {
+	return `lbcli diff - Show the changes between a recorded revision and a document's current content
+
+usage:
+   diff [options] <target> [rev]
+
+options:
+   -h   Show this help message
+
+arguments:
+   <target>   Lockbook file path or ID
+   [rev]      The revision number to compare against (default is the latest)`
+}
+
+func (c *diffCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli diff")
+	p.CustomUsage = c.UsageHelp
+	p.Arg("<target>", clap.NewString(&c.target)).Require()
+	p.Arg("[rev]", clap.NewString(&c.rev))
+	p.Parse(args)
+}
+
+func (*duCmd) UsageHelp() string {
+	return `lbcli du - Show how much storage each folder uses
+
+overview:
+   Sizes are the per-file usage reported by the server, summed up the folder hierarchy,
+   so they reflect what counts toward the data cap.
+
+usage:
+   du [-a] [-e] [-d <depth>] [-s <order>] [-t <n>] [target]
+
+options:
+   -all,a            Also list documents, not just folders
+   -exact,e          Show amounts in bytes instead of as human readable values
+   -depth,d  <arg>   Only list folders this many levels below the target
+   -sort,s  <arg>    The order of the listing: path or size (largest first)
+   -top,t  <arg>     List the n largest documents instead
+   -h                Show this help message
+
+arguments:
+   [target]   Lockbook file path or ID (defaults to root)`
+}
+
+func (c *duCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli du")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("all,a", clap.NewBool(&c.all))
+	p.Flag("exact,e", clap.NewBool(&c.exact))
+	p.Flag("depth,d", clap.NewString(&c.depth))
+	p.Flag("sort,s", clap.NewString(&c.sortBy))
+	p.Flag("top,t", clap.NewString(&c.top))
+	p.Arg("[target]", clap.NewString(&c.target))
+	p.Parse(args)
+}
+
 func (*exportCmd) UsageHelp() string {
 	return `lbcli export - Copy a lockbook file to your file system
 
 usage:
    export [--quiet] <target> [dest-dir]
    export [--img-fmt <fmt>] <drawing> [dest-dir]
+   export [--img-fmt <fmt>] <folder> [dest-dir]
+   export [--archive <fmt>] <target> [dest]
 
 options:
-   -img-fmt,i  <arg>   Format for exporting a lockbook drawing
-                       (png|jpeg|pnm|tga|farbfeld|bmp)
+   -archive,a  <arg>   Write the target (and everything in it) to a single archive
+                       (zip|tgz). The archive goes to stdout if it's piped and there's
+                       no destination
+   -img-fmt,i  <arg>   Format for exporting lockbook drawings
+                       (png|jpeg|pnm|tga|farbfeld|bmp|svg). If the target is a
+                       folder, every drawing in it is exported
    -quiet,q            Don't output progress on each file
    -h                  Show this help message
 
@@ -300,6 +597,7 @@ arguments:
 func (c *exportCmd) Parse(args []string) {
 	p := clap.NewCommandParser("lbcli export")
 	p.CustomUsage = c.UsageHelp
+	p.Flag("archive,a", clap.NewString(&c.archive))
 	p.Flag("img-fmt,i", clap.NewString(&c.imgFmt))
 	p.Flag("quiet,q", clap.NewBool(&c.quiet))
 	p.Arg("<target>", clap.NewString(&c.target)).Require()
@@ -307,6 +605,85 @@ func (c *exportCmd) Parse(args []string) {
 	p.Parse(args)
 }
 
+func (*findCmd) UsageHelp() string {
+	return `lbcli find - Search for files by name, type, modification and sharing
+
+overview:
+   All predicates must match for a file to be listed. Numeric values take an optional '+'
+   (more than) or '-' (less than) prefix. For example, '-mtime -7' means modified within
+   the last 7 days and '-size +10k' means larger than 10 KiB. The command given to -exec
+   is run with 'sh -c' where each {} is replaced with the (quoted) path.
+
+usage:
+   find [options] [path]
+
+options:
+   -name  <arg>          Only match files whose name matches this glob pattern
+   -type  <arg>          Only match files of this type: d (folder), f (document) or l (link)
+   -mtime  <arg>         Only match files last modified this many days ago (or another unit
+                         such as 12h)
+   -modified-by  <arg>   Only match files that were last modified by this user
+   -shared-with  <arg>   Only match files that are shared with this user
+   -size  <arg>          Only match files of this size in bytes (or with a k, M or G suffix)
+   -exec  <arg>          Run a command for each match instead of printing it
+   -ids                  Print IDs instead of paths
+   -print0               Separate results with a null character instead of a newline
+   -format  <arg>        The output format: text or json
+   -h                    Show this help message
+
+arguments:
+   [path]   Path or ID of the folder to search (defaults to root)`
+}
+
+func (c *findCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli find")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("name", clap.NewString(&c.name))
+	p.Flag("type", clap.NewString(&c.typ))
+	p.Flag("mtime", clap.NewString(&c.mtime))
+	p.Flag("modified-by", clap.NewString(&c.modifiedBy))
+	p.Flag("shared-with", clap.NewString(&c.sharedWith))
+	p.Flag("size", clap.NewString(&c.size))
+	p.Flag("exec", clap.NewString(&c.exec))
+	p.Flag("ids", clap.NewBool(&c.ids))
+	p.Flag("print0", clap.NewBool(&c.print0))
+	p.Flag("format", clap.NewString(&c.format))
+	p.Arg("[path]", clap.NewString(&c.target))
+	p.Parse(args)
+}
+
+func (*historyCmd) UsageHelp() string {
+	return `lbcli history - List the locally recorded revisions of a document or prune old revisions
+
+overview:
+   A revision is recorded right before a document is overwritten by lbcli, lbgui or a
+   sync. Revision numbers can be used with 'show', 'diff' and 'restore'.
+
+usage:
+   history [--prune [--keep <n>] [--older-than <age>]] [target]
+
+options:
+   -prune,p               Remove revisions beyond the retention limits (of the target or
+                          of all documents)
+   -keep,k  <arg>         Keep at most this many revisions of each document when pruning
+   -older-than,o  <arg>   Remove revisions recorded longer ago than this (such as 30d, 2w
+                          or 12h) when pruning
+   -h                     Show this help message
+
+arguments:
+   [target]   Lockbook file path or ID`
+}
+
+func (c *historyCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli history")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("prune,p", clap.NewBool(&c.prune))
+	p.Flag("keep,k", clap.NewString(&c.keep))
+	p.Flag("older-than,o", clap.NewString(&c.olderThan))
+	p.Arg("[target]", clap.NewString(&c.target))
+	p.Parse(args)
+}
+
 func (*importCmd) UsageHelp() string {
 	return `lbcli import - Import files into lockbook from your system
 
@@ -314,17 +691,22 @@ usage:
    import [options] <diskpath> [dest]
 
 options:
-   -quiet,q   Don't output progress on each file
-   -h         Show this help message
+   -from,f  <arg>   Convert an export from another app (obsidian|notion|evernote).
+                    The disk path is an Obsidian vault, a Notion zip export, or an
+                    Evernote .enex file respectively
+   -quiet,q         Don't output progress on each file
+   -h               Show this help message
 
 arguments:
-   <diskpath>   The file to import into lockbook
+   <diskpath>   The file to import into lockbook. Zip and tarball (.tar.gz) archives
+                are extracted
    [dest]       Where to put the imported files in lockbook`
 }
 
 func (c *importCmd) Parse(args []string) {
 	p := clap.NewCommandParser("lbcli import")
 	p.CustomUsage = c.UsageHelp
+	p.Flag("from,f", clap.NewString(&c.from))
 	p.Flag("quiet,q", clap.NewBool(&c.quiet))
 	p.Arg("<diskpath>", clap.NewString(&c.diskPath)).Require()
 	p.Arg("[dest]", clap.NewString(&c.dest))
@@ -431,28 +813,6 @@ func (c *mkdocCmd) Parse(args []string) {
 	p.Parse(args)
 }
 
-func (*mvCmd) UsageHelp() string {
-	return `lbcli mv - Move a file to another parent
-
-usage:
-   mv [options] <src> <dest>
-
-options:
-   -h   Show this help message
-
-arguments:
-   <src>    The file to move
-   <dest>   The destination directory`
-}
-
-func (c *mvCmd) Parse(args []string) {
-	p := clap.NewCommandParser("lbcli mv")
-	p.CustomUsage = c.UsageHelp
-	p.Arg("<src>", clap.NewString(&c.src)).Require()
-	p.Arg("<dest>", clap.NewString(&c.dest)).Require()
-	p.Parse(args)
-}
-
 func (*renameCmd) UsageHelp() string {
 	return `lbcli rename - Rename a file
 
@@ -477,24 +837,25 @@ func (c *renameCmd) Parse(args []string) {
 	p.Parse(args)
 }
 
-func (*rmCmd) UsageHelp() string {
-	return `lbcli rm - Delete a file
+func (*restoreCmd) UsageHelp() string {
+	return `lbcli restore - Replace a document's content with a recorded revision
+
+overview:
+   The content being replaced is recorded as a new revision, so a restore can be undone.
 
 usage:
-   rm [options] <target>
+   restore [options] <target>
 
 options:
-   -force,f   Don't prompt for confirmation
-   -h         Show this help message
+   -h   Show this help message
 
 arguments:
-   <target>   Lockbook path or ID to delete`
+   <target>   Lockbook file path or ID followed by '@' and a revision number`
 }
 
-func (c *rmCmd) Parse(args []string) {
-	p := clap.NewCommandParser("lbcli rm")
+func (c *restoreCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli restore")
 	p.CustomUsage = c.UsageHelp
-	p.Flag("force,f", clap.NewBool(&c.force))
 	p.Arg("<target>", clap.NewString(&c.target)).Require()
 	p.Parse(args)
 }
@@ -585,6 +946,47 @@ func (c *shareRejectCmd) Parse(args []string) {
 	p.Parse(args)
 }
 
+func (*shareListCmd) UsageHelp() string {
+	return `lbcli share list - List the files you've shared and that have been shared with you, grouped by user
+
+usage:
+   list [--by-me | --with-me]
+
+options:
+   -by-me     Only list the files you've shared with others
+   -with-me   Only list the files others have shared with you
+   -h         Show this help message`
+}
+
+func (c *shareListCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli share list")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("by-me", clap.NewBool(&c.byMe))
+	p.Flag("with-me", clap.NewBool(&c.withMe))
+	p.Parse(args)
+}
+
+func (*shareAuditCmd) UsageHelp() string {
+	return `lbcli share audit - Flag the files you've shared that might deserve a second look
+
+overview:
+   Write shares are flagged because the other user can change or delete the file. Shares
+   of files within an already shared folder are flagged because they're either redundant
+   or give someone access to only part of a folder that others can see in full.
+
+usage:
+   audit [options]
+
+options:
+   -h   Show this help message`
+}
+
+func (c *shareAuditCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli share audit")
+	p.CustomUsage = c.UsageHelp
+	p.Parse(args)
+}
+
 func (*shareCmd) UsageHelp() string {
 	return `lbcli share - Sharing related commands
 
@@ -598,7 +1000,9 @@ subcommands:
    create    Share a file with another lockbook user
    pending   List pending shares
    accept    Accept a pending share
-   reject    Reject a pending share`
+   reject    Reject a pending share
+   list      List the files you've shared and that have been shared with you, grouped by user
+   audit     Flag the files you've shared that might deserve a second look`
 }
 
 func (c *shareCmd) Parse(args []string) {
@@ -622,11 +1026,37 @@ func (c *shareCmd) Parse(args []string) {
 	case "reject":
 		c.reject = &shareRejectCmd{}
 		c.reject.Parse(rest[1:])
+	case "list":
+		c.list = &shareListCmd{}
+		c.list.Parse(rest[1:])
+	case "audit":
+		c.audit = &shareAuditCmd{}
+		c.audit.Parse(rest[1:])
 	default:
 		p.Fatalf("unknown subcommand '%s'", rest[0])
 	}
 }
 
+func (*showCmd) UsageHelp() string {
+	return `lbcli show - Print the content of a document at a recorded revision
+
+usage:
+   show [options] <target>
+
+options:
+   -h   Show this help message
+
+arguments:
+   <target>   Lockbook file path or ID followed by '@' and a revision number`
+}
+
+func (c *showCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli show")
+	p.CustomUsage = c.UsageHelp
+	p.Arg("<target>", clap.NewString(&c.target)).Require()
+	p.Parse(args)
+}
+
 func (*syncCmd) UsageHelp() string {
 	return `lbcli sync - Get updates from the server and push changes
 
@@ -634,9 +1064,19 @@ usage:
    sync [options]
 
 options:
-   -status,s    Show last synced and which operations a sync would perform
-   -verbose,v   Output every sync step and progress
-   -h           Show this help message`
+   -status,s         Show last synced and which operations a sync would perform
+   -verbose,v        Output every sync step, then a table of the pushed and pulled
+                     documents
+   -daemon,d         Keep running and sync periodically, backing off while the server is
+                     unreachable
+   -dry-run,n        Print what a sync would push and pull without syncing
+   -only  <arg>      Only push local changes within this folder
+   -exclude  <arg>   Hold back local changes to files matching this glob (and in folders
+                     matching it)
+   -persist          Save --only and --exclude so that later writes outside of them are
+                     held back until they're selected by a sync (without them, clear the
+                     saved filter)
+   -h                Show this help message`
 }
 
 func (c *syncCmd) Parse(args []string) {
@@ -644,9 +1084,135 @@ func (c *syncCmd) Parse(args []string) {
 	p.CustomUsage = c.UsageHelp
 	p.Flag("status,s", clap.NewBool(&c.status))
 	p.Flag("verbose,v", clap.NewBool(&c.verbose))
+	p.Flag("daemon,d", clap.NewBool(&c.daemon))
+	p.Flag("dry-run,n", clap.NewBool(&c.dryRun))
+	p.Flag("only", clap.NewString(&c.only))
+	p.Flag("exclude", clap.NewString(&c.exclude))
+	p.Flag("persist", clap.NewBool(&c.persist))
+	p.Parse(args)
+}
+
+func (*trashEnableCmd) UsageHelp() string {
+	return `lbcli trash enable - Start moving deleted files to the trash
+
+usage:
+   enable [options]
+
+options:
+   -h   Show this help message`
+}
+
+func (c *trashEnableCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli trash enable")
+	p.CustomUsage = c.UsageHelp
+	p.Parse(args)
+}
+
+func (*trashListCmd) UsageHelp() string {
+	return `lbcli trash list - List the files in the trash
+
+usage:
+   list [options]
+
+options:
+   -ids   Show full file IDs instead of prefixes
+   -h     Show this help message`
+}
+
+func (c *trashListCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli trash list")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("ids", clap.NewBool(&c.fullIDs))
+	p.Parse(args)
+}
+
+func (*trashRestoreCmd) UsageHelp() string {
+	return `lbcli trash restore - Move a file out of the trash back to where it was deleted from
+
+usage:
+   restore [options] <target>
+
+options:
+   -h   Show this help message
+
+arguments:
+   <target>   The ID, ID prefix or original path of the trashed file`
+}
+
+func (c *trashRestoreCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli trash restore")
+	p.CustomUsage = c.UsageHelp
+	p.Arg("<target>", clap.NewString(&c.target)).Require()
+	p.Parse(args)
+}
+
+func (*trashEmptyCmd) UsageHelp() string {
+	return `lbcli trash empty - Permanently delete the files in the trash
+
+usage:
+   empty [-f] [--older-than <age>]
+
+options:
+   -force,f               Don't prompt for confirmation
+   -older-than,o  <arg>   Only delete files that were trashed longer ago than this (such
+                          as 30d, 2w or 12h)
+   -h                     Show this help message`
+}
+
+func (c *trashEmptyCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli trash empty")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("force,f", clap.NewBool(&c.force))
+	p.Flag("older-than,o", clap.NewString(&c.olderThan))
 	p.Parse(args)
 }
 
+func (*trashCmd) UsageHelp() string {
+	return `lbcli trash - Manage deleted files in the trash
+
+overview:
+   The trash is opt-in. Once enabled, 'rm' moves files into the hidden /.trash/ folder
+   where they can be restored until the trash is emptied.
+
+usage:
+   trash [options] <command>
+
+options:
+   -h   Show this help message
+
+subcommands:
+   enable    Start moving deleted files to the trash
+   list      List the files in the trash
+   restore   Move a file out of the trash back to where it was deleted from
+   empty     Permanently delete the files in the trash`
+}
+
+func (c *trashCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli trash")
+	p.CustomUsage = c.UsageHelp
+	rest := p.Parse(args)
+
+	if len(rest) == 0 {
+		p.Fatalf("no subcommand provided")
+	}
+	switch rest[0] {
+	case "enable":
+		c.enable = &trashEnableCmd{}
+		c.enable.Parse(rest[1:])
+	case "list":
+		c.list = &trashListCmd{}
+		c.list.Parse(rest[1:])
+	case "restore":
+		c.restore = &trashRestoreCmd{}
+		c.restore.Parse(rest[1:])
+	case "empty":
+		c.empty = &trashEmptyCmd{}
+		c.empty.Parse(rest[1:])
+	default:
+		p.Fatalf("unknown subcommand '%s'", rest[0])
+	}
+}
+
 func (*usageCmd) UsageHelp() string {
 	return `lbcli usage - Local and server disk utilization (uncompressed and compressed)
 
@@ -697,22 +1263,31 @@ options:
    -h   Show this help message
 
 subcommands:
-   acct     Account related commands
-   cat      Print a document's content
-   debug    Investigative commands mainly intended for devs
-   export   Copy a lockbook file to your file system
-   import   Import files into lockbook from your system
-   jot      Quickly record brief thoughts
-   ls       List files in a directory
-   mkdir    Create a directory or do nothing if it exists
-   mkdoc    Create a document or do nothing if it exists
-   mv       Move a file to another parent
-   rename   Rename a file
-   rm       Delete a file
-   share    Sharing related commands
-   sync     Get updates from the server and push changes
-   usage    Local and server disk utilization (uncompressed and compressed)
-   write    Write data from stdin to a lockbook document
+   acct        Account related commands
+   backup      Encrypted local backups and point-in-time restore
+   cat         Print the content of one or more documents
+   conflicts   List and resolve documents that were changed both locally and on the server
+   debug       Investigative commands mainly intended for devs
+   diff        Show the changes between a recorded revision and a document's current content
+   du          Show how much storage each folder uses
+   export      Copy a lockbook file to your file system
+   find        Search for files by name, type, modification and sharing
+   history     List the locally recorded revisions of a document or prune old revisions
+   import      Import files into lockbook from your system
+   jot         Quickly record brief thoughts
+   ls          List files in a directory
+   mkdir       Create a directory or do nothing if it exists
+   mkdoc       Create a document or do nothing if it exists
+   mv          Move files to another parent
+   rename      Rename a file
+   restore     Replace a document's content with a recorded revision
+   rm          Delete files
+   share       Sharing related commands
+   show        Print the content of a document at a recorded revision
+   sync        Get updates from the server and push changes
+   trash       Manage deleted files in the trash
+   usage       Local and server disk utilization (uncompressed and compressed)
+   write       Write data from stdin to a lockbook document
 
 Run 'lbcli <subcommand> -h' for more information on specific commands.`
 }
@@ -729,15 +1304,33 @@ func (c *lbcli) Parse(args []string) {
 	case "acct":
 		c.acct = &acctCmd{}
 		c.acct.Parse(rest[1:])
+	case "backup":
+		c.backup = &backupCmd{}
+		c.backup.Parse(rest[1:])
 	case "cat":
 		c.cat = &catCmd{}
 		c.cat.Parse(rest[1:])
+	case "conflicts":
+		c.conflicts = &conflictsCmd{}
+		c.conflicts.Parse(rest[1:])
 	case "debug":
 		c.debug = &debugCmd{}
 		c.debug.Parse(rest[1:])
+	case "diff":
+		c.diff = &diffCmd{}
+		c.diff.Parse(rest[1:])
+	case "du":
+		c.du = &duCmd{}
+		c.du.Parse(rest[1:])
 	case "export":
 		c.export = &exportCmd{}
 		c.export.Parse(rest[1:])
+	case "find":
+		c.find = &findCmd{}
+		c.find.Parse(rest[1:])
+	case "history":
+		c.hist = &historyCmd{}
+		c.hist.Parse(rest[1:])
 	case "import":
 		c.imprt = &importCmd{}
 		c.imprt.Parse(rest[1:])
@@ -759,15 +1352,24 @@ func (c *lbcli) Parse(args []string) {
 	case "rename":
 		c.rename = &renameCmd{}
 		c.rename.Parse(rest[1:])
+	case "restore":
+		c.restore = &restoreCmd{}
+		c.restore.Parse(rest[1:])
 	case "rm":
 		c.rm = &rmCmd{}
 		c.rm.Parse(rest[1:])
 	case "share":
 		c.share = &shareCmd{}
 		c.share.Parse(rest[1:])
+	case "show":
+		c.show = &showCmd{}
+		c.show.Parse(rest[1:])
 	case "sync":
 		c.sync = &syncCmd{}
 		c.sync.Parse(rest[1:])
+	case "trash":
+		c.trash = &trashCmd{}
+		c.trash.Parse(rest[1:])
 	case "usage":
 		c.usage = &usageCmd{}
 		c.usage.Parse(rest[1:])
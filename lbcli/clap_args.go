@@ -0,0 +1,89 @@
+// The commands that take a variable number of arguments (such as `mv`'s "<src>...
+// <dest>") are parsed here by hand since goclap can't express them. After running `go
+// generate`, delete whatever goclap emits for these commands from clap.gen.go; the
+// compiler points out any duplicate methods that are left.
+
+package main
+
+import "github.com/steverusso/goclap/clap"
+
+func (*catCmd) UsageHelp() string {
+	return `lbcli cat - Print the content of one or more documents
+
+usage:
+   cat [options] <targets>...
+
+options:
+   -h   Show this help message
+
+arguments:
+   <targets>...   Lockbook file paths, IDs or glob patterns`
+}
+
+func (c *catCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli cat")
+	p.CustomUsage = c.UsageHelp
+	c.targets = p.Parse(args)
+	if len(c.targets) == 0 {
+		p.Fatalf("missing required arg '<targets>...'")
+	}
+}
+
+func (*mvCmd) UsageHelp() string {
+	return `lbcli mv - Move files to another parent
+
+usage:
+   mv [--dry-run] <src>... <dest>
+
+options:
+   -dry-run,n   Print what would be moved without moving anything
+   -h           Show this help message
+
+arguments:
+   <src>...   The files to move (paths, IDs or glob patterns)
+   <dest>     The destination directory`
+}
+
+func (c *mvCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli mv")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("dry-run,n", clap.NewBool(&c.dryRun))
+	rest := p.Parse(args)
+	if len(rest) < 2 {
+		p.Fatalf("missing required args '<src>... <dest>'")
+	}
+	c.srcs = rest[:len(rest)-1]
+	c.dest = rest[len(rest)-1]
+}
+
+func (*rmCmd) UsageHelp() string {
+	return `lbcli rm - Delete files
+
+overview:
+   If the trash is enabled (see 'trash enable'), files are moved to the trash instead of
+   being deleted, unless they're already in the trash or --permanent is given.
+
+usage:
+   rm [options] <targets>...
+
+options:
+   -force,f       Don't prompt for confirmation
+   -permanent,p   Delete permanently instead of moving to the trash
+   -dry-run,n     Print what would be deleted without deleting anything
+   -h             Show this help message
+
+arguments:
+   <targets>...   Lockbook paths, IDs or glob patterns to delete`
+}
+
+func (c *rmCmd) Parse(args []string) {
+	p := clap.NewCommandParser("lbcli rm")
+	p.CustomUsage = c.UsageHelp
+	p.Flag("force,f", clap.NewBool(&c.force))
+	p.Flag("permanent,p", clap.NewBool(&c.permanent))
+	p.Flag("dry-run,n", clap.NewBool(&c.dryRun))
+	c.targets = p.Parse(args)
+	if len(c.targets) == 0 {
+		p.Fatalf("missing required arg '<targets>...'")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// List and resolve documents that were changed both locally and on the server.
+//
+// When a sync replaces local changes to a document, the local content from before the
+// sync is saved next to it as "name (conflict YYYY-MM-DD).md".
+type conflictsCmd struct {
+	list    *conflictsListCmd
+	resolve *conflictsResolveCmd
+}
+
+func (c *conflictsCmd) run(core lockbook.Core, cc *lockbook.ConflictCore) error {
+	switch {
+	case c.list != nil:
+		return c.list.run(cc)
+	case c.resolve != nil:
+		return c.resolve.run(core, cc)
+	default:
+		return nil
+	}
+}
+
+// List the unresolved sync conflicts.
+type conflictsListCmd struct {
+	// Show full file IDs instead of prefixes.
+	//
+	// clap:opt ids
+	fullIDs bool
+}
+
+func (c *conflictsListCmd) run(cc *lockbook.ConflictCore) error {
+	conflicts, err := cc.Conflicts()
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("no conflicts")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "id\tdetected\tsynced from\tpath\tlocal copy")
+	for _, cf := range conflicts {
+		id := cf.ID.String()
+		if !c.fullIDs {
+			id = id[:idPrefixLen]
+		}
+		by := cf.LastmodBy
+		if by == "" {
+			by = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			id, cf.Detected.Local().Format("2006-01-02 15:04"), by, cf.Path, cf.CopyPath)
+	}
+	return tw.Flush()
+}
+
+// Resolve a sync conflict by keeping the synced version, the local copy or both.
+//
+// clap:cmd_usage --keep <synced|local|both> <target>
+type conflictsResolveCmd struct {
+	// Which version to keep: 'synced' deletes the local copy, 'local' writes the local
+	// copy over the document and deletes the copy, and 'both' keeps both documents.
+	//
+	// clap:opt keep,k
+	keep string
+	// The ID, ID prefix or path of the conflicted document or its local copy.
+	//
+	// clap:arg_required
+	target string
+}
+
+func (c *conflictsResolveCmd) run(core lockbook.Core, cc *lockbook.ConflictCore) error {
+	var res lockbook.ConflictResolution
+	switch c.keep {
+	case "synced":
+		res = lockbook.KeepSynced
+	case "local":
+		res = lockbook.KeepLocal
+	case "both":
+		res = lockbook.KeepBoth
+	case "":
+		return fmt.Errorf("--keep is required (synced, local or both)")
+	default:
+		return fmt.Errorf("unknown --keep value %q (want synced, local or both)", c.keep)
+	}
+	conflicts, err := cc.Conflicts()
+	if err != nil {
+		return err
+	}
+	id, err := conflictID(conflicts, c.target)
+	if err != nil {
+		return err
+	}
+	cf, err := cc.Resolve(core, id, res)
+	if err != nil {
+		return err
+	}
+	switch res {
+	case lockbook.KeepSynced:
+		fmt.Printf("kept the synced %s and deleted %s\n", cf.Path, cf.CopyPath)
+	case lockbook.KeepLocal:
+		fmt.Printf("restored the local copy to %s and deleted %s\n", cf.Path, cf.CopyPath)
+	case lockbook.KeepBoth:
+		fmt.Printf("kept both %s and %s\n", cf.Path, cf.CopyPath)
+	}
+	return nil
+}
+
+// conflictID finds a conflict by the ID, ID prefix or path of its document or copy.
+func conflictID(conflicts []lockbook.Conflict, v string) (lockbook.FileID, error) {
+	var matches []lockbook.Conflict
+	for _, cf := range conflicts {
+		if cf.Path == v || cf.CopyPath == v ||
+			(v != "" && (strings.HasPrefix(cf.ID.String(), v) || strings.HasPrefix(cf.CopyID.String(), v))) {
+			matches = append(matches, cf)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return lockbook.FileID{}, fmt.Errorf("no conflict matches %q", v)
+	case 1:
+		return matches[0].ID, nil
+	default:
+		return lockbook.FileID{}, fmt.Errorf("%q matches %d conflicts", v, len(matches))
+	}
+}
@@ -1,16 +1,100 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/steverusso/lockbook-x/go-lockbook"
 )
 
+const (
+	// statsEnv makes every command print the stats of its core calls when it's done.
+	statsEnv = "LOCKBOOK_STATS"
+	// slowCallEnv is a duration (e.g. "200ms") above which core calls are logged.
+	slowCallEnv = "LOCKBOOK_SLOW_CALL"
+	// recordEnv is a file to write a trace of every core call to (for bug reports).
+	recordEnv = "LOCKBOOK_RECORD"
+	// redactContentEnv leaves document content out of recorded traces.
+	redactContentEnv = "LOCKBOOK_REDACT_CONTENT"
+	// replayEnv is a recorded trace to answer core calls from instead of a real core.
+	replayEnv = "LOCKBOOK_REPLAY"
+)
+
+// openCore returns the core for the given data directory, or one that replays a trace
+// if $LOCKBOOK_REPLAY is set. If $LOCKBOOK_RECORD is set, the calls are recorded. The
+// returned func must be called when done with the core.
+func openCore(dataDir string) (lockbook.Core, func(), error) {
+	var core lockbook.Core
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	if fpath := os.Getenv(replayEnv); fpath != "" {
+		rp, err := openReplay(fpath)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, func() { os.RemoveAll(rp.Dir) })
+		core = rp
+	} else {
+		c, err := lockbook.NewCore(dataDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("initializing core: %v", err)
+		}
+		core = c
+	}
+	if fpath := os.Getenv(recordEnv); fpath != "" {
+		f, err := os.Create(fpath)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("creating trace file: %w", err)
+		}
+		rc := lockbook.Recorder(core, f, lockbook.RecordOptions{
+			RedactContent: os.Getenv(redactContentEnv) != "",
+			RedactAccount: true,
+		})
+		closers = append(closers, func() {
+			if err := rc.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "recording trace: %v\n", err)
+			}
+			f.Close()
+		})
+		core = rc
+	}
+	return core, closeAll, nil
+}
+
+// openReplay reads a trace into a replaying core that keeps anything written alongside
+// the core's data (such as history) in a temporary directory.
+func openReplay(fpath string) (*lockbook.ReplayCore, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace: %w", err)
+	}
+	defer f.Close()
+	rp, err := lockbook.Replayer(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading trace %q: %w", fpath, err)
+	}
+	if rp.Dir, err = os.MkdirTemp("", "lockbook-replay-"); err != nil {
+		return nil, fmt.Errorf("creating replay data dir: %w", err)
+	}
+	return rp, nil
+}
+
 // Investigative commands mainly intended for devs.
 type debugCmd struct {
 	finfo    *debugFinfoCmd
+	stats    *debugStatsCmd
 	validate *debugValidateCmd
 	whoami   *debugWhoamiCmd
 }
@@ -19,6 +103,8 @@ func (d *debugCmd) run(core lockbook.Core) error {
 	switch {
 	case d.finfo != nil:
 		return d.finfo.run(core)
+	case d.stats != nil:
+		return d.stats.run(core)
 	case d.validate != nil:
 		return d.validate.run(core)
 	case d.whoami != nil:
@@ -90,6 +176,80 @@ func printFile(f *lockbook.File, myName string) {
 	}
 }
 
+// Show the core call stats of a running lbgui.
+//
+// lbgui must be started with '-debug-addr'. To see the stats of an lbcli command instead,
+// set $LOCKBOOK_STATS when running it. Set $LOCKBOOK_SLOW_CALL to a duration (e.g. 200ms)
+// to log each call that takes longer.
+type debugStatsCmd struct {
+	// The address of lbgui's debug listener (e.g. localhost:6060).
+	//
+	// clap:arg_required
+	addr string
+}
+
+func (c *debugStatsCmd) run(_ lockbook.Core) error {
+	url := c.addr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	url = strings.TrimSuffix(url, "/") + "/stats"
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("fetching stats: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching stats: %s", resp.Status)
+	}
+	var stats []lockbook.OpStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return fmt.Errorf("decoding stats: %w", err)
+	}
+	printStats(os.Stdout, stats)
+	return nil
+}
+
+// printStats prints a table of call stats with the most total time spent first.
+func printStats(w io.Writer, stats []lockbook.OpStats) {
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Total > stats[j].Total })
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "op\tcalls\terrors\tmean\tmax\ttotal\tsize")
+	for _, st := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%v\t%v\t%v\t%d\n",
+			st.Op, st.Calls, errorsString(&st), roundDur(st.Mean()), roundDur(st.Max), roundDur(st.Total), st.Size)
+	}
+	tw.Flush()
+}
+
+// errorsString returns the number of errors followed by the count of each error code.
+func errorsString(st *lockbook.OpStats) string {
+	if len(st.ErrorCodes) == 0 {
+		return fmt.Sprint(st.Errors)
+	}
+	codes := make([]lockbook.ErrorCode, 0, len(st.ErrorCodes))
+	for code := range st.ErrorCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("code %d: %d", code, st.ErrorCodes[code])
+	}
+	return fmt.Sprintf("%d (%s)", st.Errors, strings.Join(parts, ", "))
+}
+
+func roundDur(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
 // Find invalid states within your lockbook.
 type debugValidateCmd struct{}
 
@@ -98,6 +258,11 @@ func (debugValidateCmd) run(core lockbook.Core) error {
 	if err != nil {
 		return fmt.Errorf("running validate: %w", err)
 	}
+	dangling, err := danglingLinks(core)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, dangling...)
 	count := len(warnings)
 	if count == 0 {
 		return nil
@@ -113,6 +278,29 @@ func (debugValidateCmd) run(core lockbook.Core) error {
 	return nil
 }
 
+// danglingLinks returns a warning for each link whose target doesn't exist.
+func danglingLinks(core lockbook.Core) ([]string, error) {
+	files, err := core.ListMetadatas()
+	if err != nil {
+		return nil, fmt.Errorf("listing metadatas: %w", err)
+	}
+	var warnings []string
+	for _, f := range files {
+		link, ok := f.Type.(lockbook.FileTypeLink)
+		if !ok {
+			continue
+		}
+		if _, err := lockbook.ResolveLink(core, f); lockbook.IsDanglingLink(err) {
+			p, err := core.PathByID(f.ID)
+			if err != nil {
+				p = f.Name
+			}
+			warnings = append(warnings, fmt.Sprintf("link %s points to nonexistent file %s", p, link.Target))
+		}
+	}
+	return warnings, nil
+}
+
 // Print user information for this lockbook.
 type debugWhoamiCmd struct{}
 
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// Show how much storage each folder uses.
+//
+// Sizes are the per-file usage reported by the server, summed up the folder hierarchy,
+// so they reflect what counts toward the data cap.
+//
+// clap:cmd_usage [-a] [-e] [-d <depth>] [-s <order>] [-t <n>] [target]
+type duCmd struct {
+	// Also list documents, not just folders.
+	//
+	// clap:opt all,a
+	all bool
+	// Show amounts in bytes instead of as human readable values.
+	//
+	// clap:opt exact,e
+	exact bool
+	// Only list folders this many levels below the target.
+	//
+	// clap:opt depth,d
+	depth string
+	// The order of the listing: path or size (largest first).
+	//
+	// clap:opt sort,s
+	sortBy string
+	// List the n largest documents instead.
+	//
+	// clap:opt top,t
+	top string
+	// Lockbook file path or ID (defaults to root).
+	target string
+}
+
+func (c *duCmd) run(core lockbook.Core) error {
+	depth := -1
+	if c.depth != "" {
+		n, err := strconv.Atoi(c.depth)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid depth %q", c.depth)
+		}
+		depth = n
+	}
+	switch c.sortBy {
+	case "", "path", "size":
+	default:
+		return fmt.Errorf("unknown sort order %q (expected path or size)", c.sortBy)
+	}
+
+	root, err := core.GetRoot()
+	if err != nil {
+		return fmt.Errorf("getting root: %w", err)
+	}
+	id := root.ID
+	if c.target != "" {
+		if id, err = idFromSomething(core, c.target); err != nil {
+			return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+		}
+	}
+	tree, err := lockbook.UsageTree(core, id)
+	if err != nil {
+		return err
+	}
+
+	var nodes []*lockbook.UsageNode
+	if c.top != "" {
+		n, err := strconv.Atoi(c.top)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of documents %q", c.top)
+		}
+		nodes = tree.Largest(n)
+	} else {
+		nodes = c.collect(tree, depth)
+		if c.sortBy == "size" {
+			sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Size > nodes[j].Size })
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, n := range nodes {
+		fmt.Fprintf(tw, "%s\t  %s\n", c.sizeString(n.Size), n.Path)
+	}
+	return tw.Flush()
+}
+
+// collect returns the nodes to list in path order, with each folder after its contents
+// (like du).
+func (c *duCmd) collect(n *lockbook.UsageNode, depth int) []*lockbook.UsageNode {
+	var nodes []*lockbook.UsageNode
+	if depth != 0 {
+		children := append([]*lockbook.UsageNode(nil), n.Children...)
+		sort.Slice(children, func(i, j int) bool { return children[i].Path < children[j].Path })
+		for _, ch := range children {
+			if !ch.File.IsDir() {
+				if c.all {
+					nodes = append(nodes, ch)
+				}
+				continue
+			}
+			nodes = append(nodes, c.collect(ch, depth-1)...)
+		}
+	}
+	if n.File.IsDir() || c.all {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func (c *duCmd) sizeString(n uint64) string {
+	if c.exact {
+		return strconv.FormatUint(n, 10)
+	}
+	return humanBytes(int64(n))
+}
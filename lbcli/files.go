@@ -5,28 +5,38 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/steverusso/lockbook-x/go-lockbook"
 )
 
-// Print a document's content.
+// Print the content of one or more documents.
 type catCmd struct {
-	// Lockbook file path or ID.
+	// Lockbook file paths, IDs or glob patterns.
 	//
 	// clap:arg_required
-	target string
+	targets []string
 }
 
 func (c *catCmd) run(core lockbook.Core) error {
-	id, err := idFromSomething(core, c.target)
+	targets, err := resolveTargets(core, c.targets)
 	if err != nil {
-		return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+		return err
 	}
-	data, err := core.ReadDocument(id)
-	if err != nil {
-		return fmt.Errorf("reading doc %q: %w", c.target, err)
+	for _, t := range targets {
+		f, err := lockbook.ResolveLink(core, t.file)
+		if err != nil {
+			return fmt.Errorf("resolving link %q: %w", t.path, err)
+		}
+		if f.IsDir() {
+			return fmt.Errorf("%q is a folder", t.path)
+		}
+		data, err := core.ReadDocument(f.ID)
+		if err != nil {
+			return fmt.Errorf("reading doc %q: %w", t.path, err)
+		}
+		fmt.Printf("%s", data)
 	}
-	fmt.Printf("%s", data)
 	return nil
 }
 
@@ -130,91 +140,209 @@ func (c *renameCmd) run(core lockbook.Core) error {
 	}
 }
 
-// Move a file to another parent.
+// Move files to another parent.
 //
 // clap:cmd_aliases move
+// clap:cmd_usage [--dry-run] <src>... <dest>
 type mvCmd struct {
-	// The file to move.
+	// Print what would be moved without moving anything.
 	//
-	// clap:arg_required
-	src string
+	// clap:opt dry-run,n
+	dryRun bool
+	// The files to move (paths, IDs or glob patterns).
+	srcs []string
 	// The destination directory.
-	//
-	// clap:arg_required
 	dest string
 }
 
 func (c *mvCmd) run(core lockbook.Core) error {
-	srcID, err := idFromSomething(core, c.src)
-	if err != nil {
-		return fmt.Errorf("trying to get src id from %q: %w", c.src, err)
-	}
 	destID, err := idFromSomething(core, c.dest)
 	if err != nil {
 		return fmt.Errorf("trying to get dest id from %q: %w", c.dest, err)
 	}
-	err = core.MoveFile(srcID, destID)
+	dest, err := core.FileByID(destID)
+	if err != nil {
+		return fmt.Errorf("file by id %q: %w", destID, err)
+	}
+	if !dest.IsDir() {
+		return fmt.Errorf("destination %q is not a folder", c.dest)
+	}
+	destPath, err := core.PathByID(destID)
 	if err != nil {
-		return fmt.Errorf("moving %s -> %s: %w", srcID, destID, err)
+		return fmt.Errorf("path by id %q: %w", destID, err)
+	}
+	srcs, err := resolveTargets(core, c.srcs)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if src.file.Parent == destID {
+			fmt.Printf("%s is already in %s\n", src.path, destPath)
+			continue
+		}
+		newPath := destPath + src.file.Name
+		if src.file.IsDir() {
+			newPath += "/"
+		}
+		if c.dryRun {
+			fmt.Printf("would move %s -> %s\n", src.path, newPath)
+			continue
+		}
+		if err := core.MoveFile(src.file.ID, destID); err != nil {
+			return fmt.Errorf("moving %s -> %s: %w", src.path, destPath, err)
+		}
 	}
 	return nil
 }
 
-// Delete a file.
+// Delete files.
+//
+// If the trash is enabled (see 'trash enable'), files are moved to the trash instead of
+// being deleted, unless they're already in the trash or --permanent is given.
 type rmCmd struct {
 	// Don't prompt for confirmation.
 	//
 	// clap:opt force,f
 	force bool
-	// Lockbook path or ID to delete.
+	// Delete permanently instead of moving to the trash.
+	//
+	// clap:opt permanent,p
+	permanent bool
+	// Print what would be deleted without deleting anything.
+	//
+	// clap:opt dry-run,n
+	dryRun bool
+	// Lockbook paths, IDs or glob patterns to delete.
 	//
 	// clap:arg_required
-	target string
+	targets []string
 }
 
 func (c *rmCmd) run(core lockbook.Core) error {
-	targets := []string{c.target} // todo(steve): support multiple targets in the command
-	ids := make([]lockbook.FileID, len(targets))
-	for i, t := range targets {
-		id, err := idFromSomething(core, t)
-		if err != nil {
-			return fmt.Errorf("trying to get id from %q: %w", t, err)
+	var trash *lockbook.Trash
+	if !c.permanent {
+		t, err := lockbook.OpenTrash(core)
+		if err != nil && !errors.Is(err, lockbook.ErrTrashDisabled) {
+			return fmt.Errorf("opening trash: %w", err)
 		}
-		ids[i] = id
+		trash = t
 	}
-	for i, id := range ids {
-		f, err := core.FileByID(id)
-		if err != nil {
-			return fmt.Errorf("file by id %q: %w", id, err)
+	targets, err := resolveTargets(core, c.targets)
+	if err != nil {
+		return err
+	}
+	targets = withoutNestedTargets(targets)
+
+	tree, err := lockbook.LoadTree(core)
+	if err != nil {
+		return fmt.Errorf("loading files in order to count children: %w", err)
+	}
+	var toTrash, toDelete []target
+	numChildren := 0
+	for _, t := range targets {
+		if trash != nil && !trash.Contains(&t.file) {
+			toTrash = append(toTrash, t)
+		} else {
+			toDelete = append(toDelete, t)
 		}
-		if !c.force {
-			phrase := fmt.Sprintf("delete %q", id)
-			if t := targets[i]; t != id.String() {
-				phrase += " (target: " + t + ")"
-			}
-			if f.IsDir() {
-				children, err := core.GetAndGetChildrenRecursively(id)
-				if err != nil {
-					return fmt.Errorf("getting all children in order to count: %w", err)
-				}
-				phrase += fmt.Sprintf(" and its %d children", len(children))
-			}
-			answer := ""
-			fmt.Printf("are you sure you want to %s? [y/N]: ", phrase)
-			fmt.Scanln(&answer)
-			if answer != "y" && answer != "Y" {
-				fmt.Println("aborted.")
-				continue
+		numChildren += tree.SubtreeSize(t.file.ID)
+	}
+	if c.dryRun {
+		for _, t := range toTrash {
+			fmt.Printf("would move %s to the trash\n", t.path)
+		}
+		for _, t := range toDelete {
+			fmt.Printf("would delete %s\n", t.path)
+		}
+		return nil
+	}
+	if !c.force {
+		if len(targets) > 1 {
+			for _, t := range targets {
+				fmt.Println("  " + t.path)
 			}
 		}
-		err = core.DeleteFile(id)
+		var actions []string
+		if len(toTrash) > 0 {
+			actions = append(actions, "move "+describeTargets(toTrash)+" to the trash")
+		}
+		if len(toDelete) > 0 {
+			actions = append(actions, "permanently delete "+describeTargets(toDelete))
+		}
+		q := "are you sure you want to " + strings.Join(actions, " and ")
+		if numChildren > 0 {
+			q += fmt.Sprintf(" (including %d files within folders)", numChildren)
+		}
+		if !confirm(q + "?") {
+			fmt.Println("aborted.")
+			return nil
+		}
+	}
+
+	// Files in folders that were shared with us can't be moved into our trash.
+	var untrashable []target
+	for _, t := range toTrash {
+		_, err := trash.Move(t.file.ID)
+		if lockbook.IsTrashUnsupported(err) {
+			untrashable = append(untrashable, t)
+			continue
+		}
 		if err != nil {
-			return fmt.Errorf("deleting file %q: %w", id, err)
+			return err
+		}
+		if len(t.file.Shares) > 0 {
+			fmt.Printf("note: %s stays shared until it's deleted from the trash\n", t.path)
+		}
+	}
+	if len(untrashable) > 0 && !c.force {
+		for _, t := range untrashable {
+			fmt.Fprintf(os.Stderr, "%s can't be moved to the trash\n", t.path)
+		}
+		if confirm("permanently delete " + describeTargets(untrashable) + " instead?") {
+			toDelete = append(toDelete, untrashable...)
+			untrashable = nil
 		}
 	}
+	for _, t := range toDelete {
+		if err := core.DeleteFile(t.file.ID); err != nil {
+			return fmt.Errorf("deleting file %q: %w", t.path, err)
+		}
+	}
+	if len(untrashable) > 0 {
+		paths := make([]string, len(untrashable))
+		for i, t := range untrashable {
+			paths[i] = t.path
+		}
+		return fmt.Errorf("can't move %s to the trash (use --permanent to delete them)", strings.Join(paths, ", "))
+	}
 	return nil
 }
 
+// withoutNestedTargets leaves out targets that are within another targeted folder.
+func withoutNestedTargets(targets []target) []target {
+	kept := make([]target, 0, len(targets))
+	for _, t := range targets {
+		isNested := false
+		for _, other := range targets {
+			if other.file.IsDir() && other.file.ID != t.file.ID && strings.HasPrefix(t.path, other.path) {
+				isNested = true
+				break
+			}
+		}
+		if !isNested {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func describeTargets(targets []target) string {
+	if len(targets) == 1 {
+		return fmt.Sprintf("%q", targets[0].path)
+	}
+	return fmt.Sprintf("%d files", len(targets))
+}
+
 // Write data from stdin to a lockbook document.
 //
 // clap:cmd_usage [--trunc] <target>
@@ -237,6 +365,9 @@ func (c *writeCmd) run(core lockbook.Core) error {
 	if err != nil {
 		return fmt.Errorf("trying to get an id from %q: %w", c.target, err)
 	}
+	if id, err = resolveLinkID(core, id); err != nil {
+		return err
+	}
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		return fmt.Errorf("trying to read from stdin: %w", err)
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// Search for files by name, type, modification and sharing.
+//
+// All predicates must match for a file to be listed. Numeric values take an optional '+'
+// (more than) or '-' (less than) prefix. For example, '-mtime -7' means modified within
+// the last 7 days and '-size +10k' means larger than 10 KiB. The command given to -exec
+// is run with 'sh -c' where each {} is replaced with the (quoted) path.
+//
+// clap:cmd_usage [options] [path]
+type findCmd struct {
+	// Only match files whose name matches this glob pattern.
+	//
+	// clap:opt name
+	name string
+	// Only match files of this type: d (folder), f (document) or l (link).
+	//
+	// clap:opt type
+	typ string
+	// Only match files last modified this many days ago (or another unit such as 12h).
+	//
+	// clap:opt mtime
+	mtime string
+	// Only match files that were last modified by this user.
+	//
+	// clap:opt modified-by
+	modifiedBy string
+	// Only match files that are shared with this user.
+	//
+	// clap:opt shared-with
+	sharedWith string
+	// Only match files of this size in bytes (or with a k, M or G suffix).
+	//
+	// clap:opt size
+	size string
+	// Run a command for each match instead of printing it.
+	//
+	// clap:opt exec
+	exec string
+	// Print IDs instead of paths.
+	//
+	// clap:opt ids
+	ids bool
+	// Separate results with a null character instead of a newline.
+	//
+	// clap:opt print0
+	print0 bool
+	// The output format: text or json.
+	//
+	// clap:opt format
+	format string
+	// Path or ID of the folder to search (defaults to root).
+	target string
+}
+
+type findResult struct {
+	ID        lockbook.FileID `json:"id"`
+	Path      string          `json:"path"`
+	Type      string          `json:"type"`
+	Lastmod   time.Time       `json:"lastmod"`
+	LastmodBy string          `json:"lastmod_by"`
+	Size      *uint64         `json:"size,omitempty"`
+	Shares    []findShare     `json:"shares,omitempty"`
+}
+
+type findShare struct {
+	By   string `json:"by"`
+	With string `json:"with"`
+	Mode string `json:"mode"`
+}
+
+// findPredicate reports whether a file (with its size, if known) matches.
+type findPredicate func(f *lockbook.File, size uint64) bool
+
+func (c *findCmd) run(core lockbook.Core) error {
+	switch c.format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown format %q (expected text or json)", c.format)
+	}
+	preds, err := c.predicates()
+	if err != nil {
+		return err
+	}
+
+	start, err := core.GetRoot()
+	if err != nil {
+		return fmt.Errorf("getting root: %w", err)
+	}
+	if c.target != "" {
+		id, err := idFromSomething(core, c.target)
+		if err != nil {
+			return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+		}
+		if start, err = core.FileByID(id); err != nil {
+			return fmt.Errorf("file by id %q: %w", id, err)
+		}
+	}
+	startPath, err := core.PathByID(start.ID)
+	if err != nil {
+		return fmt.Errorf("path by id %q: %w", start.ID, err)
+	}
+	files, err := core.GetAndGetChildrenRecursively(start.ID)
+	if err != nil {
+		return fmt.Errorf("getting files under %q: %w", startPath, err)
+	}
+
+	var sizes map[lockbook.FileID]uint64
+	if c.size != "" || c.format == "json" {
+		u, err := core.GetUsage()
+		switch {
+		case err == nil:
+			sizes = make(map[lockbook.FileID]uint64, len(u.Usages))
+			for _, fu := range u.Usages {
+				sizes[fu.FileID] = fu.SizeBytes
+			}
+		case c.size != "":
+			return fmt.Errorf("getting usage: %w", err)
+		}
+	}
+
+	paths := findPaths(start, startPath, files)
+	var results []findResult
+	for i := range files {
+		f := &files[i]
+		p, ok := paths[f.ID]
+		if !ok {
+			continue
+		}
+		size, hasSize := sizes[f.ID]
+		matches := true
+		for _, pred := range preds {
+			if !pred(f, size) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		r := findResult{
+			ID:        f.ID,
+			Path:      p,
+			Type:      findTypeName(f.Type),
+			Lastmod:   f.Lastmod,
+			LastmodBy: f.LastmodBy,
+		}
+		if hasSize {
+			r.Size = &size
+		}
+		for _, sh := range f.Shares {
+			r.Shares = append(r.Shares, findShare{By: sh.SharedBy, With: sh.SharedWith, Mode: sh.Mode.String()})
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+
+	switch {
+	case c.exec != "":
+		return c.runExec(results)
+	case c.format == "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if results == nil {
+			results = []findResult{}
+		}
+		return enc.Encode(results)
+	}
+	sep := "\n"
+	if c.print0 {
+		sep = "\x00"
+	}
+	for _, r := range results {
+		fmt.Print(c.resultString(&r), sep)
+	}
+	return nil
+}
+
+func (c *findCmd) resultString(r *findResult) string {
+	if c.ids {
+		return r.ID.String()
+	}
+	return r.Path
+}
+
+func (c *findCmd) runExec(results []findResult) error {
+	numFailed := 0
+	for _, r := range results {
+		script := strings.ReplaceAll(c.exec, "{}", shellQuote(c.resultString(&r)))
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return fmt.Errorf("running command: %w", err)
+			}
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("command failed for %d of %d files", numFailed, len(results))
+	}
+	return nil
+}
+
+// findPaths returns the path of every file within the start folder. The trash is left
+// out unless the search starts within it.
+func findPaths(start lockbook.File, startPath string, files []lockbook.File) map[lockbook.FileID]string {
+	children := make(map[lockbook.FileID][]*lockbook.File, len(files))
+	for i := range files {
+		f := &files[i]
+		if f.ID != start.ID {
+			children[f.Parent] = append(children[f.Parent], f)
+		}
+	}
+	paths := make(map[lockbook.FileID]string, len(files))
+	paths[start.ID] = startPath
+	var walk func(id lockbook.FileID, dir string)
+	walk = func(id lockbook.FileID, dir string) {
+		for _, ch := range children[id] {
+			if start.IsRoot() && ch.Parent == start.ID && ch.Name == lockbook.TrashDirName {
+				continue
+			}
+			p := dir + ch.Name
+			if ch.IsDir() {
+				p += "/"
+				paths[ch.ID] = p
+				walk(ch.ID, p)
+				continue
+			}
+			paths[ch.ID] = p
+		}
+	}
+	walk(start.ID, startPath)
+	return paths
+}
+
+func (c *findCmd) predicates() ([]findPredicate, error) {
+	var preds []findPredicate
+	if c.name != "" {
+		if _, err := path.Match(c.name, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", c.name, err)
+		}
+		preds = append(preds, func(f *lockbook.File, _ uint64) bool {
+			ok, _ := path.Match(c.name, f.Name)
+			return ok
+		})
+	}
+	if c.typ != "" {
+		var want string
+		switch c.typ {
+		case "d":
+			want = "folder"
+		case "f":
+			want = "document"
+		case "l":
+			want = "link"
+		default:
+			return nil, fmt.Errorf("unknown type %q (expected d, f or l)", c.typ)
+		}
+		preds = append(preds, func(f *lockbook.File, _ uint64) bool {
+			return findTypeName(f.Type) == want
+		})
+	}
+	if c.mtime != "" {
+		cmp, v := splitCmpPrefix(c.mtime)
+		n, unit, err := parseMtime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mtime %q", c.mtime)
+		}
+		age := time.Duration(n) * unit
+		now := time.Now()
+		preds = append(preds, func(f *lockbook.File, _ uint64) bool {
+			d := now.Sub(f.Lastmod)
+			switch cmp {
+			case '+':
+				return d > age
+			case '-':
+				return d < age
+			default:
+				// The same number of whole units ago (like find's -mtime with days).
+				return int64(d/unit) == n
+			}
+		})
+	}
+	if c.modifiedBy != "" {
+		preds = append(preds, func(f *lockbook.File, _ uint64) bool {
+			return f.LastmodBy == c.modifiedBy
+		})
+	}
+	if c.sharedWith != "" {
+		preds = append(preds, func(f *lockbook.File, _ uint64) bool {
+			for _, sh := range f.Shares {
+				if sh.SharedWith == c.sharedWith {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	if c.size != "" {
+		cmp, v := splitCmpPrefix(c.size)
+		n, unit, err := parseSize(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", c.size)
+		}
+		preds = append(preds, func(_ *lockbook.File, size uint64) bool {
+			switch cmp {
+			case '+':
+				return size > n*unit
+			case '-':
+				return size < n*unit
+			default:
+				// Sizes are rounded up to the unit (like find's -size).
+				return (size+unit-1)/unit == n
+			}
+		})
+	}
+	return preds, nil
+}
+
+// splitCmpPrefix separates an optional leading '+' or '-' from the value.
+func splitCmpPrefix(s string) (byte, string) {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		return s[0], s[1:]
+	}
+	return 0, s
+}
+
+// parseMtime parses a number of days such as "7", or a number with a unit suffix (s, m,
+// h, d or w) such as "12h", into the number and its unit.
+func parseMtime(s string) (n int64, unit time.Duration, err error) {
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	unit = 24 * time.Hour
+	if s != "" {
+		if u, ok := units[s[len(s)-1]]; ok {
+			unit, s = u, s[:len(s)-1]
+		}
+	}
+	n, err = strconv.ParseInt(s, 10, 64)
+	return n, unit, err
+}
+
+// parseSize parses a size such as "512", "10k", "3M" or "1G" into a number and its unit.
+func parseSize(s string) (n, unit uint64, err error) {
+	unit = 1
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'c':
+			s = s[:len(s)-1]
+		case 'k', 'K':
+			unit, s = 1<<10, s[:len(s)-1]
+		case 'M':
+			unit, s = 1<<20, s[:len(s)-1]
+		case 'G':
+			unit, s = 1<<30, s[:len(s)-1]
+		}
+	}
+	n, err = strconv.ParseUint(s, 10, 64)
+	return n, unit, err
+}
+
+func findTypeName(t lockbook.FileType) string {
+	switch t.(type) {
+	case lockbook.FileTypeFolder:
+		return "folder"
+	case lockbook.FileTypeLink:
+		return "link"
+	default:
+		return "document"
+	}
+}
+
+// shellQuote quotes a string for use as a single word in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/lockbook-x/go-lockbook/history"
+)
+
+const diffContextLines = 3
+
+// List the locally recorded revisions of a document or prune old revisions.
+//
+// A revision is recorded right before a document is overwritten by lbcli, lbgui or a
+// sync. Revision numbers can be used with 'show', 'diff' and 'restore'.
+//
+// clap:cmd_usage [--prune [--keep <n>] [--older-than <age>]] [target]
+type historyCmd struct {
+	// Remove revisions beyond the retention limits (of the target or of all documents).
+	//
+	// clap:opt prune,p
+	prune bool
+	// Keep at most this many revisions of each document when pruning.
+	//
+	// clap:opt keep,k
+	keep string
+	// Remove revisions recorded longer ago than this (such as 30d, 2w or 12h) when pruning.
+	//
+	// clap:opt older-than,o
+	olderThan string
+	// Lockbook file path or ID.
+	target string
+}
+
+func (c *historyCmd) run(core *history.Core) error {
+	if c.prune {
+		return c.runPrune(core)
+	}
+	if c.target == "" {
+		return fmt.Errorf("a target is required unless pruning")
+	}
+	id, err := idFromSomething(core, c.target)
+	if err != nil {
+		return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+	}
+	revs, err := core.Store.Revisions(id)
+	if err != nil {
+		return fmt.Errorf("getting revisions of %q: %w", c.target, err)
+	}
+	if len(revs) == 0 {
+		fmt.Println("no revisions recorded")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "rev\trecorded\tlast modified\tby\tsize\treason")
+	for i := len(revs) - 1; i >= 0; i-- {
+		r := &revs[i]
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", r.N,
+			r.Recorded.Local().Format("2006-01-02 15:04:05"),
+			r.Lastmod.Local().Format("2006-01-02 15:04:05"),
+			r.LastmodBy, humanBytes(int64(r.Size)), r.Reason)
+	}
+	return tw.Flush()
+}
+
+func (c *historyCmd) runPrune(core *history.Core) error {
+	var p history.Policy
+	if c.keep != "" {
+		n, err := strconv.Atoi(c.keep)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of revisions to keep %q", c.keep)
+		}
+		p.MaxRevisions = n
+	}
+	if c.olderThan != "" {
+		d, err := parseAge(c.olderThan)
+		if err != nil {
+			return err
+		}
+		p.MaxAge = d
+	}
+	if p.MaxRevisions == 0 && p.MaxAge == 0 {
+		p = core.Store.Policy
+	}
+	var ids []lockbook.FileID
+	if c.target != "" {
+		id, err := idFromSomething(core, c.target)
+		if err != nil {
+			return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+		}
+		ids = append(ids, id)
+	}
+	n, err := core.Store.Prune(p, ids...)
+	if err != nil {
+		return fmt.Errorf("pruning history: %w", err)
+	}
+	fmt.Printf("removed %d revisions\n", n)
+	return nil
+}
+
+// Print the content of a document at a recorded revision.
+type showCmd struct {
+	// Lockbook file path or ID followed by '@' and a revision number.
+	//
+	// clap:arg_required
+	target string
+}
+
+func (c *showCmd) run(core *history.Core) error {
+	id, n, err := parseRevTarget(core, c.target)
+	if err != nil {
+		return err
+	}
+	data, err := core.Store.Read(id, n)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s", data)
+	return nil
+}
+
+// Show the changes between a recorded revision and a document's current content.
+type diffCmd struct {
+	// Lockbook file path or ID.
+	//
+	// clap:arg_required
+	target string
+	// The revision number to compare against (default is the latest).
+	rev string
+}
+
+func (c *diffCmd) run(core *history.Core) error {
+	id, err := idFromSomething(core, c.target)
+	if err != nil {
+		return fmt.Errorf("trying to get id from %q: %w", c.target, err)
+	}
+	var n int
+	if c.rev != "" {
+		if n, err = strconv.Atoi(c.rev); err != nil {
+			return fmt.Errorf("invalid revision %q", c.rev)
+		}
+	} else {
+		revs, err := core.Store.Revisions(id)
+		if err != nil {
+			return fmt.Errorf("getting revisions of %q: %w", c.target, err)
+		}
+		if len(revs) == 0 {
+			return fmt.Errorf("no revisions recorded for %q", c.target)
+		}
+		n = revs[len(revs)-1].N
+	}
+	old, err := core.Store.Read(id, n)
+	if err != nil {
+		return err
+	}
+	cur, err := core.ReadDocument(id)
+	if err != nil {
+		return fmt.Errorf("reading doc %q: %w", c.target, err)
+	}
+	fmt.Printf("--- %s@%d\n+++ %s\n", c.target, n, c.target)
+	for _, line := range unifiedDiff(splitLines(string(old)), splitLines(string(cur))) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Replace a document's content with a recorded revision.
+//
+// The content being replaced is recorded as a new revision, so a restore can be undone.
+type restoreCmd struct {
+	// Lockbook file path or ID followed by '@' and a revision number.
+	//
+	// clap:arg_required
+	target string
+}
+
+func (c *restoreCmd) run(core *history.Core) error {
+	id, n, err := parseRevTarget(core, c.target)
+	if err != nil {
+		return err
+	}
+	if err := core.Restore(id, n); err != nil {
+		return fmt.Errorf("restoring %q: %w", c.target, err)
+	}
+	return nil
+}
+
+// parseRevTarget splits a "<target>@<rev>" value into a file ID and revision number.
+func parseRevTarget(core lockbook.Core, v string) (lockbook.FileID, int, error) {
+	i := strings.LastIndexByte(v, '@')
+	if i == -1 {
+		return lockbook.FileID{}, 0, fmt.Errorf("%q is missing a revision (<target>@<rev>)", v)
+	}
+	n, err := strconv.Atoi(v[i+1:])
+	if err != nil {
+		return lockbook.FileID{}, 0, fmt.Errorf("invalid revision %q", v[i+1:])
+	}
+	id, err := idFromSomething(core, v[:i])
+	if err != nil {
+		return lockbook.FileID{}, 0, fmt.Errorf("trying to get id from %q: %w", v[:i], err)
+	}
+	return id, n, nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines returns the fewest line insertions and deletions turning `a` into `b`. It
+// uses the linear space form of Myers' O(ND) algorithm, so comparing large documents
+// that differ in a few places stays cheap.
+func diffLines(a, b []string) []diffOp {
+	d := differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.ops
+}
+
+// differ holds the state of one `diffLines` call.
+type differ struct {
+	a, b   []string
+	ops    []diffOp
+	vf, vb []int // the furthest reaching forward and backward paths, by diagonal
+}
+
+// diff appends the edits turning `a[a0:a1]` into `b[b0:b1]`.
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.ops = append(d.ops, diffOp{' ', d.a[a0]})
+		a0++
+		b0++
+	}
+	var suf int
+	for a0 < a1-suf && b0 < b1-suf && d.a[a1-1-suf] == d.b[b1-1-suf] {
+		suf++
+	}
+	a1 -= suf
+	b1 -= suf
+
+	switch {
+	case a0 == a1:
+		for _, l := range d.b[b0:b1] {
+			d.ops = append(d.ops, diffOp{'+', l})
+		}
+	case b0 == b1:
+		for _, l := range d.a[a0:a1] {
+			d.ops = append(d.ops, diffOp{'-', l})
+		}
+	default:
+		// With the common ends trimmed off, there are at least two edits here, so both
+		// halves around the middle snake are smaller than the whole.
+		x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+		d.diff(a0, x, b0, y)
+		for _, l := range d.a[x:u] {
+			d.ops = append(d.ops, diffOp{' ', l})
+		}
+		d.diff(u, a1, v, b1)
+	}
+
+	for _, l := range d.a[a1 : a1+suf] {
+		d.ops = append(d.ops, diffOp{' ', l})
+	}
+}
+
+// middleSnake searches for the shortest edit script between `a[a0:a1]` and `b[b0:b1]`
+// from both ends at once, and returns the start and end of the diagonal run (snake)
+// where the two searches meet.
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta&1 != 0
+	maxD := (n + m + 1) / 2
+	off := maxD + 1
+	if len(d.vf) < 2*off+1 {
+		d.vf = make([]int, 2*off+1)
+		d.vb = make([]int, 2*off+1)
+	}
+	// The backward search runs forward over the reversed lines, so its diagonal `kr`
+	// is the forward diagonal `delta-kr`.
+	d.vf[off+1] = 0
+	d.vb[off+1] = 0
+	for D := 0; D <= maxD; D++ {
+		for k := -D; k <= D; k += 2 {
+			var px int
+			if k == -D || (k != D && d.vf[off+k-1] < d.vf[off+k+1]) {
+				px = d.vf[off+k+1]
+			} else {
+				px = d.vf[off+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && d.a[a0+px] == d.b[b0+py] {
+				px++
+				py++
+			}
+			d.vf[off+k] = px
+			if kr := delta - k; odd && kr >= -(D-1) && kr <= D-1 && px+d.vb[off+kr] >= n {
+				return a0 + sx, b0 + sy, a0 + px, b0 + py
+			}
+		}
+		for kr := -D; kr <= D; kr += 2 {
+			var px int
+			if kr == -D || (kr != D && d.vb[off+kr-1] < d.vb[off+kr+1]) {
+				px = d.vb[off+kr+1]
+			} else {
+				px = d.vb[off+kr-1] + 1
+			}
+			py := px - kr
+			sx, sy := px, py
+			for px < n && py < m && d.a[a1-1-px] == d.b[b1-1-py] {
+				px++
+				py++
+			}
+			d.vb[off+kr] = px
+			if k := delta - kr; !odd && k >= -D && k <= D && px+d.vf[off+k] >= n {
+				return a1 - px, b1 - py, a1 - sx, b1 - sy
+			}
+		}
+	}
+	panic("unreachable: the searches always meet by the middle")
+}
+
+// unifiedDiff returns the lines of a unified diff (without the file headers) between
+// `a` and `b`.
+func unifiedDiff(a, b []string) []string {
+	ops := diffLines(a, b)
+	var out []string
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContextLines {
+				break
+			}
+		}
+		lo := start - diffContextLines
+		if lo < 0 {
+			lo = 0
+		}
+		hi := end + diffContextLines
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+
+		// Line numbers of the hunk's first line in each side.
+		aLine, bLine := 1, 1
+		for _, op := range ops[:lo] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		var aLen, bLen int
+		lines := make([]string, 0, hi-lo)
+		for _, op := range ops[lo:hi] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+			lines = append(lines, string(op.kind)+op.line)
+		}
+		if aLen == 0 {
+			aLine--
+		}
+		if bLen == 0 {
+			bLine--
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aLine, aLen, bLine, bLen))
+		out = append(out, lines...)
+		start = hi
+	}
+	return out
+}
@@ -2,22 +2,32 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/lockbook-x/go-lockbook/drawing"
+	"github.com/steverusso/lockbook-x/go-lockbook/importer"
 )
 
 // Import files into lockbook from your system.
 //
 // clap:cmd_name import
 type importCmd struct {
+	// Convert an export from another app (obsidian|notion|evernote). The disk path is
+	// an Obsidian vault, a Notion zip export, or an Evernote .enex file respectively.
+	//
+	// clap:opt from,f
+	from string
 	// Don't output progress on each file.
 	//
 	// clap:opt quiet,q
 	quiet bool
-	// The file to import into lockbook.
+	// The file to import into lockbook. Zip and tarball (.tar.gz) archives are
+	// extracted.
 	//
 	// clap:arg_required
 	diskPath string
@@ -56,6 +66,9 @@ func (c *importCmd) run(core lockbook.Core) error {
 			switch {
 			case info.Total != 0:
 				total = info.Total
+			case info.DiskPath != "" && total == 0:
+				count++
+				fmt.Printf("(%d) %s ... ", count, info.DiskPath)
 			case info.DiskPath != "":
 				count++
 				fmt.Printf("(%d/%d) %s ... ", count, total, info.DiskPath)
@@ -64,6 +77,24 @@ func (c *importCmd) run(core lockbook.Core) error {
 			}
 		}
 	}
+	if c.from != "" {
+		destPath, err := core.PathByID(destID)
+		if err != nil {
+			return fmt.Errorf("getting path of %q: %w", destID, err)
+		}
+		return importFrom(core, c.from, c.diskPath, destPath, forEach)
+	}
+	if archiveFmt, ok := lockbook.ArchiveFormatFromPath(c.diskPath); ok {
+		f, err := os.Open(c.diskPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := lockbook.ImportArchive(core, f, archiveFmt, destID, forEach); err != nil {
+			return fmt.Errorf("importing archive '%s': %w", c.diskPath, err)
+		}
+		return nil
+	}
 	err = core.ImportFile(c.diskPath, destID, forEach)
 	if err != nil {
 		return fmt.Errorf("importing '%s': %w", c.diskPath, err)
@@ -71,12 +102,38 @@ func (c *importCmd) run(core lockbook.Core) error {
 	return nil
 }
 
+func importFrom(core lockbook.Core, from, diskPath, dest string, fn func(lockbook.ImportFileInfo)) error {
+	var imprt func(lockbook.Core, string, string, func(lockbook.ImportFileInfo)) error
+	switch strings.ToLower(from) {
+	case "obsidian":
+		imprt = importer.Obsidian
+	case "notion":
+		imprt = importer.Notion
+	case "evernote":
+		imprt = importer.Evernote
+	default:
+		return fmt.Errorf("unknown import source %q (expected obsidian, notion or evernote)", from)
+	}
+	if err := imprt(core, diskPath, dest, fn); err != nil {
+		return fmt.Errorf("importing %s from %s: %w", diskPath, from, err)
+	}
+	return nil
+}
+
 // Copy a lockbook file to your file system.
 //
 // clap:cmd_usage [--quiet] <target> [dest-dir]
 // clap:cmd_usage [--img-fmt <fmt>] <drawing> [dest-dir]
+// clap:cmd_usage [--img-fmt <fmt>] <folder> [dest-dir]
+// clap:cmd_usage [--archive <fmt>] <target> [dest]
 type exportCmd struct {
-	// Format for exporting a lockbook drawing (png|jpeg|pnm|tga|farbfeld|bmp).
+	// Write the target (and everything in it) to a single archive (zip|tgz). The
+	// archive goes to stdout if it's piped and there's no destination.
+	//
+	// clap:opt archive,a
+	archive string
+	// Format for exporting lockbook drawings (png|jpeg|pnm|tga|farbfeld|bmp|svg). If the
+	// target is a folder, every drawing in it is exported.
 	//
 	// clap:opt img-fmt,i
 	imgFmt string
@@ -97,18 +154,21 @@ func (c *exportCmd) run(core lockbook.Core) error {
 	if err != nil {
 		return fmt.Errorf("trying to get an id from %q: %w", c.target, err)
 	}
+	if id, err = resolveLinkID(core, id); err != nil {
+		return err
+	}
 	f, err := core.FileByID(id)
 	if err != nil {
 		return fmt.Errorf("file by id %q: %w", id, err)
 	}
-	// Check if we're explicitly exporting a lockbook drawing to an image.
-	if !f.IsDir() && filepath.Ext(f.Name) == "draw" && c.imgFmt != "" {
-		if err := exportDrawing(core, id, c.imgFmt); err != nil {
-			return fmt.Errorf("exporting drawing: %w", err)
-		}
-		return nil
+	if c.archive != "" {
+		return c.exportArchive(core, f)
 	}
+	// Check if we're explicitly exporting lockbook drawings to images.
 	if c.imgFmt != "" {
+		if f.IsDir() || isDrawing(f.Name) {
+			return c.exportDrawings(core, f)
+		}
 		fmt.Fprintln(os.Stderr, "ignoring '--img-fmt' option because target is not a drawing")
 	}
 	// If no destination path is provided, it'll be a file with the target name in the
@@ -142,29 +202,173 @@ func (c *exportCmd) run(core lockbook.Core) error {
 	return nil
 }
 
-func exportDrawing(core lockbook.Core, id lockbook.FileID, imgFmt string) error {
-	var imgFmtCode lockbook.ImageFormat
-	switch imgFmt {
-	case "png":
-		imgFmtCode = lockbook.ImgFmtPNG
-	case "jpg", "jpeg":
-		imgFmtCode = lockbook.ImgFmtJPEG
-	case "pnm":
-		imgFmtCode = lockbook.ImgFmtPNM
-	case "tga":
-		imgFmtCode = lockbook.ImgFmtTGA
-	case "farbfeld":
-		imgFmtCode = lockbook.ImgFmtFarbfeld
-	case "bmp":
-		imgFmtCode = lockbook.ImgFmtBMP
-	default:
-		fmt.Printf("unknown image format %q, defaulting to png...", imgFmt)
+// exportArchive writes the target to an archive. The destination can be a file path or
+// an existing directory, in which case the archive is named after the target.
+func (c *exportCmd) exportArchive(core lockbook.Core, f lockbook.File) error {
+	archiveFmt, err := lockbook.ParseArchiveFormat(c.archive)
+	if err != nil {
+		return err
 	}
+	if c.dest == "" && isStdoutPipe() {
+		return lockbook.ExportArchive(core, f.ID, os.Stdout, archiveFmt)
+	}
+	name := f.Name
+	if f.IsRoot() {
+		acct, err := core.GetAccount()
+		if err != nil {
+			return fmt.Errorf("getting account: %w", err)
+		}
+		name = acct.Username
+	}
+	dest := c.dest
+	if dest == "" {
+		dest = "."
+	}
+	if fi, err := os.Stat(dest); err == nil && fi.IsDir() {
+		dest = filepath.Join(dest, strings.TrimSuffix(name, path.Ext(name))+archiveFmt.Ext())
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := lockbook.ExportArchive(core, f.ID, out, archiveFmt); err != nil {
+		out.Close()
+		return fmt.Errorf("exporting archive: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if !c.quiet {
+		fmt.Println(dest)
+	}
+	return nil
+}
 
-	data, err := core.ExportDrawing(id, imgFmtCode)
+func isDrawing(name string) bool {
+	return path.Ext(name) == ".draw"
+}
+
+// exportDrawings writes the target drawing, or every drawing within the target folder, as
+// an image in the destination directory. A single drawing goes to stdout if it's piped
+// and there's no destination. Drawings in a folder keep their structure relative to it.
+func (c *exportCmd) exportDrawings(core lockbook.Core, f lockbook.File) error {
+	x, err := newDrawingExporter(core, c.imgFmt)
 	if err != nil {
-		return fmt.Errorf("exporting drawing: %w", err)
+		return err
+	}
+	if !f.IsDir() {
+		if c.dest == "" && isStdoutPipe() {
+			return x.toWriter(f.ID, os.Stdout)
+		}
+		if c.dest == "" {
+			c.dest = "."
+		}
+		if err := os.MkdirAll(c.dest, 0o755); err != nil {
+			return fmt.Errorf("creating directory %s: %w", c.dest, err)
+		}
+		return x.toFile(f.ID, filepath.Join(c.dest, x.imageName(f.Name)))
+	}
+	if c.dest == "" {
+		c.dest = "."
+	}
+	dirPath, err := core.PathByID(f.ID)
+	if err != nil {
+		return fmt.Errorf("getting path of %q: %w", f.ID, err)
+	}
+	dirPath = strings.TrimSuffix(dirPath, "/") + "/"
+
+	files, err := core.GetAndGetChildrenRecursively(f.ID)
+	if err != nil {
+		return fmt.Errorf("getting children of %q: %w", f.ID, err)
+	}
+	n := 0
+	for _, child := range files {
+		if child.IsDir() || !isDrawing(child.Name) {
+			continue
+		}
+		lbPath, err := core.PathByID(child.ID)
+		if err != nil {
+			return fmt.Errorf("getting path of %q: %w", child.ID, err)
+		}
+		relDir := path.Dir(strings.TrimPrefix(lbPath, dirPath))
+		outDir := filepath.Join(c.dest, filepath.FromSlash(relDir))
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return fmt.Errorf("creating directory %s: %w", outDir, err)
+		}
+		outPath := filepath.Join(outDir, x.imageName(child.Name))
+		if err := x.toFile(child.ID, outPath); err != nil {
+			return fmt.Errorf("%s: %w", lbPath, err)
+		}
+		if !c.quiet {
+			fmt.Printf("%s -> %s\n", lbPath, outPath)
+		}
+		n++
+	}
+	if n == 0 {
+		fmt.Fprintf(os.Stderr, "no drawings found in %s\n", dirPath)
 	}
-	fmt.Print(data)
 	return nil
 }
+
+// drawingExporter writes drawings as images in a given format. SVGs are rendered
+// natively since lockbook-core can only output raster images.
+type drawingExporter struct {
+	core   lockbook.Core
+	isSVG  bool
+	imgFmt lockbook.ImageFormat
+	ext    string
+}
+
+func newDrawingExporter(core lockbook.Core, fmtName string) (drawingExporter, error) {
+	if strings.EqualFold(fmtName, "svg") {
+		return drawingExporter{core: core, isSVG: true, ext: ".svg"}, nil
+	}
+	imgFmt, err := lockbook.ParseImageFormat(fmtName)
+	if err != nil {
+		return drawingExporter{}, err
+	}
+	return drawingExporter{core: core, imgFmt: imgFmt, ext: imgFmt.Ext()}, nil
+}
+
+// imageName returns the drawing's name with the image format's extension.
+func (x drawingExporter) imageName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + x.ext
+}
+
+func (x drawingExporter) toFile(id lockbook.FileID, fpath string) error {
+	if !x.isSVG {
+		if err := x.core.ExportDrawingToDisk(id, x.imgFmt, fpath); err != nil {
+			return fmt.Errorf("exporting drawing to %s: %w", fpath, err)
+		}
+		return nil
+	}
+	out, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	if err := x.toWriter(id, out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (x drawingExporter) toWriter(id lockbook.FileID, w io.Writer) error {
+	if x.isSVG {
+		data, err := x.core.ReadDocument(id)
+		if err != nil {
+			return fmt.Errorf("reading drawing: %w", err)
+		}
+		d, err := drawing.Parse(data)
+		if err != nil {
+			return err
+		}
+		return drawing.WriteSVG(w, d, drawing.Options{})
+	}
+	data, err := x.core.ExportDrawing(id, x.imgFmt)
+	if err != nil {
+		return fmt.Errorf("exporting drawing: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
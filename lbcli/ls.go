@@ -106,22 +106,23 @@ func (fn *fileNode) printOut(cfg *lsConfig) {
 	}
 }
 
-func getChildren(core lockbook.Core, files []lockbook.File, parent lockbook.FileID, cfg *lsConfig) ([]fileNode, error) {
-	children := []fileNode{}
+func getChildren(tree *lockbook.Tree, parent lockbook.FileID, recursive bool, cfg *lsConfig) ([]fileNode, error) {
+	files := tree.Children(parent)
+	children := make([]fileNode, 0, len(files))
 	for i := range files {
 		f := &files[i]
-		if f.Parent != parent {
-			continue
-		}
 		// File name.
 		name := f.Name
 		if f.IsDir() {
 			name += "/"
 		}
+		if _, ok := f.Type.(lockbook.FileTypeLink); ok {
+			name += " -> " + linkText(tree, *f)
+		}
 		// Parent directory.
 		dirName := ""
 		if cfg.paths {
-			fpath, err := core.PathByID(f.ID)
+			fpath, err := tree.Path(f.ID)
 			if err != nil {
 				return nil, fmt.Errorf("getting path for %q: %w", f.ID, err)
 			}
@@ -145,16 +146,33 @@ func getChildren(core lockbook.Core, files []lockbook.File, parent lockbook.File
 			isDir:   f.IsDir(),
 			shared:  getShareInfo(f.Shares, cfg.myName),
 		}
-		childsChildren, err := getChildren(core, files, f.ID, cfg)
-		if err != nil {
-			return nil, fmt.Errorf("getting children for %q: %w", f.ID, err)
+		if recursive {
+			childsChildren, err := getChildren(tree, f.ID, recursive, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("getting children for %q: %w", f.ID, err)
+			}
+			child.children = childsChildren
 		}
-		child.children = childsChildren
 		children = append(children, child)
 	}
 	return children, nil
 }
 
+// linkText describes where a link points, such as "/notes/ (@alice)".
+func linkText(tree *lockbook.Tree, f lockbook.File) string {
+	lt, err := tree.DescribeLink(f)
+	switch {
+	case lockbook.IsDanglingLink(err):
+		return "(dangling)"
+	case err != nil:
+		return "(error: " + err.Error() + ")"
+	}
+	if lt.Owner == "" {
+		return lt.Path
+	}
+	return lt.Path + " (@" + lt.Owner + ")"
+}
+
 type branch int
 
 const (
@@ -216,15 +234,13 @@ type lsShareInfo struct {
 }
 
 func getShareInfo(shares []lockbook.Share, myName string) lsShareInfo {
-	by := ""
+	info := lockbook.GetShareInfo(shares, myName)
 	withs := make([]string, 0, len(shares))
-	for _, sh := range shares {
-		if sh.SharedWith == myName {
-			by = sh.SharedBy
-			withs = append(withs, "me")
-		} else {
-			withs = append(withs, "@"+sh.SharedWith)
-		}
+	if info.WithMe {
+		withs = append(withs, "me")
+	}
+	for _, uname := range info.SharedWith {
+		withs = append(withs, "@"+uname)
 	}
 	sort.SliceStable(withs, func(i, j int) bool {
 		return len(withs[i]) < len(withs[j])
@@ -237,7 +253,7 @@ func getShareInfo(shares []lockbook.Share, myName string) lsShareInfo {
 	} else if n != 0 {
 		with = fmt.Sprintf("%s, %s, and %d more", withs[0], withs[1], n-2)
 	}
-	return lsShareInfo{by: by, with: with}
+	return lsShareInfo{by: info.SharedBy, with: with}
 }
 
 func (ls *lsCmd) run(core lockbook.Core) error {
@@ -251,32 +267,16 @@ func (ls *lsCmd) run(core lockbook.Core) error {
 	if err != nil {
 		return fmt.Errorf("trying to get target from %q: %w", ls.target, err)
 	}
-	f, err := core.FileByID(id)
+	tree, err := lockbook.LoadTree(core)
 	if err != nil {
-		return fmt.Errorf("getting file by id %q: %w", id, err)
+		return err
 	}
-
-	var files []lockbook.File
-	if ls.recursive {
-		files, err = core.GetAndGetChildrenRecursively(f.ID)
-		if err != nil {
-			return fmt.Errorf("getting children recursively for %q: %w", f.ID, err)
-		}
-	} else {
-		files, err = core.GetChildren(f.ID)
-		if err != nil {
-			return fmt.Errorf("getting children for %q: %w", f.ID, err)
-		}
+	f, ok := tree.File(id)
+	if !ok {
+		return fmt.Errorf("file %q not found", ls.target)
 	}
-	lockbook.SortFiles(files)
-
-	if f.IsRoot() {
-		for i := range files {
-			if files[i].IsRoot() {
-				files = append(files[:i], files[i+1:]...)
-				break
-			}
-		}
+	if f, err = tree.ResolveLink(f); err != nil {
+		return fmt.Errorf("resolving link %q: %w", ls.target, err)
 	}
 
 	acct, err := core.GetAccount()
@@ -297,7 +297,7 @@ func (ls *lsCmd) run(core lockbook.Core) error {
 		onlyDocs: ls.onlyDocs,
 		fullIDs:  ls.fullIDs,
 	}
-	infos, err := getChildren(core, files, f.ID, &cfg)
+	infos, err := getChildren(tree, f.ID, ls.recursive, &cfg)
 	if err != nil {
 		return fmt.Errorf("getting child nodes: %w", err)
 	}
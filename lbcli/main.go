@@ -5,34 +5,51 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/lockbook-x/go-lockbook/history"
 )
 
-const idPrefixLen = 8
+const (
+	idPrefixLen        = 8
+	daemonSyncInterval = 30 * time.Second
+	maxOfflineInterval = 10 * time.Minute
+)
 
 //go:generate goclap -type lbcli
 
 // An unofficial lockbook cli.
 type lbcli struct {
-	acct   *acctCmd
-	cat    *catCmd
-	debug  *debugCmd
-	export *exportCmd
-	imprt  *importCmd
-	jot    *jotCmd
-	ls     *lsCmd
-	mkdir  *mkdirCmd
-	mkdoc  *mkdocCmd
-	mv     *mvCmd
-	rename *renameCmd
-	rm     *rmCmd
-	share  *shareCmd
-	sync   *syncCmd
-	usage  *usageCmd
-	write  *writeCmd
+	acct      *acctCmd
+	backup    *backupCmd
+	cat       *catCmd
+	conflicts *conflictsCmd
+	debug     *debugCmd
+	diff      *diffCmd
+	du        *duCmd
+	export    *exportCmd
+	find      *findCmd
+	hist      *historyCmd
+	imprt     *importCmd
+	jot       *jotCmd
+	ls        *lsCmd
+	mkdir     *mkdirCmd
+	mkdoc     *mkdocCmd
+	mv        *mvCmd
+	rename    *renameCmd
+	restore   *restoreCmd
+	rm        *rmCmd
+	share     *shareCmd
+	show      *showCmd
+	sync      *syncCmd
+	trash     *trashCmd
+	usage     *usageCmd
+	write     *writeCmd
 }
 
 // Get updates from the server and push changes.
@@ -41,36 +58,291 @@ type syncCmd struct {
 	//
 	// clap:opt status,s
 	status bool
-	// Output every sync step and progress.
+	// Output every sync step, then a table of the pushed and pulled documents.
 	//
 	// clap:opt verbose,v
 	verbose bool
+	// Keep running and sync periodically, backing off while the server is unreachable.
+	//
+	// clap:opt daemon,d
+	daemon bool
+	// Print what a sync would push and pull without syncing.
+	//
+	// clap:opt dry-run,n
+	dryRun bool
+	// Only push local changes within this folder.
+	//
+	// clap:opt only
+	only string
+	// Hold back local changes to files matching this glob (and in folders matching it).
+	//
+	// clap:opt exclude
+	exclude string
+	// Save --only and --exclude so that later writes outside of them are held back until
+	// they're selected by a sync (without them, clear the saved filter).
+	//
+	// clap:opt persist
+	persist bool
 }
 
-func (c *syncCmd) run(core lockbook.Core) error {
+func (c *syncCmd) run(core lockbook.Core, dc *lockbook.DeferredCore, cc *lockbook.ConflictCore) error {
 	if c.status {
-		if err := printSyncStatus(core); err != nil {
+		if err := printSyncStatus(core, dc); err != nil {
 			return fmt.Errorf("getting sync status: %w", err)
 		}
 		return nil
 	}
+	var filter lockbook.SyncFilter
+	if c.only != "" {
+		filter.Only = []string{c.only}
+	}
+	if c.exclude != "" {
+		filter.Exclude = []string{c.exclude}
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	saved, err := dc.Filter()
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.persist && !c.dryRun:
+		if err := dc.SetFilter(filter); err != nil {
+			return fmt.Errorf("saving sync filter: %w", err)
+		}
+	case filter.IsZero() && !c.persist:
+		// A sync without its own filter keeps to the saved one.
+		filter = saved
+		if !filter.IsZero() {
+			fmt.Fprintf(os.Stderr, "note: using the saved sync filter (%s), see 'sync --status'\n", describeFilter(filter))
+		}
+	}
+	if c.dryRun {
+		return printSyncPlan(dc, filter)
+	}
+	if !filter.IsZero() {
+		if err := warnUnfilterable(dc, filter); err != nil {
+			return err
+		}
+	}
+	// The held back writes this filter selects are flushed before the sync calculates its
+	// work. Any others are left for a later sync.
+	if _, err := dc.Flush(filter); err != nil {
+		return fmt.Errorf("flushing held back writes: %w", err)
+	}
+	defer printDeferred(dc)
+	defer printConflictsNotice(cc)
 	var syncProgress func(lockbook.SyncProgress)
 	if c.verbose {
 		syncProgress = func(sp lockbook.SyncProgress) {
-			fmt.Printf("(%d/%d) %s\n", sp.Progress, sp.Total, sp.Msg)
+			what := sp.Stage.String()
+			if sp.Path != "" {
+				what += " " + sp.Path
+			} else if sp.Name != "" {
+				what += " " + sp.Name
+			}
+			fmt.Printf("(%d/%d) %s\n", sp.Progress, sp.Total, what)
 		}
 	}
-	err := core.SyncAll(syncProgress)
+	if c.daemon {
+		return syncDaemon(core, syncProgress)
+	}
+	if !c.verbose {
+		if err := core.SyncAll(nil); err != nil {
+			return fmt.Errorf("syncing: %w", err)
+		}
+		return nil
+	}
+	docs, err := lockbook.SyncAllDetailed(core, syncProgress)
 	if err != nil {
 		return fmt.Errorf("syncing: %w", err)
 	}
-	if c.verbose {
-		fmt.Println("done")
+	printSyncedDocs(docs)
+	fmt.Println("done")
+	return nil
+}
+
+// printSyncedDocs prints a table of the documents that were pushed or pulled. Documents
+// that may have been mistaken for another with the same name are marked with "(?)".
+func printSyncedDocs(docs []lockbook.SyncProgress) {
+	if len(docs) == 0 {
+		return
+	}
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "op\tpath")
+	for _, sp := range docs {
+		op := "push"
+		if sp.Stage.IsPull() {
+			op = "pull"
+		}
+		p := sp.Path
+		if sp.Ambiguous {
+			p += " (?)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", op, p)
+	}
+	tw.Flush()
+}
+
+// syncDaemon syncs every `daemonSyncInterval` until the process is stopped. When the
+// server can't be reached, it goes into offline mode where it only probes the server
+// (with exponential backoff) and resumes syncing as soon as a probe succeeds. Repeated
+// errors are only printed once.
+func syncDaemon(core lockbook.Core, syncProgress func(lockbook.SyncProgress)) error {
+	backoff := lockbook.Backoff{Min: daemonSyncInterval, Max: maxOfflineInterval}
+	isOffline := false
+	numQueued := -1
+	lastErr := ""
+	for {
+		var err error
+		if isOffline {
+			err = lockbook.Ping(core)
+		}
+		if err == nil {
+			err = core.SyncAll(syncProgress)
+		}
+		wait := daemonSyncInterval
+		now := time.Now().Format("15:04:05")
+		switch {
+		case lockbook.IsConnectivityError(err):
+			n, err := lockbook.CountLocalChanges(core)
+			if err != nil {
+				return fmt.Errorf("counting local changes: %w", err)
+			}
+			if !isOffline || n != numQueued {
+				fmt.Printf("%s offline — about %d local changes queued\n", now, n)
+			}
+			isOffline = true
+			numQueued = n
+			wait = backoff.Next()
+		case err != nil:
+			if msg := err.Error(); msg != lastErr {
+				fmt.Fprintf(os.Stderr, "%s \033[1;31merror:\033[0m syncing: %v\n", now, err)
+				lastErr = msg
+			}
+		default:
+			if isOffline {
+				fmt.Printf("%s back online\n", now)
+			}
+			isOffline = false
+			numQueued = -1
+			lastErr = ""
+			backoff.Reset()
+		}
+		time.Sleep(wait)
+	}
+}
+
+// printSyncPlan prints every change a sync would push or pull, marking the local changes
+// that the filter leaves out, followed by the writes that are currently held back.
+func printSyncPlan(dc *lockbook.DeferredCore, filter lockbook.SyncFilter) error {
+	// Plan from beneath the deferred writes since those aren't part of the sync.
+	plan, err := lockbook.PlanSync(dc.Core, filter)
+	if err != nil {
+		return err
+	}
+	deferred, err := dc.Deferred()
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Println("nothing to sync")
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "op\tsize\tlastmod by\tpath\t")
+		for _, ch := range plan {
+			op := "push"
+			if ch.Type == lockbook.WorkUnitTypeServer {
+				op = "pull"
+			}
+			size := "-"
+			if ch.Size >= 0 {
+				size = humanBytes(ch.Size)
+			}
+			lastmodBy := ch.LastmodBy
+			if lastmodBy == "" {
+				lastmodBy = "-"
+			}
+			note := ""
+			if !ch.Selected {
+				note = "(excluded, already written)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", op, size, lastmodBy, ch.Path, note)
+		}
+		tw.Flush()
+	}
+	var flushed, held []lockbook.DeferredWrite
+	for _, w := range deferred {
+		if filter.Match(w.Path) {
+			flushed = append(flushed, w)
+		} else {
+			held = append(held, w)
+		}
+	}
+	for _, w := range flushed {
+		fmt.Printf("held back write would be pushed: %s (%s)\n", w.Path, humanBytes(int64(w.Size)))
+	}
+	for _, w := range held {
+		fmt.Printf("held back write would stay held back: %s (%s)\n", w.Path, humanBytes(int64(w.Size)))
+	}
+	return nil
+}
+
+// warnUnfilterable warns about the local changes outside of the filter that were already
+// written to the core before they could be held back, since they'll be pushed anyway.
+func warnUnfilterable(dc *lockbook.DeferredCore, filter lockbook.SyncFilter) error {
+	plan, err := lockbook.PlanSync(dc.Core, filter)
+	if err != nil {
+		return err
+	}
+	for _, ch := range plan {
+		if ch.Type == lockbook.WorkUnitTypeLocal && !ch.Selected {
+			fmt.Fprintf(os.Stderr, "warning: %s is excluded but was already changed, so it will be pushed\n", ch.Path)
+		}
 	}
 	return nil
 }
 
-func printSyncStatus(core lockbook.Core) error {
+// printConflictsNotice points out any unresolved sync conflicts.
+func printConflictsNotice(cc *lockbook.ConflictCore) {
+	conflicts, err := cc.Conflicts()
+	if err != nil || len(conflicts) == 0 {
+		return
+	}
+	fmt.Printf("%d unresolved conflict(s), see 'conflicts list':\n", len(conflicts))
+	for _, cf := range conflicts {
+		fmt.Printf("  %s (local copy: %s)\n", cf.Path, cf.CopyPath)
+	}
+}
+
+// printDeferred reports the writes that are still being held back after a sync.
+func printDeferred(dc *lockbook.DeferredCore) {
+	deferred, err := dc.Deferred()
+	if err != nil || len(deferred) == 0 {
+		return
+	}
+	fmt.Printf("skipped %d held back write(s):\n", len(deferred))
+	for _, w := range deferred {
+		fmt.Printf("  %s (%s)\n", w.Path, humanBytes(int64(w.Size)))
+	}
+}
+
+// describeFilter returns a short description of a sync filter, such as "only notes,
+// excluding **/*.tmp".
+func describeFilter(f lockbook.SyncFilter) string {
+	var parts []string
+	if len(f.Only) > 0 {
+		parts = append(parts, "only "+strings.Join(f.Only, ", "))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, "excluding "+strings.Join(f.Exclude, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printSyncStatus(core lockbook.Core, dc *lockbook.DeferredCore) error {
 	wc, err := core.CalculateWork()
 	if err != nil {
 		return fmt.Errorf("calculating work: %w", err)
@@ -87,6 +359,20 @@ func printSyncStatus(core lockbook.Core) error {
 		return fmt.Errorf("getting last synced human string: %w", err)
 	}
 	fmt.Printf("last synced: %s\n", lastSyncedAt)
+	filter, err := dc.Filter()
+	if err != nil {
+		return err
+	}
+	if !filter.IsZero() {
+		fmt.Printf("saved sync filter: %s (writes outside of it are held back, clear it with 'sync --persist')\n", describeFilter(filter))
+	}
+	deferred, err := dc.Deferred()
+	if err != nil {
+		return err
+	}
+	for _, w := range deferred {
+		fmt.Printf("%s is held back (%s)\n", w.Path, humanBytes(int64(w.Size)))
+	}
 	return nil
 }
 
@@ -166,6 +452,20 @@ func idFromSomething(core lockbook.Core, v string) (uuid.UUID, error) {
 	return uuid.Nil, errors.New(errMsg)
 }
 
+// resolveLinkID returns the ID of the file a link points to, or the ID itself if it
+// isn't a link.
+func resolveLinkID(core lockbook.Core, id lockbook.FileID) (lockbook.FileID, error) {
+	f, err := core.FileByID(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("file by id %q: %w", id, err)
+	}
+	target, err := lockbook.ResolveLink(core, f)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("resolving link %q: %w", f.Name, err)
+	}
+	return target.ID, nil
+}
+
 func asLbErr(err error) (*lockbook.Error, bool) {
 	var lberr *lockbook.Error
 	if errors.As(err, &lberr) {
@@ -174,6 +474,102 @@ func asLbErr(err error) (*lockbook.Error, bool) {
 	return nil, false
 }
 
+// target is a file resolved from a command line argument.
+type target struct {
+	arg  string
+	path string
+	file lockbook.File
+}
+
+// resolveTargets resolves each argument (a path, ID, ID prefix or glob pattern) into the
+// files it refers to. Each file is only included once, and a glob pattern that doesn't
+// match anything is an error.
+func resolveTargets(core lockbook.Core, args []string) ([]target, error) {
+	var targets []target
+	seen := make(map[lockbook.FileID]bool)
+	add := func(t target) {
+		if !seen[t.file.ID] {
+			seen[t.file.ID] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, arg := range args {
+		if lockbook.HasGlobMeta(arg) {
+			matches, err := lockbook.Glob(core, arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) > 0 {
+				for _, m := range matches {
+					add(target{arg: arg, path: m.Path, file: m.File})
+				}
+				continue
+			}
+			// The argument might just be a name with special characters in it.
+			if _, exists, err := lockbook.MaybeFileByPath(core, arg); err != nil || !exists {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		id, err := idFromSomething(core, arg)
+		if err != nil {
+			return nil, fmt.Errorf("trying to get id from %q: %w", arg, err)
+		}
+		f, err := core.FileByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("file by id %q: %w", id, err)
+		}
+		p, err := core.PathByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("path by id %q: %w", id, err)
+		}
+		add(target{arg: arg, path: p, file: f})
+	}
+	return targets, nil
+}
+
+// humanBytes formats a number of bytes with a binary unit suffix (such as "3.4 MiB").
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseAge parses a duration such as "30d", "2w" or anything `time.ParseDuration`
+// accepts.
+func parseAge(s string) (time.Duration, error) {
+	if n := len(s); n > 1 && (s[n-1] == 'd' || s[n-1] == 'w') {
+		v, err := strconv.Atoi(s[:n-1])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		day := 24 * time.Hour
+		if s[n-1] == 'w' {
+			return time.Duration(v) * 7 * day, nil
+		}
+		return time.Duration(v) * day, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// confirm asks a yes or no question and reports whether the answer was yes.
+func confirm(question string) bool {
+	answer := ""
+	fmt.Printf("%s [y/N]: ", question)
+	fmt.Scanln(&answer)
+	return answer == "y" || answer == "Y"
+}
+
 func isStdinPipe() bool {
 	fi, err := os.Stdin.Stat()
 	if err != nil {
@@ -212,11 +608,35 @@ func run() error {
 		dataDir = filepath.Join(home, ".lockbook/lbcli")
 	}
 
-	// Initialize a new lockbook Core instance.
-	core, err := lockbook.NewCore(dataDir)
+	// Initialize a new lockbook Core instance (or a replay of a recorded trace).
+	lbCore, closeCore, err := openCore(dataDir)
 	if err != nil {
-		return fmt.Errorf("initializing core: %v", err)
+		return err
+	}
+	defer closeCore()
+	// Record metrics about each call into the core, logging the slow ones if asked to.
+	var instOpts lockbook.InstrumentOptions
+	if v := os.Getenv(slowCallEnv); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("parsing $%s: %w", slowCallEnv, err)
+		}
+		instOpts.SlowThreshold = d
+	}
+	instCore := lockbook.Instrument(lbCore, instOpts)
+	if os.Getenv(statsEnv) != "" {
+		defer func() { printStats(os.Stderr, instCore.Stats.Snapshot()) }()
+	}
+	// Serve metadata lookups from memory, save conflict copies of local changes replaced
+	// by a sync, hold back writes excluded from a selective sync, and record the prior
+	// revisions of documents before they're overwritten. Conflict copies are saved below
+	// the held back writes so that they're never held back themselves.
+	conflictCore := lockbook.DetectConflicts(lockbook.NewCachedCore(instCore))
+	deferCore := lockbook.DeferWrites(conflictCore)
+	deferCore.OnDefer = func(w lockbook.DeferredWrite) {
+		fmt.Fprintf(os.Stderr, "note: holding back the write to %s until a sync selects it (see 'sync --dry-run')\n", w.Path)
 	}
+	core := history.Wrap(deferCore, history.Open(lbCore))
 
 	lb := lbcli{}
 	lb.Parse(os.Args)
@@ -238,12 +658,24 @@ func run() error {
 	switch {
 	case lb.acct != nil:
 		return lb.acct.run(core)
+	case lb.backup != nil:
+		return lb.backup.run(core)
 	case lb.cat != nil:
 		return lb.cat.run(core)
+	case lb.conflicts != nil:
+		return lb.conflicts.run(core, conflictCore)
 	case lb.debug != nil:
 		return lb.debug.run(core)
+	case lb.diff != nil:
+		return lb.diff.run(core)
+	case lb.du != nil:
+		return lb.du.run(core)
 	case lb.export != nil:
 		return lb.export.run(core)
+	case lb.find != nil:
+		return lb.find.run(core)
+	case lb.hist != nil:
+		return lb.hist.run(core)
 	case lb.imprt != nil:
 		return lb.imprt.run(core)
 	case lb.jot != nil:
@@ -258,12 +690,18 @@ func run() error {
 		return lb.mv.run(core)
 	case lb.rename != nil:
 		return lb.rename.run(core)
+	case lb.restore != nil:
+		return lb.restore.run(core)
 	case lb.rm != nil:
 		return lb.rm.run(core)
 	case lb.share != nil:
 		return lb.share.run(core)
+	case lb.show != nil:
+		return lb.show.run(core)
 	case lb.sync != nil:
-		return lb.sync.run(core)
+		return lb.sync.run(core, deferCore, conflictCore)
+	case lb.trash != nil:
+		return lb.trash.run(core)
 	case lb.usage != nil:
 		return lb.usage.run(core)
 	case lb.write != nil:
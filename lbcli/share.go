@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/gofrs/uuid"
 	"github.com/steverusso/lockbook-x/go-lockbook"
@@ -17,6 +19,8 @@ type shareCmd struct {
 	pending *sharePendingCmd
 	accept  *shareAcceptCmd
 	reject  *shareRejectCmd
+	list    *shareListCmd
+	audit   *shareAuditCmd
 }
 
 func (s *shareCmd) run(core lockbook.Core) error {
@@ -29,6 +33,10 @@ func (s *shareCmd) run(core lockbook.Core) error {
 		return s.accept.run(core)
 	case s.reject != nil:
 		return s.reject.run(core)
+	case s.list != nil:
+		return s.list.run(core)
+	case s.audit != nil:
+		return s.audit.run(core)
 	default:
 		return nil
 	}
@@ -194,6 +202,117 @@ func (c *shareRejectCmd) run(core lockbook.Core) error {
 	return nil
 }
 
+// List the files you've shared and that have been shared with you, grouped by user.
+//
+// clap:cmd_usage [--by-me | --with-me]
+type shareListCmd struct {
+	// Only list the files you've shared with others.
+	//
+	// clap:opt by-me
+	byMe bool
+	// Only list the files others have shared with you.
+	//
+	// clap:opt with-me
+	withMe bool
+}
+
+func (c *shareListCmd) run(core lockbook.Core) error {
+	if c.byMe && c.withMe {
+		return errors.New("--by-me and --with-me can't be used together")
+	}
+	acct, err := core.GetAccount()
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+	shared, err := lockbook.ListShares(core)
+	if err != nil {
+		return err
+	}
+
+	// Group the shares by the other user involved.
+	byUser := map[string][]lockbook.SharedFile{}
+	for _, sf := range shared {
+		byMe := sf.Share.SharedBy == acct.Username
+		withMe := sf.Share.SharedWith == acct.Username
+		if (c.byMe && !byMe) || (c.withMe && !withMe) {
+			continue
+		}
+		other := sf.Share.SharedWith
+		if withMe {
+			other = sf.Share.SharedBy
+		}
+		byUser[other] = append(byUser[other], sf)
+	}
+	if len(byUser) == 0 {
+		fmt.Println("no shares")
+		return nil
+	}
+	users := make([]string, 0, len(byUser))
+	for u := range byUser {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, u := range users {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "@%s\n", u)
+		for _, sf := range byUser[u] {
+			var dir string
+			switch {
+			case sf.Share.SharedBy == acct.Username:
+				dir = "shared by me"
+			case sf.Share.SharedWith == acct.Username:
+				dir = "shared with me"
+			default:
+				dir = "shared by @" + sf.Share.SharedBy
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", strings.ToLower(sf.Share.Mode.String()), sf.Path, dir)
+		}
+	}
+	return tw.Flush()
+}
+
+// Flag the files you've shared that might deserve a second look.
+//
+// Write shares are flagged because the other user can change or delete the file. Shares
+// of files within an already shared folder are flagged because they're either redundant
+// or give someone access to only part of a folder that others can see in full.
+type shareAuditCmd struct{}
+
+func (c *shareAuditCmd) run(core lockbook.Core) error {
+	acct, err := core.GetAccount()
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+	shared, err := lockbook.ListShares(core)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	numIssues := 0
+	for _, sf := range shared {
+		if sf.Share.SharedBy != acct.Username {
+			continue
+		}
+		if sf.Share.Mode == lockbook.ShareModeWrite {
+			fmt.Fprintf(tw, "%s\t@%s\twrite access\n", sf.Path, sf.Share.SharedWith)
+			numIssues++
+		}
+		if sf.InSharedFolder {
+			fmt.Fprintf(tw, "%s\t@%s\twithin a shared folder\n", sf.Path, sf.Share.SharedWith)
+			numIssues++
+		}
+	}
+	if numIssues == 0 {
+		fmt.Println("no issues found")
+		return nil
+	}
+	return tw.Flush()
+}
+
 func getOnePendingShareMatch(shares []lockbook.File, id string) (lockbook.File, error) {
 	matches := []lockbook.File{}
 	for _, f := range shares {
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// Manage deleted files in the trash.
+//
+// The trash is opt-in. Once enabled, 'rm' moves files into the hidden /.trash/ folder
+// where they can be restored until the trash is emptied.
+type trashCmd struct {
+	enable  *trashEnableCmd
+	list    *trashListCmd
+	restore *trashRestoreCmd
+	empty   *trashEmptyCmd
+}
+
+func (c *trashCmd) run(core lockbook.Core) error {
+	switch {
+	case c.enable != nil:
+		return c.enable.run(core)
+	case c.list != nil:
+		return c.list.run(core)
+	case c.restore != nil:
+		return c.restore.run(core)
+	case c.empty != nil:
+		return c.empty.run(core)
+	default:
+		return nil
+	}
+}
+
+func openTrash(core lockbook.Core) (*lockbook.Trash, error) {
+	t, err := lockbook.OpenTrash(core)
+	if errors.Is(err, lockbook.ErrTrashDisabled) {
+		return nil, errors.New("the trash isn't enabled (run 'trash enable')")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening trash: %w", err)
+	}
+	return t, nil
+}
+
+// Start moving deleted files to the trash.
+type trashEnableCmd struct{}
+
+func (c *trashEnableCmd) run(core lockbook.Core) error {
+	if _, err := lockbook.EnableTrash(core); err != nil {
+		return err
+	}
+	fmt.Println("deleted files will be moved to /" + lockbook.TrashDirName + "/")
+	return nil
+}
+
+// List the files in the trash.
+type trashListCmd struct {
+	// Show full file IDs instead of prefixes.
+	//
+	// clap:opt ids
+	fullIDs bool
+}
+
+func (c *trashListCmd) run(core lockbook.Core) error {
+	t, err := openTrash(core)
+	if err != nil {
+		return err
+	}
+	entries, err := t.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("the trash is empty")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "id\tdeleted\toriginal path")
+	for _, e := range entries {
+		id := e.ID.String()
+		if !c.fullIDs {
+			id = id[:idPrefixLen]
+		}
+		origPath := e.OrigPath
+		if origPath == "" {
+			origPath = "(unknown) " + e.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", id, e.Deleted.Local().Format("2006-01-02 15:04"), origPath)
+	}
+	return tw.Flush()
+}
+
+// Move a file out of the trash back to where it was deleted from.
+type trashRestoreCmd struct {
+	// The ID, ID prefix or original path of the trashed file.
+	//
+	// clap:arg_required
+	target string
+}
+
+func (c *trashRestoreCmd) run(core lockbook.Core) error {
+	t, err := openTrash(core)
+	if err != nil {
+		return err
+	}
+	id, err := trashEntryID(t, c.target)
+	if err != nil {
+		return err
+	}
+	p, err := t.Restore(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s\n", p)
+	return nil
+}
+
+// trashEntryID finds a trashed file by its ID, ID prefix or original path.
+func trashEntryID(t *lockbook.Trash, v string) (lockbook.FileID, error) {
+	entries, err := t.List()
+	if err != nil {
+		return lockbook.FileID{}, err
+	}
+	var matches []lockbook.TrashEntry
+	for _, e := range entries {
+		if e.OrigPath == v || (v != "" && strings.HasPrefix(e.ID.String(), v)) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return lockbook.FileID{}, fmt.Errorf("no file in the trash matches %q", v)
+	case 1:
+		return matches[0].ID, nil
+	default:
+		return lockbook.FileID{}, fmt.Errorf("%q matches %d files in the trash", v, len(matches))
+	}
+}
+
+// Permanently delete the files in the trash.
+//
+// clap:cmd_usage [-f] [--older-than <age>]
+type trashEmptyCmd struct {
+	// Don't prompt for confirmation.
+	//
+	// clap:opt force,f
+	force bool
+	// Only delete files that were trashed longer ago than this (such as 30d, 2w or 12h).
+	//
+	// clap:opt older-than,o
+	olderThan string
+}
+
+func (c *trashEmptyCmd) run(core lockbook.Core) error {
+	var age time.Duration
+	if c.olderThan != "" {
+		d, err := parseAge(c.olderThan)
+		if err != nil {
+			return err
+		}
+		age = d
+	}
+	t, err := openTrash(core)
+	if err != nil {
+		return err
+	}
+	if !c.force {
+		q := "permanently delete everything in the trash?"
+		if age > 0 {
+			q = "permanently delete everything trashed more than " + c.olderThan + " ago?"
+		}
+		if !confirm(q) {
+			fmt.Println("aborted.")
+			return nil
+		}
+	}
+	deleted, err := t.Empty(age)
+	fmt.Printf("deleted %d files\n", len(deleted))
+	return err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+type (
+	conflictsLoaded struct {
+		conflicts []lockbook.Conflict
+		err       error
+	}
+	conflictResolved struct {
+		conflict lockbook.Conflict
+		res      lockbook.ConflictResolution
+		err      error
+	}
+)
+
+func (conflictsLoaded) implsWsUpdate()  {}
+func (conflictResolved) implsWsUpdate() {}
+
+// conflictBanner is shown above the workspace while there are unresolved sync conflicts.
+// It shows the oldest one with buttons to open or resolve it.
+type conflictBanner struct {
+	openBtn       widget.Clickable
+	keepSyncedBtn widget.Clickable
+	keepLocalBtn  widget.Clickable
+	keepBothBtn   widget.Clickable
+}
+
+func loadConflicts(cc *lockbook.ConflictCore) conflictsLoaded {
+	conflicts, err := cc.Conflicts()
+	if err != nil {
+		return conflictsLoaded{err: fmt.Errorf("getting conflicts: %w", err)}
+	}
+	return conflictsLoaded{conflicts: conflicts}
+}
+
+func (ws *workspace) setConflicts(u conflictsLoaded) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, u.err)
+		return
+	}
+	ws.conflicts = u.conflicts
+}
+
+// resolveConflict resolves a conflict through the given (history recording) core.
+func resolveConflict(core lockbook.Core, cc *lockbook.ConflictCore, updates chan<- legitUpdate, cf lockbook.Conflict, res lockbook.ConflictResolution) {
+	u := conflictResolved{conflict: cf, res: res}
+	u.conflict, u.err = cc.Resolve(core, cf.ID, res)
+	updates <- u
+}
+
+func (ws *workspace) handleConflictResolved(u conflictResolved) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, fmt.Errorf("resolving conflict: %w", u.err))
+		return
+	}
+	for i := range ws.conflicts {
+		if ws.conflicts[i].ID == u.conflict.ID {
+			ws.conflicts = append(ws.conflicts[:i], ws.conflicts[i+1:]...)
+			break
+		}
+	}
+	name := path.Base(u.conflict.Path)
+	switch u.res {
+	case lockbook.KeepSynced:
+		ws.handleFilesDeleted(filesDeleted{ids: []lockbook.FileID{u.conflict.CopyID}})
+		ws.botStatus = fmt.Sprintf("Kept the synced %q", name)
+	case lockbook.KeepLocal:
+		ws.handleFilesDeleted(filesDeleted{ids: []lockbook.FileID{u.conflict.CopyID}})
+		ws.botStatus = fmt.Sprintf("Restored your version of %q", name)
+	case lockbook.KeepBoth:
+		ws.botStatus = fmt.Sprintf("Kept both versions of %q", name)
+	}
+	// The document's content may have changed since its tab was opened.
+	if t := ws.tabByID(u.conflict.ID); t != nil && t.markdown() != nil {
+		go openFile(ws.core, ws.updates, u.conflict.ID, name)
+	}
+}
+
+// layConflictBanner lays out the conflict banner (if there are any conflicts) at the top
+// and then the given widget in the remaining space below it.
+func (ws *workspace) layConflictBanner(gtx C, th *material.Theme, w layout.Widget) D {
+	if len(ws.conflicts) == 0 {
+		return w(gtx)
+	}
+	cf := ws.conflicts[0]
+	b := &ws.conflictBanner
+	if b.openBtn.Clicked() {
+		ws.openFiles([]nameAndID{
+			{name: path.Base(cf.Path), id: cf.ID},
+			{name: path.Base(cf.CopyPath), id: cf.CopyID},
+		})
+	}
+	if b.keepSyncedBtn.Clicked() {
+		go resolveConflict(ws.core, ws.conflictCore, ws.updates, cf, lockbook.KeepSynced)
+	}
+	if b.keepLocalBtn.Clicked() {
+		go resolveConflict(ws.core, ws.conflictCore, ws.updates, cf, lockbook.KeepLocal)
+	}
+	if b.keepBothBtn.Clicked() {
+		go resolveConflict(ws.core, ws.conflictCore, ws.updates, cf, lockbook.KeepBoth)
+	}
+
+	msg := fmt.Sprintf("%q was also changed on another device", path.Base(cf.Path))
+	if cf.LastmodBy != "" {
+		msg += " by " + cf.LastmodBy
+	}
+	msg += fmt.Sprintf(". Your version was saved as %q.", path.Base(cf.CopyPath))
+	if n := len(ws.conflicts); n > 1 {
+		msg = fmt.Sprintf("(1 of %d) %s", n, msg)
+	}
+
+	m := op.Record(gtx.Ops)
+	gtx1 := gtx
+	gtx1.Constraints.Min.Y = 0
+	dims := layout.UniformInset(inset).Layout(gtx1, func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx C) D {
+				lbl := material.Body2(th, msg)
+				lbl.MaxLines = 2
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Width: inset}.Layout),
+			layout.Rigid(func(gtx C) D {
+				return toolbarButtons(th).layout(gtx, []groupButton{
+					{click: &b.openBtn, text: "Open Both"},
+					{click: &b.keepSyncedBtn, text: "Keep Synced"},
+					{click: &b.keepLocalBtn, text: "Keep Mine"},
+					{click: &b.keepBothBtn, text: "Keep Both"},
+				})
+			}),
+		)
+	})
+	call := m.Stop()
+
+	bannerSize := image.Pt(gtx.Constraints.Max.X, dims.Size.Y)
+	paint.FillShape(gtx.Ops, color.NRGBA{120, 90, 0, 255}, clip.Rect{Max: bannerSize}.Op())
+	call.Add(gtx.Ops)
+
+	offOp := op.Offset(image.Pt(0, bannerSize.Y)).Push(gtx.Ops)
+	gtx.Constraints.Max.Y -= bannerSize.Y
+	if gtx.Constraints.Min.Y > gtx.Constraints.Max.Y {
+		gtx.Constraints.Min.Y = gtx.Constraints.Max.Y
+	}
+	_ = w(gtx)
+	offOp.Pop()
+	return D{Size: gtx.Constraints.Max.Add(image.Pt(0, bannerSize.Y))}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gioui.org/gesture"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/lockbook-x/go-lockbook/drawing"
+)
+
+const (
+	drawingExt     = ".draw"
+	drawingPadding = 20
+)
+
+func isDrawing(name string) bool {
+	return path.Ext(name) == drawingExt
+}
+
+type (
+	openDrawingResult struct {
+		id      lockbook.FileID
+		img     image.Image
+		lastmod time.Time
+		err     error
+	}
+	drawingExported struct {
+		dest string
+		err  error
+	}
+)
+
+func (openDrawingResult) implsWsUpdate() {}
+func (drawingExported) implsWsUpdate()   {}
+
+// drawingView is the content of a tab for a lockbook drawing. The drawing is rendered
+// natively and displayed with pan & zoom.
+type drawingView struct {
+	img        paint.ImageOp
+	hasImg     bool
+	lastmod    time.Time
+	err        error
+	zoom       zoomView
+	exportBtns []widget.Clickable
+}
+
+func newDrawingView() *drawingView {
+	return &drawingView{
+		exportBtns: make([]widget.Clickable, len(lockbook.ImageFormats)),
+	}
+}
+
+// openDrawing reads and renders the drawing and sends the image as an update. If `since`
+// isn't zero, nothing happens unless the drawing was modified after it.
+func openDrawing(core lockbook.Core, updates chan<- legitUpdate, id lockbook.FileID, since time.Time) {
+	u := openDrawingResult{id: id}
+
+	f, err := core.FileByID(id)
+	if err != nil {
+		u.err = fmt.Errorf("file by id %q: %w", id, err)
+		updates <- u
+		return
+	}
+	if !since.IsZero() && !f.Lastmod.After(since) {
+		return
+	}
+	u.lastmod = f.Lastmod
+
+	data, err := core.ReadDocument(id)
+	if err != nil {
+		u.err = fmt.Errorf("reading drawing %q: %w", id, err)
+		updates <- u
+		return
+	}
+	d, err := drawing.Parse(data)
+	if err != nil {
+		u.err = err
+		updates <- u
+		return
+	}
+	u.img = drawing.Render(d, drawing.Options{
+		Padding:    drawingPadding,
+		Background: color.NRGBA{255, 255, 255, 255},
+	})
+	updates <- u
+}
+
+// refreshDrawings re-renders any open drawings that have changed (such as after a sync).
+func (ws *workspace) refreshDrawings() {
+	for i := range ws.tabs {
+		if dv, ok := ws.tabs[i].content.(*drawingView); ok && dv.hasImg {
+			go openDrawing(ws.core, ws.updates, ws.tabs[i].id, dv.lastmod)
+		}
+	}
+}
+
+func (ws *workspace) setTabDrawing(u openDrawingResult) {
+	t := ws.tabByID(u.id)
+	if t == nil {
+		return
+	}
+	dv, ok := t.content.(*drawingView)
+	if !ok {
+		return
+	}
+	if u.err != nil {
+		dv.err = u.err
+		return
+	}
+	dv.err = nil
+	dv.img = paint.NewImageOp(u.img)
+	dv.hasImg = true
+	dv.lastmod = u.lastmod
+}
+
+func (ws *workspace) layDrawingTab(gtx C, th *material.Theme, t *tab, dv *drawingView) D {
+	for i := range dv.exportBtns {
+		if dv.exportBtns[i].Clicked() {
+			ws.modals = append(ws.modals, newExportDrawingPrompt(t.id, t.name, lockbook.ImageFormats[i]))
+		}
+	}
+
+	btnGrpStyle := toolbarButtons(th)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(inset).Layout(gtx, func(gtx C) D {
+				exportBtns := make([]groupButton, len(dv.exportBtns))
+				for i := range dv.exportBtns {
+					exportBtns[i] = groupButton{
+						click: &dv.exportBtns[i],
+						text:  lockbook.ImageFormats[i].String(),
+					}
+				}
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(material.Body2(th, "Export as…").Layout),
+					layout.Rigid(layout.Spacer{Width: inset}.Layout),
+					layout.Rigid(func(gtx C) D {
+						return btnGrpStyle.layout(gtx, exportBtns)
+					}),
+					layout.Flexed(1, layout.Spacer{}.Layout),
+					layout.Rigid(func(gtx C) D {
+						if !dv.hasImg {
+							return D{}
+						}
+						return dv.zoom.layControls(gtx, th)
+					}),
+				)
+			})
+		}),
+		layout.Rigid(rule{color: th.Fg}.Layout),
+		layout.Flexed(1, func(gtx C) D {
+			switch {
+			case dv.err != nil:
+				return layout.Center.Layout(gtx, material.Body1(th, "error: "+dv.err.Error()).Layout)
+			case !dv.hasImg:
+				return layout.Center.Layout(gtx, material.Loader(th).Layout)
+			default:
+				return dv.zoom.layout(gtx, dv.img, color.NRGBA{255, 255, 255, 255})
+			}
+		}),
+	)
+}
+
+type exportDrawingPrompt struct {
+	id     lockbook.FileID
+	imgFmt lockbook.ImageFormat
+	input  widget.Editor
+	err    error
+}
+
+func newExportDrawingPrompt(id lockbook.FileID, name string, imgFmt lockbook.ImageFormat) *exportDrawingPrompt {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		dir = "."
+	}
+	p := &exportDrawingPrompt{
+		id:     id,
+		imgFmt: imgFmt,
+		input:  widget.Editor{SingleLine: true, Submit: true},
+	}
+	p.input.SetText(filepath.Join(dir, strings.TrimSuffix(name, drawingExt)+imgFmt.Ext()))
+	return p
+}
+
+func (exportDrawingPrompt) implsModal() {}
+
+func exportDrawing(core lockbook.Core, updates chan<- legitUpdate, id lockbook.FileID, imgFmt lockbook.ImageFormat, dest string) {
+	u := drawingExported{dest: dest}
+	defer func() { updates <- u }()
+
+	data, err := core.ExportDrawing(id, imgFmt)
+	if err != nil {
+		u.err = fmt.Errorf("exporting drawing %q: %w", id, err)
+		return
+	}
+	if err = os.WriteFile(dest, data, 0o644); err != nil {
+		u.err = fmt.Errorf("writing %s: %w", dest, err)
+	}
+}
+
+func (ws *workspace) layExportDrawingPrompt(gtx C, th *material.Theme, p *exportDrawingPrompt) D {
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	for _, e := range p.input.Events() {
+		if e, ok := e.(widget.SubmitEvent); ok {
+			dest := strings.TrimSpace(e.Text)
+			if dest == "" {
+				p.err = fmt.Errorf("a destination is required")
+				continue
+			}
+			go exportDrawing(ws.core, ws.updates, p.id, p.imgFmt, dest)
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(material.Body1(th, "Export as "+p.imgFmt.String()+" to:").Layout),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(material.Editor(th, &p.input, "Destination path").Layout),
+			layout.Rigid(func(gtx C) D {
+				if p.err == nil {
+					return D{}
+				}
+				return layout.Inset{Top: 12}.Layout(gtx, material.Body2(th, "error: "+p.err.Error()).Layout)
+			}),
+		)
+	})
+}
+
+// layModalBox centers the given widget in a rounded box with the theme's background.
+func layModalBox(gtx C, th *material.Theme, w layout.Widget) D {
+	gtx1 := gtx
+	gtx1.Constraints.Min.X = 400
+	gtx1.Constraints.Min.Y = 0
+	if gtx1.Constraints.Max.X > 600 {
+		gtx1.Constraints.Max.X = 600
+	}
+	m := op.Record(gtx1.Ops)
+	innerDims := layout.UniformInset(12).Layout(gtx1, w)
+	innerDraw := m.Stop()
+
+	return layout.Center.Layout(gtx, func(gtx C) D {
+		rr := clip.UniformRRect(image.Rectangle{Max: innerDims.Size}, 8)
+		defer rr.Push(gtx.Ops).Pop()
+
+		paint.FillShape(gtx.Ops, th.Bg, rr.Op(gtx.Ops))
+		innerDraw.Add(gtx.Ops)
+		return innerDims
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"strings"
+	"time"
 
 	"gioui.org/gesture"
 	"gioui.org/io/key"
@@ -30,6 +31,10 @@ type fileExplorer struct {
 	homeBtn     widget.Clickable
 	mkdirBtn    widget.Clickable
 	mkdocBtn    widget.Clickable
+	trashBtn    widget.Clickable
+	usageBtn    widget.Clickable
+	shareBtn    widget.Clickable
+	inboxBtn    widget.Clickable
 	bcrumbs     []breadcrumb
 	entries     []fileEntry
 	entryList   widget.List
@@ -149,6 +154,10 @@ func (ex *fileExplorer) layTopbar(gtx C, th *material.Theme) D {
 	endBtnsDims := btnGrpStyle.layout(gtx, []groupButton{
 		{click: &ex.mkdocBtn, icon: &iconNewDoc},
 		{click: &ex.mkdirBtn, icon: &iconNewFolder},
+		{click: &ex.trashBtn, icon: &iconTrash},
+		{click: &ex.usageBtn, icon: &iconUsage},
+		{click: &ex.shareBtn, icon: &iconShare},
+		{click: &ex.inboxBtn, icon: &iconInbox},
 	})
 	drawEndBtns := m.Stop()
 
@@ -278,24 +287,35 @@ func (ws *workspace) openDir(id lockbook.FileID) {
 func (ws *workspace) openFiles(namesAndIDs []nameAndID) {
 	ws.animStage = wsExplClosing
 	for _, v := range namesAndIDs {
-		v := v
-		ws.insertTab(v.id, v.name)
-		go openFile(ws.core, ws.updates, v.id)
+		ws.openTab(v.id, v.name)
 	}
 }
 
-func openFile(core lockbook.Core, updates chan<- legitUpdate, id lockbook.FileID) {
+// openTab inserts a new tab for the given file and starts loading its content.
+func (ws *workspace) openTab(id lockbook.FileID, name string) {
+	ws.insertTab(id, name)
+	if isDrawing(name) {
+		go openDrawing(ws.core, ws.updates, id, time.Time{})
+		return
+	}
+	go openFile(ws.core, ws.updates, id, name)
+}
+
+func openFile(core lockbook.Core, updates chan<- legitUpdate, id lockbook.FileID, name string) {
 	u := openFileResult{id: id}
+	defer func() { updates <- u }()
 
 	u.data, u.err = core.ReadDocument(id)
 	if u.err != nil {
 		u.err = fmt.Errorf("reading doc %q: %w", id, u.err)
+		return
+	}
+	u.kind, u.mimeType = detectContent(name, u.data)
+	if u.kind == contentImage {
+		if u.img, u.decodeErr = decodeImage(u.data); u.decodeErr != nil {
+			u.kind = contentBinary
+		}
 	}
-	updates <- u
-}
-
-func (ws *workspace) deleteSelectedFiles() {
-	// todo(steve): impl
 }
 
 func (ex *fileExplorer) makeSelection(i int, isCtrl, isShift bool) {
@@ -357,6 +377,15 @@ func (ex *fileExplorer) removeEntries(del []lockbook.File) {
 	}
 }
 
+func (ex *fileExplorer) removeID(id lockbook.FileID) {
+	for i := range ex.entries {
+		if ex.entries[i].id == id {
+			ex.entries = append(ex.entries[:i], ex.entries[i+1:]...)
+			return
+		}
+	}
+}
+
 func (ex *fileExplorer) populate(parents []nameAndID, files []lockbook.File) {
 	if cap(ex.bcrumbs) < len(parents) {
 		ex.bcrumbs = make([]breadcrumb, 0, len(parents))
@@ -126,7 +126,8 @@ func (ws *workspace) layEntry(gtx C, th *material.Theme, en *treeEntry, lvl, yOf
 
 	height := 0
 	m := op.Record(gtx.Ops)
-	dims := drawEntry(gtx, th, en, lvl)
+	isSyncing := !ws.syncingID.IsNil() && ws.syncingID == en.file.ID
+	dims := drawEntry(gtx, th, en, lvl, isSyncing)
 	call := m.Stop()
 
 	rrOp := clip.Rect(image.Rectangle{Max: dims.Size}).Push(gtx.Ops)
@@ -156,7 +157,9 @@ func (ws *workspace) layEntry(gtx C, th *material.Theme, en *treeEntry, lvl, yOf
 	return D{Size: image.Pt(gtx.Constraints.Max.X, height)}
 }
 
-func drawEntry(gtx C, th *material.Theme, en *treeEntry, lvl int) D {
+// drawEntry draws a tree entry. Entries that are currently being pushed or pulled by a
+// sync get a sync icon at the end.
+func drawEntry(gtx C, th *material.Theme, en *treeEntry, lvl int, isSyncing bool) D {
 	layIcon := func(gtx C) D {
 		icon := iconDocument
 		if en.file.IsDir() {
@@ -187,6 +190,12 @@ func drawEntry(gtx C, th *material.Theme, en *treeEntry, lvl int) D {
 		layout.Rigid(layIcon),
 		layout.Rigid(layout.Spacer{Width: 10}.Layout),
 		layout.Flexed(1, layName),
+		layout.Rigid(func(gtx C) D {
+			if !isSyncing {
+				return D{}
+			}
+			return iconSync.Layout(gtx, th.ContrastFg)
+		}),
 	)
 }
 
@@ -203,6 +212,24 @@ func (t *fileTree) find(id lockbook.FileID) *treeEntry {
 	return t.root.find(id)
 }
 
+// remove takes the entry for the given file out of the tree.
+func (t *fileTree) remove(id lockbook.FileID) {
+	en := t.find(id)
+	if en == nil || en == &t.root {
+		return
+	}
+	parent := t.find(en.file.Parent)
+	if parent == nil {
+		return
+	}
+	for i := range parent.children {
+		if parent.children[i].file.ID == id {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			return
+		}
+	}
+}
+
 type treeSelection struct {
 	entries []*treeEntry
 }
@@ -287,6 +314,8 @@ type treePopupMenu struct {
 
 	newDoc popupMenuButton
 	newDir popupMenuButton
+	share  popupMenuButton
+	delete popupMenuButton
 }
 
 func (ws *workspace) layoutTreePopup(gtx C, th *material.Theme) D {
@@ -301,6 +330,16 @@ func (ws *workspace) layoutTreePopup(gtx C, th *material.Theme) D {
 		ws.modals = append(ws.modals, newCreateFilePrompt(lockbook.FileTypeFolder{}))
 		return D{}
 	}
+	if pm.share.Pressed() {
+		*pm = treePopupMenu{}
+		ws.shareTreeSelection()
+		return D{}
+	}
+	if pm.delete.Pressed() {
+		*pm = treePopupMenu{}
+		ws.deleteTreeSelection()
+		return D{}
+	}
 
 	width := 200
 	height := 0
@@ -319,10 +358,23 @@ func (ws *workspace) layoutTreePopup(gtx C, th *material.Theme) D {
 		height += dims.Size.Y
 		offOp.Pop()
 	}
+	// share
+	{
+		offOp := op.Offset(image.Pt(0, height)).Push(gtx.Ops)
+		dims := layPopupMenuItem(gtx, th, &pm.share, "Share…")
+		height += dims.Size.Y
+		offOp.Pop()
+	}
+	// delete
+	{
+		offOp := op.Offset(image.Pt(0, height)).Push(gtx.Ops)
+		dims := layPopupMenuItem(gtx, th, &pm.delete, "Delete")
+		height += dims.Size.Y
+		offOp.Pop()
+	}
 	// todo:
 	// rename
 	// export
-	// delete
 	call := m.Stop()
 
 	offOp := op.Offset(pm.position).Push(gtx.Ops)
@@ -4,13 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"image"
-	"image/png"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"strings"
 
 	"github.com/steverusso/lockbook-x/go-lockbook"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
 )
 
-// Gets all parents except root in descending order from root.
+// Gets all parents except root in descending order from root, including the file itself.
+// This walks up with `FileByID` rather than loading a `lockbook.Tree`, since each call is
+// served by the metadata cache when it's warm and a whole tree would list every file in
+// the account when it isn't.
 func getParents(core lockbook.Core, id lockbook.FileID) ([]nameAndID, error) {
 	r := []nameAndID{}
 	for {
@@ -30,11 +37,11 @@ func getParents(core lockbook.Core, id lockbook.FileID) ([]nameAndID, error) {
 	return r, nil
 }
 
-func decodeImage(blob []byte) image.Image {
+func decodeImage(blob []byte) (image.Image, error) {
 	imgBuf := bytes.NewReader(blob)
-	img, err := png.Decode(imgBuf)
+	img, _, err := image.Decode(imgBuf)
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("decoding image: %w", err)
 	}
-	return img
+	return img, nil
 }
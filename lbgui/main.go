@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -27,6 +28,7 @@ import (
 	"github.com/steverusso/gio-fonts/nunito/nunitobolditalic"
 	"github.com/steverusso/gio-fonts/nunito/nunitoitalic"
 	"github.com/steverusso/gio-fonts/nunito/nunitoregular"
+	"github.com/steverusso/lockbook-x/go-lockbook"
 )
 
 type (
@@ -117,6 +119,10 @@ func (lb *legitbook) handleExplEvent(gtx C, e key.Event) {
 		case key.NameDeleteForward:
 			lb.work.deleteSelectedFiles()
 		case key.NameEscape:
+			if lb.work.syncDetails.isOpen {
+				lb.work.syncDetails.isOpen = false
+				break
+			}
 			lb.work.expl.deselectAll()
 		}
 	case key.ModCtrl:
@@ -185,6 +191,10 @@ func run() error {
 				lb.splash = splashScreen{}
 				go lb.work.manageSyncs()
 				go lb.work.manageSaves()
+				go lb.work.manageSessionSaves()
+				go lb.work.restoreSession()
+				// Show the pending work right away instead of after the first sync.
+				go func(core lockbook.Core) { lb.updates <- calcWork(core) }(u.core)
 			case handoffToOnboard:
 				// todo(steve): design and impl the "onboard" screen
 			case wsUpdate:
@@ -198,10 +208,11 @@ func run() error {
 				gtx := layout.NewContext(&ops, e)
 				lb.frame(gtx)
 				e.Frame(gtx.Ops)
-				if *printFrameTimes {
-					log.Println(time.Since(start))
-				}
+				stats.Observe("gui.Frame", time.Since(start), nil, 0)
 			case system.DestroyEvent:
+				if lb.screen == showWorkspace {
+					lb.work.saveSession()
+				}
 				return e.Err
 			}
 		}
@@ -216,13 +227,32 @@ func mustFont(fnt text.Font, data []byte) text.FontFace {
 	return text.FontFace{Font: fnt, Face: face}
 }
 
-var printFrameTimes = flag.Bool("print-frame-times", false, "Print how long each frame takes.")
+var (
+	debugAddr = flag.String("debug-addr", "", "Serve frame times and core call stats on this address (see 'lbcli debug stats').")
+	slowCall  = flag.Duration("slow-call", 0, "Log core calls that take longer than this.")
+
+	recordPath    = flag.String("record", "", "Write a trace of every core call to this file (for bug reports).")
+	redactContent = flag.Bool("redact-content", false, "Leave document content out of the recorded trace.")
+	replayPath    = flag.String("replay", "", "Answer core calls from this recorded trace instead of a real core.")
+)
+
+// stats holds the core call metrics along with how long each frame takes.
+var stats lockbook.Stats
 
 func main() {
 	flag.Parse()
 
+	if *debugAddr != "" {
+		stats.Publish("lockbook")
+		go func() {
+			log.Println(http.ListenAndServe(*debugAddr, stats.Handler()))
+		}()
+	}
+
 	go func() {
-		if err := run(); err != nil {
+		err := run()
+		runAtExit()
+		if err != nil {
 			log.Fatal(err)
 		}
 		os.Exit(0)
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+type contentKind uint8
+
+const (
+	contentText contentKind = iota
+	contentImage
+	contentBinary
+)
+
+// contentKindByExt guesses the kind of content from a file name alone. It's used to
+// decide what to show while a document is loading.
+func contentKindByExt(name string) (contentKind, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp":
+		return contentImage, true
+	case ".svg", ".pdf":
+		return contentBinary, true
+	case ".md", ".txt":
+		return contentText, true
+	default:
+		return 0, false
+	}
+}
+
+// detectContent determines the kind of content and its MIME type from the data's magic
+// bytes, falling back on the file extension. SVGs and PDFs can't be rendered, so they
+// get the binary preview.
+func detectContent(name string, data []byte) (contentKind, string) {
+	mimeType := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return contentImage, mimeType
+	case strings.HasPrefix(mimeType, "text/"):
+		if kind, ok := contentKindByExt(name); ok && kind == contentBinary {
+			if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+				mimeType = t
+			}
+			return contentBinary, mimeType
+		}
+		return contentText, mimeType
+	default:
+		return contentBinary, mimeType
+	}
+}
+
+func toolbarButtons(th *material.Theme) buttonGroupStyle {
+	return buttonGroupStyle{
+		bg:       th.Bg,
+		fg:       th.Fg,
+		shaper:   th.Shaper,
+		textSize: th.TextSize * 0.8,
+	}
+}
+
+// imageView is the read-only content of a tab for an image document.
+type imageView struct {
+	img  paint.ImageOp
+	zoom zoomView
+}
+
+func newImageView(img image.Image) *imageView {
+	return &imageView{img: paint.NewImageOp(img)}
+}
+
+func layImageTab(gtx C, th *material.Theme, iv *imageView) D {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(inset).Layout(gtx, func(gtx C) D {
+				size := iv.img.Size()
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(material.Body2(th, fmt.Sprintf("%d × %d", size.X, size.Y)).Layout),
+					layout.Flexed(1, layout.Spacer{}.Layout),
+					layout.Rigid(func(gtx C) D {
+						return iv.zoom.layControls(gtx, th)
+					}),
+				)
+			})
+		}),
+		layout.Rigid(rule{color: th.Fg}.Layout),
+		layout.Flexed(1, func(gtx C) D {
+			return iv.zoom.layout(gtx, iv.img, darken(th.Bg, 0.2))
+		}),
+	)
+}
+
+// hexView is the read-only content of a tab for a document that can't be displayed
+// otherwise. It shows a classic hex dump of the data.
+type hexView struct {
+	data     []byte
+	mimeType string
+	note     string
+	list     widget.List
+}
+
+const hexRowLen = 16
+
+func newHexView(data []byte, mimeType string, decodeErr error) *hexView {
+	hv := &hexView{
+		data:     data,
+		mimeType: mimeType,
+		list:     widget.List{List: layout.List{Axis: layout.Vertical}},
+	}
+	if decodeErr != nil {
+		hv.note = "Unable to display image: " + decodeErr.Error()
+	}
+	return hv
+}
+
+func layHexTab(gtx C, th *material.Theme, hv *hexView) D {
+	numRows := (len(hv.data) + hexRowLen - 1) / hexRowLen
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(inset).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						info := hv.mimeType + " · " + formatBytes(len(hv.data)) + " · read-only"
+						return material.Body2(th, info).Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if hv.note == "" {
+							return D{}
+						}
+						lbl := material.Caption(th, hv.note)
+						lbl.Color.A /= 2
+						return layout.Inset{Top: insetHalf}.Layout(gtx, lbl.Layout)
+					}),
+				)
+			})
+		}),
+		layout.Rigid(rule{color: th.Fg}.Layout),
+		layout.Flexed(1, func(gtx C) D {
+			return layout.UniformInset(inset).Layout(gtx, func(gtx C) D {
+				return material.List(th, &hv.list).Layout(gtx, numRows, func(gtx C, i int) D {
+					lbl := material.Body2(th, hexRow(hv.data, i*hexRowLen))
+					lbl.Font = text.Font{Variant: "Mono"}
+					lbl.MaxLines = 1
+					return lbl.Layout(gtx)
+				})
+			})
+		}),
+	)
+}
+
+// hexRow formats up to 16 bytes starting at `off` as "offset  hex bytes  |ascii|".
+func hexRow(data []byte, off int) string {
+	end := off + hexRowLen
+	if end > len(data) {
+		end = len(data)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x  ", off)
+	for i := off; i < off+hexRowLen; i++ {
+		if i < end {
+			fmt.Fprintf(&b, "%02x ", data[i])
+		} else {
+			b.WriteString("   ")
+		}
+		if i == off+hexRowLen/2-1 {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range data[off:end] {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('|')
+	return b.String()
+}
+
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.Itoa(n) + " B"
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// zoomView displays an image that can be zoomed with the scroll wheel and panned by
+// dragging.
+type zoomView struct {
+	// A zero scale means the image will be fit to the available space on the next layout.
+	scale      float32
+	offset     f32.Point
+	dragAt     f32.Point
+	isDragging bool
+	fitBtn     widget.Clickable
+}
+
+// layControls lays out the current zoom percentage and a button to fit the image.
+func (z *zoomView) layControls(gtx C, th *material.Theme) D {
+	if z.fitBtn.Clicked() {
+		z.scale = 0
+	}
+	pct := strconv.Itoa(int(z.scale*100+0.5)) + "%"
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(material.Body2(th, pct).Layout),
+		layout.Rigid(layout.Spacer{Width: inset}.Layout),
+		layout.Rigid(func(gtx C) D {
+			return toolbarButtons(th).layout(gtx, []groupButton{{click: &z.fitBtn, text: "Fit"}})
+		}),
+	)
+}
+
+func (z *zoomView) fit(area, imgSize image.Point) {
+	if imgSize.X == 0 || imgSize.Y == 0 {
+		z.scale = 1
+		return
+	}
+	sx := float32(area.X) / float32(imgSize.X)
+	sy := float32(area.Y) / float32(imgSize.Y)
+	z.scale = sx
+	if sy < sx {
+		z.scale = sy
+	}
+	if z.scale > 1 {
+		z.scale = 1
+	}
+	z.offset = f32.Pt(
+		(float32(area.X)-float32(imgSize.X)*z.scale)/2,
+		(float32(area.Y)-float32(imgSize.Y)*z.scale)/2,
+	)
+}
+
+// zoomAt scales by the given factor while keeping the point under `pos` in place.
+func (z *zoomView) zoomAt(pos f32.Point, factor float32) {
+	const minScale, maxScale = 0.05, 20
+	newScale := z.scale * factor
+	if newScale < minScale || newScale > maxScale {
+		return
+	}
+	z.offset = pos.Sub(pos.Sub(z.offset).Mul(newScale / z.scale))
+	z.scale = newScale
+}
+
+func (z *zoomView) layout(gtx C, img paint.ImageOp, bg color.NRGBA) D {
+	size := gtx.Constraints.Max
+	for _, e := range gtx.Events(z) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Scroll:
+			if z.scale == 0 {
+				break
+			}
+			factor := float32(1.1)
+			if e.Scroll.Y > 0 {
+				factor = 1 / factor
+			}
+			z.zoomAt(e.Position, factor)
+		case pointer.Press:
+			z.isDragging = true
+			z.dragAt = e.Position
+		case pointer.Drag:
+			if z.isDragging {
+				z.offset = z.offset.Add(e.Position.Sub(z.dragAt))
+				z.dragAt = e.Position
+			}
+		case pointer.Release, pointer.Cancel:
+			z.isDragging = false
+		}
+	}
+	imgSize := img.Size()
+	if z.scale == 0 {
+		z.fit(size, imgSize)
+	}
+
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	pointer.InputOp{
+		Tag:          z,
+		Types:        pointer.Press | pointer.Drag | pointer.Release | pointer.Scroll,
+		ScrollBounds: image.Rect(-100, -100, 100, 100),
+	}.Add(gtx.Ops)
+	if z.isDragging {
+		pointer.CursorGrabbing.Add(gtx.Ops)
+	}
+
+	tr := f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(z.scale, z.scale)).Offset(z.offset)
+	defer op.Affine(tr).Push(gtx.Ops).Pop()
+	defer clip.Rect{Max: imgSize}.Push(gtx.Ops).Pop()
+	paint.ColorOp{Color: bg}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	img.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	return D{Size: size}
+}
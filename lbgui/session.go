@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/mdedit"
+)
+
+const (
+	sessionFileName     = "session.json"
+	sessionSaveInterval = time.Second * 30
+)
+
+// session is the workspace state that is persisted across runs.
+type session struct {
+	Tabs       []sessionTab      `json:"tabs"`
+	ActiveTab  int               `json:"active_tab"`
+	LayoutMode wsLayoutMode      `json:"layout_mode"`
+	ExplTarget lockbook.FileID   `json:"expl_target"`
+	Expanded   []lockbook.FileID `json:"expanded"`
+
+	// seq orders snapshots so that an older one is never written over a newer one.
+	seq uint64
+}
+
+// sessionTab holds an open tab's file ID, view settings and editor caret.
+type sessionTab struct {
+	ID           lockbook.FileID     `json:"id"`
+	ViewMode     mdedit.ViewMode     `json:"view_mode"`
+	SingleWidget mdedit.SingleWidget `json:"single_widget"`
+	SplitRatio   float32             `json:"split_ratio"`
+	Editor       *editorState        `json:"editor,omitempty"`
+}
+
+// editorState is where the caret of a tab's editor was. The editor scrolls to the caret
+// once it's placed.
+type editorState struct {
+	CaretStart int `json:"caret_start"`
+	CaretEnd   int `json:"caret_end"`
+}
+
+// editorState returns the editor's caret. A state that's waiting to be restored is
+// returned as is.
+func (md *markdownContent) editorState() *editorState {
+	if md.restoreState != nil {
+		return md.restoreState
+	}
+	var st editorState
+	st.CaretStart, st.CaretEnd = md.view.Editor.Selection()
+	return &st
+}
+
+// applyEditorState restores the editor's caret. It must be called after the editor's
+// text is set.
+func (md *markdownContent) applyEditorState(st *editorState) {
+	md.view.Editor.SetCaret(st.CaretStart, st.CaretEnd)
+}
+
+type (
+	sessionSaveTick struct{}
+	sessionRestored struct {
+		sess  session
+		names map[lockbook.FileID]string
+		dirs  []openDirTreeResult
+	}
+)
+
+func (sessionSaveTick) implsWsUpdate() {}
+func (sessionRestored) implsWsUpdate() {}
+
+func sessionFilePath() string {
+	return filepath.Join(getDataDir(), sessionFileName)
+}
+
+// snapshotSession captures the current workspace state. It must be called from the UI
+// go routine.
+func (ws *workspace) snapshotSession() session {
+	s := session{
+		Tabs:       make([]sessionTab, len(ws.tabs)),
+		ActiveTab:  ws.activeTab,
+		LayoutMode: ws.mode,
+		ExplTarget: ws.expl.targetID,
+		Expanded:   ws.tree.expandedIDs(),
+	}
+	ws.sessionSeq++
+	s.seq = ws.sessionSeq
+	for i := range ws.tabs {
+		t := &ws.tabs[i]
+		s.Tabs[i] = sessionTab{ID: t.id}
+		if md := t.markdown(); md != nil {
+			s.Tabs[i].ViewMode = md.view.Mode
+			s.Tabs[i].SingleWidget = md.view.SingleWidget
+			s.Tabs[i].SplitRatio = md.view.SplitRatio
+			s.Tabs[i].Editor = md.editorState()
+		}
+	}
+	return s
+}
+
+// lastSessionWrite guards writing the session file and holds the sequence number of the
+// last snapshot written, since the periodic and closing saves happen on different go
+// routines.
+var lastSessionWrite struct {
+	sync.Mutex
+	seq uint64
+}
+
+// writeSession writes a snapshot to the session file unless a newer one was already
+// written.
+func writeSession(s session) error {
+	lastSessionWrite.Lock()
+	defer lastSessionWrite.Unlock()
+	if s.seq != 0 && s.seq <= lastSessionWrite.seq {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding session: %w", err)
+	}
+	fpath := sessionFilePath()
+	tmp := fpath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := os.Rename(tmp, fpath); err != nil {
+		return fmt.Errorf("replacing session file: %w", err)
+	}
+	lastSessionWrite.seq = s.seq
+	return nil
+}
+
+// saveSession writes the current workspace state to the data directory. It blocks, so
+// it's meant for when the app is closing.
+func (ws *workspace) saveSession() {
+	if err := writeSession(ws.snapshotSession()); err != nil {
+		log.Printf("saving session: %v", err)
+	}
+}
+
+func (ws *workspace) manageSessionSaves() {
+	t := time.NewTicker(sessionSaveInterval)
+	for range t.C {
+		ws.updates <- sessionSaveTick{}
+	}
+}
+
+// restoreSession loads the previous session (if any) and gathers everything needed to
+// restore it. Any files that no longer exist are left out.
+func (ws *workspace) restoreSession() {
+	data, err := os.ReadFile(sessionFilePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("reading session: %v", err)
+		}
+		return
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Printf("decoding session: %v", err)
+		return
+	}
+	r := sessionRestored{names: make(map[lockbook.FileID]string, len(s.Tabs))}
+	exists := func(id lockbook.FileID) (lockbook.File, bool) {
+		f, err := ws.core.FileByID(id)
+		if err != nil {
+			if err, ok := err.(*lockbook.Error); !ok || err.Code != lockbook.CodeFileNonexistent {
+				log.Printf("restoring session: file by id %q: %v", id, err)
+			}
+			return lockbook.File{}, false
+		}
+		return f, true
+	}
+
+	tabs := make([]sessionTab, 0, len(s.Tabs))
+	for i, t := range s.Tabs {
+		f, ok := exists(t.ID)
+		if !ok {
+			if i < s.ActiveTab {
+				s.ActiveTab--
+			}
+			continue
+		}
+		r.names[f.ID] = f.Name
+		tabs = append(tabs, t)
+	}
+	s.Tabs = tabs
+
+	if !s.ExplTarget.IsNil() {
+		if f, ok := exists(s.ExplTarget); !ok || !f.IsDir() {
+			s.ExplTarget = lockbook.FileID{}
+		}
+	}
+
+	// The expanded directories are stored parents first, so populating them in order
+	// will always find the parent entry in the tree.
+	for _, id := range s.Expanded {
+		f, ok := exists(id)
+		if !ok || !f.IsDir() {
+			continue
+		}
+		files, err := ws.core.GetChildren(id)
+		if err != nil {
+			log.Printf("restoring session: getting children of %q: %v", id, err)
+			continue
+		}
+		lockbook.SortFiles(files)
+		r.dirs = append(r.dirs, openDirTreeResult{id: id, files: files})
+	}
+
+	r.sess = s
+	ws.updates <- r
+}
+
+func (ws *workspace) applySession(r sessionRestored) {
+	s := &r.sess
+	for _, st := range s.Tabs {
+		if ws.tabByID(st.ID) != nil {
+			continue
+		}
+		ws.openTab(st.ID, r.names[st.ID])
+		md := ws.tabs[ws.activeTab].markdown()
+		if md == nil {
+			continue
+		}
+		md.view.Mode = st.ViewMode
+		md.view.SingleWidget = st.SingleWidget
+		if st.SplitRatio > 0 {
+			md.view.SplitRatio = st.SplitRatio
+		}
+		// The caret can only be placed once the document's text is loaded.
+		md.restoreState = st.Editor
+	}
+	if len(ws.tabs) > 0 {
+		ws.animStage = wsExplClosed
+		ws.animPct = 0
+		ws.selectTab(s.ActiveTab)
+	}
+	ws.mode = s.LayoutMode
+	if !s.ExplTarget.IsNil() {
+		ws.openDir(s.ExplTarget)
+	}
+	for _, d := range r.dirs {
+		ws.tree.populate(d.id, d.files)
+	}
+}
+
+// expandedIDs returns the IDs of all expanded entries (except root) with parents before
+// their children.
+func (t *fileTree) expandedIDs() []lockbook.FileID {
+	var ids []lockbook.FileID
+	var walk func(en *treeEntry)
+	walk = func(en *treeEntry) {
+		for i := range en.children {
+			ch := &en.children[i]
+			if ch.isExpanded {
+				ids = append(ids, ch.file.ID)
+				walk(ch)
+			}
+		}
+	}
+	walk(&t.root)
+	return ids
+}
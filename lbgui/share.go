@@ -0,0 +1,464 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gioui.org/gesture"
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+type (
+	pendingSharesLoaded struct {
+		shares []lockbook.File
+		err    error
+	}
+	shareChanged struct {
+		status string
+		// parent is the folder a link was created in (if any) so it can be refreshed.
+		parent lockbook.FileID
+		err    error
+	}
+	fileSharesLoaded struct {
+		id     lockbook.FileID
+		shares []lockbook.Share
+		err    error
+	}
+)
+
+func (pendingSharesLoaded) implsWsUpdate() {}
+func (shareChanged) implsWsUpdate()        {}
+func (fileSharesLoaded) implsWsUpdate()    {}
+
+func loadPendingShares(core lockbook.Core) pendingSharesLoaded {
+	shares, err := core.GetPendingShares()
+	if err != nil {
+		return pendingSharesLoaded{err: fmt.Errorf("getting pending shares: %w", err)}
+	}
+	return pendingSharesLoaded{shares: shares}
+}
+
+// setPendingShares stores the latest pending shares and counts the ones that haven't
+// been seen in the inbox yet for the bottom bar badge. The shares from the first load are
+// counted as seen, so only the ones that arrive while the app is open are new.
+func (ws *workspace) setPendingShares(u pendingSharesLoaded) {
+	if u.err != nil {
+		if !lockbook.IsConnectivityError(u.err) {
+			ws.bgErrs = append(ws.bgErrs, u.err)
+		}
+		return
+	}
+	ws.pendingShares = u.shares
+	if inbox := ws.shareInbox(); inbox != nil {
+		inbox.acceptBtns = make([]widget.Clickable, len(u.shares))
+		inbox.rejectBtns = make([]widget.Clickable, len(u.shares))
+		ws.markSharesSeen()
+		return
+	}
+	if ws.seenShares == nil {
+		ws.markSharesSeen()
+		return
+	}
+	ws.numNewShares = 0
+	for _, f := range u.shares {
+		if !ws.seenShares[f.ID] {
+			ws.numNewShares++
+		}
+	}
+}
+
+func (ws *workspace) markSharesSeen() {
+	if ws.seenShares == nil {
+		ws.seenShares = make(map[lockbook.FileID]bool)
+	}
+	for _, f := range ws.pendingShares {
+		ws.seenShares[f.ID] = true
+	}
+	ws.numNewShares = 0
+}
+
+func (ws *workspace) handleShareChanged(u shareChanged) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, u.err)
+		return
+	}
+	if u.status != "" {
+		ws.botStatus = u.status
+	}
+	if !u.parent.IsNil() {
+		ws.refreshDir(u.parent)
+	}
+}
+
+// sharePrompt is a modal for sharing a file with another user. It also lists who the file
+// is already shared with once that's loaded.
+type sharePrompt struct {
+	file      nameAndID
+	shares    []lockbook.Share
+	loading   bool
+	input     widget.Editor
+	mode      widget.Enum
+	shareBtn  widget.Clickable
+	cancelBtn widget.Clickable
+	err       error
+}
+
+func (sharePrompt) implsModal() {}
+
+func (ws *workspace) openSharePrompt(file nameAndID) {
+	p := &sharePrompt{
+		file:  file,
+		input: widget.Editor{SingleLine: true, Submit: true},
+	}
+	p.mode.Value = "read"
+	p.loading = true
+	ws.modals = append(ws.modals, p)
+	go loadFileShares(ws.core, ws.updates, file.id)
+}
+
+func loadFileShares(core lockbook.Core, updates chan<- legitUpdate, id lockbook.FileID) {
+	u := fileSharesLoaded{id: id}
+	f, err := core.FileByID(id)
+	if err != nil {
+		u.err = fmt.Errorf("file by id %q: %w", id, err)
+	} else {
+		u.shares = f.Shares
+	}
+	updates <- u
+}
+
+// setFileShares fills in who a file is shared with if its share prompt is still open.
+func (ws *workspace) setFileShares(u fileSharesLoaded) {
+	for _, m := range ws.modals {
+		if p, ok := m.(*sharePrompt); ok && p.file.id == u.id {
+			p.loading = false
+			p.shares = u.shares
+			if u.err != nil {
+				p.err = u.err
+			}
+		}
+	}
+}
+
+// shareSelectedFile opens the share prompt for the one selected explorer entry.
+func (ws *workspace) shareSelectedFile() {
+	var sel []nameAndID
+	for i := range ws.expl.entries {
+		if en := &ws.expl.entries[i]; en.isSelected() {
+			sel = append(sel, nameAndID{name: en.name, id: en.id})
+		}
+	}
+	if len(sel) != 1 {
+		ws.botStatus = "Select one file to share"
+		return
+	}
+	ws.openSharePrompt(sel[0])
+}
+
+func (ws *workspace) shareTreeSelection() {
+	sel := ws.tree.selection()
+	if len(sel.entries) != 1 {
+		ws.botStatus = "Select one file to share"
+		return
+	}
+	f := &sel.entries[0].file
+	ws.openSharePrompt(nameAndID{name: f.Name, id: f.ID})
+}
+
+func shareFile(core lockbook.Core, updates chan<- legitUpdate, file nameAndID, uname string, mode lockbook.ShareMode) {
+	u := shareChanged{}
+	if err := core.ShareFile(file.id, uname, mode); err != nil {
+		u.err = fmt.Errorf("sharing %q: %w", file.name, err)
+	} else {
+		u.status = fmt.Sprintf("Shared %s with @%s (on the next sync)", file.name, uname)
+	}
+	updates <- u
+}
+
+func (ws *workspace) laySharePrompt(gtx C, th *material.Theme, p *sharePrompt) D {
+	closeModal := func() { ws.modals = ws.modals[:len(ws.modals)-1] }
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			closeModal()
+			return D{}
+		}
+	}
+	if p.cancelBtn.Clicked() {
+		closeModal()
+		return D{}
+	}
+	submit := p.shareBtn.Clicked()
+	for _, e := range p.input.Events() {
+		if _, ok := e.(widget.SubmitEvent); ok {
+			submit = true
+		}
+	}
+	if submit {
+		uname := strings.TrimPrefix(strings.TrimSpace(p.input.Text()), "@")
+		if uname == "" {
+			p.err = errors.New("a username is required")
+		} else {
+			mode := lockbook.ShareModeRead
+			if p.mode.Value == "write" {
+				mode = lockbook.ShareModeWrite
+			}
+			go shareFile(ws.core, ws.updates, p.file, uname, mode)
+			closeModal()
+			return D{}
+		}
+	}
+
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body1(th, fmt.Sprintf("Share %q", p.file.name))
+				lbl.Font.Weight = text.Bold
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(material.Editor(th, &p.input, "Username").Layout),
+			layout.Rigid(layout.Spacer{Height: inset}.Layout),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.RadioButton(th, &p.mode, "read", "Read only").Layout),
+					layout.Rigid(layout.Spacer{Width: 12}.Layout),
+					layout.Rigid(material.RadioButton(th, &p.mode, "write", "Read and write").Layout),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if p.err == nil {
+					return D{}
+				}
+				return layout.Inset{Top: inset}.Layout(gtx, material.Body2(th, "error: "+p.err.Error()).Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if p.loading {
+					return layout.Inset{Top: 12}.Layout(gtx, material.Caption(th, "Loading shares...").Layout)
+				}
+				if len(p.shares) == 0 {
+					return D{}
+				}
+				lines := make([]string, len(p.shares))
+				for i, sh := range p.shares {
+					lines[i] = fmt.Sprintf("@%s · %s (by @%s)", sh.SharedWith, strings.ToLower(sh.Mode.String()), sh.SharedBy)
+				}
+				return layout.Inset{Top: 12}.Layout(gtx, func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(material.Caption(th, "Shared with").Layout),
+						layout.Rigid(func(gtx C) D {
+							lbl := material.Body2(th, strings.Join(lines, "\n"))
+							lbl.Color.A /= 2
+							return lbl.Layout(gtx)
+						}),
+					)
+				})
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(func(gtx C) D {
+				return toolbarButtons(th).layout(gtx, []groupButton{
+					{click: &p.cancelBtn, text: "Cancel"},
+					{click: &p.shareBtn, text: "Share"},
+				})
+			}),
+		)
+	})
+}
+
+// shareInbox is a modal listing the pending shares with buttons to accept or reject each
+// of them.
+type shareInbox struct {
+	list       widget.List
+	acceptBtns []widget.Clickable
+	rejectBtns []widget.Clickable
+}
+
+func (shareInbox) implsModal() {}
+
+func (ws *workspace) openShareInbox() {
+	inbox := &shareInbox{}
+	inbox.list.Axis = layout.Vertical
+	inbox.acceptBtns = make([]widget.Clickable, len(ws.pendingShares))
+	inbox.rejectBtns = make([]widget.Clickable, len(ws.pendingShares))
+	ws.modals = append(ws.modals, inbox)
+	ws.markSharesSeen()
+	go func() { ws.updates <- loadPendingShares(ws.core) }()
+}
+
+func (ws *workspace) shareInbox() *shareInbox {
+	for _, m := range ws.modals {
+		if inbox, ok := m.(*shareInbox); ok {
+			return inbox
+		}
+	}
+	return nil
+}
+
+// changeShares runs an action on a pending share and reloads the pending shares
+// afterwards.
+func changeShares(core lockbook.Core, updates chan<- legitUpdate, fn func() shareChanged) {
+	updates <- fn()
+	updates <- loadPendingShares(core)
+}
+
+func (ws *workspace) layShareInbox(gtx C, th *material.Theme, inbox *shareInbox) D {
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	for i := range ws.pendingShares {
+		sh := ws.pendingShares[i]
+		if inbox.acceptBtns[i].Clicked() {
+			ws.modals = append(ws.modals, newAcceptSharePrompt(sh))
+		}
+		if inbox.rejectBtns[i].Clicked() {
+			go changeShares(ws.core, ws.updates, func() shareChanged {
+				if err := ws.core.DeletePendingShare(sh.ID); err != nil {
+					return shareChanged{err: fmt.Errorf("rejecting %q: %w", sh.Name, err)}
+				}
+				return shareChanged{status: "Rejected " + sh.Name}
+			})
+		}
+	}
+
+	btnStyle := toolbarButtons(th)
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body1(th, "Pending Shares")
+				lbl.Font.Weight = text.Bold
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(func(gtx C) D {
+				if len(ws.pendingShares) == 0 {
+					return material.Body2(th, "No pending shares.").Layout(gtx)
+				}
+				if gtx.Constraints.Max.Y > 360 {
+					gtx.Constraints.Max.Y = 360
+				}
+				return material.List(th, &inbox.list).Layout(gtx, len(ws.pendingShares), func(gtx C, i int) D {
+					return layout.Inset{Bottom: insetHalf}.Layout(gtx, func(gtx C) D {
+						return inbox.layEntry(gtx, th, btnStyle, &ws.pendingShares[i], i)
+					})
+				})
+			}),
+		)
+	})
+}
+
+func (inbox *shareInbox) layEntry(gtx C, th *material.Theme, btnStyle buttonGroupStyle, f *lockbook.File, i int) D {
+	from, mode := "", ""
+	if len(f.Shares) > 0 {
+		from = f.Shares[0].SharedBy
+		mode = strings.ToLower(f.Shares[0].Mode.String())
+	}
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					lbl := material.Body2(th, f.Name)
+					lbl.MaxLines = 1
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					lbl := material.Caption(th, "from @"+from+" · "+mode)
+					lbl.MaxLines = 1
+					lbl.Color.A /= 2
+					return lbl.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return btnStyle.layout(gtx, []groupButton{
+				{click: &inbox.acceptBtns[i], text: "Accept"},
+				{click: &inbox.rejectBtns[i], text: "Reject"},
+			})
+		}),
+	)
+}
+
+// acceptSharePrompt asks which folder a link to an accepted share should be placed in.
+type acceptSharePrompt struct {
+	share lockbook.File
+	input widget.Editor
+	err   error
+}
+
+func (acceptSharePrompt) implsModal() {}
+
+func newAcceptSharePrompt(share lockbook.File) *acceptSharePrompt {
+	p := &acceptSharePrompt{
+		share: share,
+		input: widget.Editor{SingleLine: true, Submit: true},
+	}
+	p.input.SetText("/")
+	return p
+}
+
+// acceptShare creates a link to the shared file in the destination folder, creating the
+// folder if it doesn't exist.
+func acceptShare(core lockbook.Core, share lockbook.File, dest string) shareChanged {
+	if !strings.HasSuffix(dest, "/") {
+		dest += "/"
+	}
+	dir, exists, err := lockbook.MaybeFileByPath(core, dest)
+	if err != nil {
+		return shareChanged{err: fmt.Errorf("file by path %q: %w", dest, err)}
+	}
+	if !exists {
+		if dir, err = core.CreateFileAtPath(dest); err != nil {
+			return shareChanged{err: fmt.Errorf("creating file at path %q: %w", dest, err)}
+		}
+	} else if !dir.IsDir() {
+		return shareChanged{err: fmt.Errorf("destination %q is a document, must be a folder", dest)}
+	}
+	name := strings.TrimSuffix(share.Name, "/")
+	if _, err := core.CreateFile(name, dir.ID, lockbook.FileTypeLink{Target: share.ID}); err != nil {
+		return shareChanged{err: fmt.Errorf("creating link: %w", err)}
+	}
+	return shareChanged{status: "Accepted " + name + " into " + dest, parent: dir.ID}
+}
+
+func (ws *workspace) layAcceptSharePrompt(gtx C, th *material.Theme, p *acceptSharePrompt) D {
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	for _, e := range p.input.Events() {
+		if e, ok := e.(widget.SubmitEvent); ok {
+			dest := strings.TrimSpace(e.Text)
+			if dest == "" {
+				p.err = errors.New("a destination folder is required")
+				continue
+			}
+			share := p.share
+			go changeShares(ws.core, ws.updates, func() shareChanged {
+				return acceptShare(ws.core, share, dest)
+			})
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(material.Body1(th, fmt.Sprintf("Add %q to folder:", p.share.Name)).Layout),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(material.Editor(th, &p.input, "Folder path").Layout),
+			layout.Rigid(func(gtx C) D {
+				if p.err == nil {
+					return D{}
+				}
+				return layout.Inset{Top: 12}.Layout(gtx, material.Body2(th, "error: "+p.err.Error()).Layout)
+			}),
+		)
+	})
+}
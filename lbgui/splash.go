@@ -6,10 +6,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"gioui.org/layout"
 	"gioui.org/widget/material"
 	"github.com/steverusso/lockbook-x/go-lockbook"
+	"github.com/steverusso/lockbook-x/go-lockbook/history"
 )
 
 type splashScreen struct {
@@ -23,11 +25,13 @@ type handoffToOnboard struct {
 }
 
 type handoffToWorkspace struct {
-	core       lockbook.Core
-	lastSynced string
-	root       lockbook.File
-	rootFiles  []lockbook.File
-	errs       []error
+	core         lockbook.Core
+	conflictCore *lockbook.ConflictCore
+	lastSynced   string
+	root         lockbook.File
+	rootFiles    []lockbook.File
+	conflicts    []lockbook.Conflict
+	errs         []error
 }
 
 type setSplashErr struct {
@@ -52,12 +56,20 @@ func (s *splashScreen) setError(ctx string, err error) {
 }
 
 func (s *splashScreen) doStartupWork() {
-	dir := getDataDir()
-	core, err := lockbook.NewCore(dir)
+	lbCore, err := openCore(getDataDir())
 	if err != nil {
 		s.setError("initializing lockbook-core", err)
 		return
 	}
+	// Record metrics about each call into the core, cache file metadata in memory, save
+	// conflict copies of local changes replaced by a sync, and keep prior revisions of
+	// documents before they're overwritten by saves or syncs.
+	instCore := lockbook.Instrument(lbCore, lockbook.InstrumentOptions{
+		Stats:         &stats,
+		SlowThreshold: *slowCall,
+	})
+	conflictCore := lockbook.DetectConflicts(lockbook.NewCachedCore(instCore))
+	core := history.Wrap(conflictCore, history.Open(lbCore))
 	// Determine whether we're going to the onboard screen or the workspace by checking
 	// for an account.
 	if _, err = core.GetAccount(); err != nil {
@@ -92,13 +104,90 @@ func (s *splashScreen) doStartupWork() {
 		errs = append(errs, fmt.Errorf("getting last synced: %s", err))
 	}
 
+	conflicts, err := conflictCore.Conflicts()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("getting conflicts: %s", err))
+	}
+
 	s.updates <- handoffToWorkspace{
-		core:       core,
-		root:       root,
-		rootFiles:  rootFiles,
-		lastSynced: lastSynced,
-		errs:       errs,
+		core:         core,
+		conflictCore: conflictCore,
+		root:         root,
+		rootFiles:    rootFiles,
+		lastSynced:   lastSynced,
+		conflicts:    conflicts,
+		errs:         errs,
+	}
+}
+
+// openCore returns the core for the given data directory, or one that replays the trace
+// given by '-replay'. The calls are recorded if '-record' is given.
+func openCore(dir string) (lockbook.Core, error) {
+	var core lockbook.Core
+	if *replayPath != "" {
+		f, err := os.Open(*replayPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening trace: %w", err)
+		}
+		defer f.Close()
+		rp, err := lockbook.Replayer(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading trace: %w", err)
+		}
+		// Keep the history of replayed writes out of the real data dir.
+		if rp.Dir, err = os.MkdirTemp("", "lbgui-replay-"); err != nil {
+			return nil, fmt.Errorf("creating replay data dir: %w", err)
+		}
+		atExit(func() { os.RemoveAll(rp.Dir) })
+		core = rp
+	} else {
+		c, err := lockbook.NewCore(dir)
+		if err != nil {
+			return nil, err
+		}
+		core = c
+	}
+	if *recordPath != "" {
+		f, err := os.Create(*recordPath)
+		if err != nil {
+			return nil, fmt.Errorf("creating trace file: %w", err)
+		}
+		// The file stays open for as long as the app runs.
+		rc := lockbook.Recorder(core, f, lockbook.RecordOptions{
+			RedactContent: *redactContent,
+			RedactAccount: true,
+		})
+		atExit(func() {
+			if err := rc.Err(); err != nil {
+				log.Printf("recording trace: %v", err)
+			}
+			f.Close()
+		})
+		core = rc
+	}
+	return core, nil
+}
+
+var (
+	exitMu    sync.Mutex
+	exitFuncs []func()
+)
+
+// atExit registers a function to be called (by `runAtExit`) when the app exits.
+func atExit(fn func()) {
+	exitMu.Lock()
+	exitFuncs = append(exitFuncs, fn)
+	exitMu.Unlock()
+}
+
+// runAtExit calls the functions registered by `atExit` in reverse order.
+func runAtExit() {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	for i := len(exitFuncs) - 1; i >= 0; i-- {
+		exitFuncs[i]()
 	}
+	exitFuncs = nil
 }
 
 func getDataDir() string {
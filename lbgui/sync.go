@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"sort"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+// pendingWork is a work unit from `CalculateWork` along with the path of its file.
+type pendingWork struct {
+	typ  lockbook.WorkUnitType
+	id   lockbook.FileID
+	path string
+}
+
+type syncDetailsPopover struct {
+	isOpen bool
+	list   widget.List
+}
+
+// calcWork gets the pending sync work and resolves each work unit's file path. Files
+// that only exist on the server won't have a local path, so they'll show their ID.
+func calcWork(core lockbook.Core) workCalcResult {
+	wc, err := core.CalculateWork()
+	if err != nil {
+		return workCalcResult{err: fmt.Errorf("calculating work: %w", err)}
+	}
+	work := make([]pendingWork, len(wc.WorkUnits))
+	for i, wu := range wc.WorkUnits {
+		fpath, err := core.PathByID(wu.ID)
+		if err != nil {
+			fpath = wu.ID.String()
+		}
+		work[i] = pendingWork{typ: wu.Type, id: wu.ID, path: fpath}
+	}
+	sort.SliceStable(work, func(i, j int) bool {
+		a, b := work[i], work[j]
+		if a.typ != b.typ {
+			return a.typ == lockbook.WorkUnitTypeLocal
+		}
+		return a.path < b.path
+	})
+	return workCalcResult{work: work}
+}
+
+// pendingWorkID finds the file a sync progress update is about among the pending work.
+// The core only reports documents by name, so the first match is used if more than one
+// file with that name is being synced in the same direction.
+func pendingWorkID(work []pendingWork, sp lockbook.SyncProgress) lockbook.FileID {
+	if sp.Name == "" {
+		return lockbook.FileID{}
+	}
+	typ := lockbook.WorkUnitTypeLocal
+	if sp.Stage.IsPull() {
+		typ = lockbook.WorkUnitTypeServer
+	}
+	for i := range work {
+		if work[i].typ == typ && path.Base(work[i].path) == sp.Name {
+			return work[i].id
+		}
+	}
+	return lockbook.FileID{}
+}
+
+// syncProgressText describes a sync progress update, such as "Pushing document a.md".
+func syncProgressText(sp lockbook.SyncProgress) string {
+	s := sp.Stage.String()
+	if sp.Name != "" {
+		s += " " + sp.Name
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func countPendingWork(work []pendingWork) (numPush, numPull int) {
+	for i := range work {
+		if work[i].typ == lockbook.WorkUnitTypeLocal {
+			numPush++
+		} else {
+			numPull++
+		}
+	}
+	return
+}
+
+// pendingWorkSummary returns a short description of the pending work such as "3 changes
+// to push, 1 to pull".
+func pendingWorkSummary(work []pendingWork) string {
+	numPush, numPull := countPendingWork(work)
+	plural := func(n int) string {
+		if n == 1 {
+			return "change"
+		}
+		return "changes"
+	}
+	switch {
+	case numPush > 0 && numPull > 0:
+		return fmt.Sprintf("%d %s to push, %d to pull", numPush, plural(numPush), numPull)
+	case numPush > 0:
+		return fmt.Sprintf("%d %s to push", numPush, plural(numPush))
+	case numPull > 0:
+		return fmt.Sprintf("%d %s to pull", numPull, plural(numPull))
+	default:
+		return "Up to date"
+	}
+}
+
+// toggleSyncDetails opens or closes the sync details popover. Opening it kicks off a
+// fresh work calculation so the list isn't stale.
+func (ws *workspace) toggleSyncDetails() {
+	ws.syncDetails.isOpen = !ws.syncDetails.isOpen
+	if ws.syncDetails.isOpen {
+		go func() { ws.updates <- calcWork(ws.core) }()
+	}
+}
+
+// laySyncDetails draws the sync details popover in the bottom right corner of the given
+// constraints (which should end right above the bottom bar).
+func (ws *workspace) laySyncDetails(gtx C, th *material.Theme) {
+	const (
+		width     = 460
+		maxHeight = 320
+	)
+	area := gtx.Constraints.Max
+	gtx.Constraints.Min = image.Point{}
+	gtx.Constraints.Max.X = width
+	if gtx.Constraints.Max.Y > maxHeight {
+		gtx.Constraints.Max.Y = maxHeight
+	}
+
+	m := op.Record(gtx.Ops)
+	dims := layout.UniformInset(inset).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(th, pendingWorkSummary(ws.pending))
+				lbl.Font.Weight = text.Bold
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: insetHalf}.Layout),
+			layout.Flexed(1, func(gtx C) D {
+				return material.List(th, &ws.syncDetails.list).Layout(gtx, len(ws.pending), func(gtx C, i int) D {
+					w := &ws.pending[i]
+					dir := "push"
+					if w.typ == lockbook.WorkUnitTypeServer {
+						dir = "pull"
+					}
+					return layout.Flex{}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							gtx.Constraints.Min.X = 50
+							lbl := material.Caption(th, dir)
+							lbl.Color.A /= 2
+							return lbl.Layout(gtx)
+						}),
+						layout.Flexed(1, func(gtx C) D {
+							lbl := material.Caption(th, w.path)
+							lbl.MaxLines = 1
+							return lbl.Layout(gtx)
+						}),
+					)
+				})
+			}),
+		)
+	})
+	call := m.Stop()
+
+	size := image.Pt(width, dims.Size.Y)
+	pos := image.Pt(area.X-size.X-inset, area.Y-size.Y-insetHalf)
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	rr := clip.UniformRRect(image.Rectangle{Max: size}, 6)
+	paint.FillShape(gtx.Ops, lighten(th.Bg, 0.1), rr.Op(gtx.Ops))
+	widget.Border{Color: merge(th.Fg, th.Bg, 0.7), CornerRadius: 6, Width: 1}.Layout(gtx, func(gtx C) D {
+		return D{Size: size}
+	})
+	call.Add(gtx.Ops)
+}
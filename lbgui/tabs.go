@@ -17,18 +17,52 @@ import (
 )
 
 type tab struct {
-	id             lockbook.FileID
-	name           string
-	btn            widget.Clickable
+	id      lockbook.FileID
+	name    string
+	btn     widget.Clickable
+	content tabContent
+}
+
+// tabContent is what a tab displays, which depends on the kind of file that's open:
+// markdown (editable), a drawing, an image or a binary preview.
+type tabContent interface{ implsTabContent() }
+
+type markdownContent struct {
 	view           mdedit.View
-	isLoading      bool
 	numQueuedSaves uint8
 	lastEditAt     time.Time
 	lastSaveAt     time.Time
+	// restoreState is the editor state from the previous session that's restored once the
+	// document's text is loaded.
+	restoreState *editorState
+}
+
+func (*markdownContent) implsTabContent() {}
+func (*drawingView) implsTabContent()     {}
+func (*imageView) implsTabContent()       {}
+func (*hexView) implsTabContent()         {}
+
+func newMarkdownContent() *markdownContent {
+	md := &markdownContent{}
+	md.view.Mode = mdedit.ViewModeSingle
+	md.view.SingleWidget = mdedit.SingleViewEditor
+	md.view.SplitRatio = 0.5
+	return md
+}
+
+func (md *markdownContent) isDirty() bool {
+	return md.lastSaveAt.Before(md.lastEditAt)
+}
+
+// markdown returns the tab's markdown content or nil if it's showing something else.
+func (t *tab) markdown() *markdownContent {
+	md, _ := t.content.(*markdownContent)
+	return md
 }
 
 func (t *tab) isDirty() bool {
-	return t.lastSaveAt.Before(t.lastEditAt)
+	md := t.markdown()
+	return md != nil && md.isDirty()
 }
 
 func (ws *workspace) layTabsNotebook(gtx C, th *material.Theme) D {
@@ -46,7 +80,7 @@ func (ws *workspace) layTabsNotebook(gtx C, th *material.Theme) D {
 		}),
 		layout.Rigid(rule{color: th.Fg}.Layout),
 		layout.Flexed(1, func(gtx C) D {
-			return ws.layMarkdownTab(gtx, th, &ws.tabs[ws.activeTab])
+			return ws.layTab(gtx, th, &ws.tabs[ws.activeTab])
 		}),
 	)
 }
@@ -73,7 +107,7 @@ func (ws *workspace) layTabs(gtx C, th *material.Theme) D {
 			return D{Size: size}
 		}),
 		layout.Flexed(1, func(gtx C) D {
-			return ws.layMarkdownTab(gtx, th, &ws.tabs[ws.activeTab])
+			return ws.layTab(gtx, th, &ws.tabs[ws.activeTab])
 		}),
 	)
 }
@@ -112,21 +146,36 @@ func (ws *workspace) layTabList(gtx C, th *material.Theme) D {
 	})
 }
 
-func (ws *workspace) layMarkdownTab(gtx C, th *material.Theme, t *tab) D {
+func (ws *workspace) layTab(gtx C, th *material.Theme, t *tab) D {
+	switch c := t.content.(type) {
+	case *markdownContent:
+		return ws.layMarkdownTab(gtx, th, t.id, c)
+	case *drawingView:
+		return ws.layDrawingTab(gtx, th, t, c)
+	case *imageView:
+		return layImageTab(gtx, th, c)
+	case *hexView:
+		return layHexTab(gtx, th, c)
+	default:
+		return layout.Center.Layout(gtx, material.Loader(th).Layout)
+	}
+}
+
+func (ws *workspace) layMarkdownTab(gtx C, th *material.Theme, id lockbook.FileID, md *markdownContent) D {
 	defer func() {
-		if t.view.Editor.HasChanged() {
+		if md.view.Editor.HasChanged() {
 			ws.setLastEditAt(gtx.Now)
-			t.lastEditAt = gtx.Now
+			md.lastEditAt = gtx.Now
 		}
 	}()
-	if t.view.Editor.SaveRequested() && t.isDirty() {
+	if md.view.Editor.SaveRequested() && md.isDirty() {
 		ws.saveQueue.pushBack(saveRequest{
-			id:   t.id,
-			data: t.view.Editor.Text(),
+			id:   id,
+			data: md.view.Editor.Text(),
 		})
-		t.numQueuedSaves++
+		md.numQueuedSaves++
 	}
-	if t.numQueuedSaves > 0 {
+	if md.numQueuedSaves > 0 {
 		layout.NE.Layout(gtx, func(gtx C) D {
 			return material.Loader(th).Layout(gtx)
 		})
@@ -143,7 +192,7 @@ func (ws *workspace) layMarkdownTab(gtx C, th *material.Theme, t *tab) D {
 			BlockQuote: color.NRGBA{165, 165, 165, 230},
 			CodeBlock:  color.NRGBA{162, 120, 70, 255},
 		},
-		View: &t.view,
+		View: &md.view,
 	}.Layout(gtx)
 }
 
@@ -157,9 +206,12 @@ func (ws *workspace) insertTab(id lockbook.FileID, name string) {
 		id:   id,
 		name: name,
 	}
-	t.view.Mode = mdedit.ViewModeSingle
-	t.view.SingleWidget = mdedit.SingleViewEditor
-	t.view.SplitRatio = 0.5
+	// Text is assumed until the document is read unless the extension says otherwise.
+	if isDrawing(name) {
+		t.content = newDrawingView()
+	} else if kind, ok := contentKindByExt(name); !ok || kind == contentText {
+		t.content = newMarkdownContent()
+	}
 	ws.tabs[ws.activeTab] = t
 }
 
@@ -171,22 +223,45 @@ func (ws *workspace) selectTab(n int) {
 		n = len(ws.tabs) - 1
 	}
 	ws.activeTab = n
-	ws.tabs[ws.activeTab].view.Editor.Focus()
+	if md := ws.tabs[ws.activeTab].markdown(); md != nil {
+		md.view.Editor.Focus()
+	}
 }
 
-func (ws *workspace) setTabMarkdown(id lockbook.FileID, data []byte) {
-	for i := range ws.tabs {
-		if ws.tabs[i].id == id {
-			ws.tabs[i].view.Editor.SetText(data)
-			ws.tabs[i].view.Editor.Focus()
-			return
+// setTabContent sets the content of a tab once its document has been read. Text stays
+// editable while images and other binary files get a read-only preview.
+func (ws *workspace) setTabContent(u openFileResult) {
+	t := ws.tabByID(u.id)
+	if t == nil {
+		return
+	}
+	switch u.kind {
+	case contentText:
+		md := t.markdown()
+		if md == nil {
+			md = newMarkdownContent()
+			t.content = md
+		}
+		md.view.Editor.SetText(u.data)
+		md.view.Editor.Focus()
+		if md.restoreState != nil {
+			md.applyEditorState(md.restoreState)
+			md.restoreState = nil
 		}
+	case contentImage:
+		t.content = newImageView(u.img)
+	default:
+		t.content = newHexView(u.data, u.mimeType, u.decodeErr)
 	}
 }
 
 func (ws *workspace) closeActiveTab() {
-	ws.tabs = append(ws.tabs[:ws.activeTab], ws.tabs[ws.activeTab+1:]...)
-	if ws.activeTab >= len(ws.tabs) && ws.activeTab != 0 {
+	ws.closeTab(ws.activeTab)
+}
+
+func (ws *workspace) closeTab(i int) {
+	ws.tabs = append(ws.tabs[:i], ws.tabs[i+1:]...)
+	if (i < ws.activeTab || ws.activeTab >= len(ws.tabs)) && ws.activeTab != 0 {
 		ws.activeTab--
 	}
 }
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gioui.org/gesture"
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+const maxListedDeletes = 5
+
+type (
+	filesDeleted struct {
+		ids     []lockbook.FileID
+		trashed bool
+		// untrashable are the files that couldn't be moved to the trash (such as files in
+		// a folder someone else shared) and can only be deleted permanently.
+		untrashable []nameAndID
+		err         error
+	}
+	trashLoaded struct {
+		enabled bool
+		entries []lockbook.TrashEntry
+		err     error
+	}
+	// trashChecked is whether the trash can be used for a delete prompt.
+	trashChecked struct {
+		prompt  *deleteFilesPrompt
+		enabled bool
+		err     error
+	}
+	trashChanged struct {
+		status string
+		// parent is the folder a file was restored into (if any) so it can be refreshed.
+		parent lockbook.FileID
+		err    error
+	}
+)
+
+func (filesDeleted) implsWsUpdate() {}
+func (trashLoaded) implsWsUpdate()  {}
+func (trashChecked) implsWsUpdate() {}
+func (trashChanged) implsWsUpdate() {}
+
+// withoutTrash leaves the trash folder out of a list of root's children.
+func withoutTrash(rootID lockbook.FileID, files []lockbook.File) []lockbook.File {
+	for i := range files {
+		if files[i].Parent == rootID && files[i].Name == lockbook.TrashDirName && files[i].IsDir() {
+			return append(files[:i:i], files[i+1:]...)
+		}
+	}
+	return files
+}
+
+// deleteFilesPrompt confirms deleting files, offering to move them to the trash if it's
+// enabled.
+type deleteFilesPrompt struct {
+	files     []nameAndID
+	canTrash  bool
+	msg       string
+	trashBtn  widget.Clickable
+	deleteBtn widget.Clickable
+	cancelBtn widget.Clickable
+}
+
+func (deleteFilesPrompt) implsModal() {}
+
+func (ws *workspace) deleteSelectedFiles() {
+	var files []nameAndID
+	for i := range ws.expl.entries {
+		if en := &ws.expl.entries[i]; en.isSelected() {
+			files = append(files, nameAndID{name: en.name, id: en.id})
+		}
+	}
+	ws.promptDelete(files)
+}
+
+func (ws *workspace) deleteTreeSelection() {
+	sel := ws.tree.selection()
+	files := make([]nameAndID, len(sel.entries))
+	for i, en := range sel.entries {
+		files[i] = nameAndID{name: en.file.Name, id: en.file.ID}
+	}
+	ws.promptDelete(files)
+}
+
+func (ws *workspace) promptDelete(files []nameAndID) {
+	if len(files) == 0 {
+		return
+	}
+	p := &deleteFilesPrompt{files: files}
+	ws.modals = append(ws.modals, p)
+	go checkTrash(ws.core, ws.updates, p)
+}
+
+// checkTrash opens the trash (which reads its index through the core) to see whether a
+// delete prompt can offer it.
+func checkTrash(core lockbook.Core, updates chan<- legitUpdate, p *deleteFilesPrompt) {
+	u := trashChecked{prompt: p}
+	_, err := lockbook.OpenTrash(core)
+	switch {
+	case err == nil:
+		u.enabled = true
+	case !errors.Is(err, lockbook.ErrTrashDisabled):
+		u.err = fmt.Errorf("opening trash: %w", err)
+	}
+	updates <- u
+}
+
+// setTrashChecked shows the trash button on a delete prompt if it's still open and the
+// trash is enabled.
+func (ws *workspace) setTrashChecked(u trashChecked) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, u.err)
+	}
+	for _, m := range ws.modals {
+		if m == u.prompt {
+			u.prompt.canTrash = u.enabled
+		}
+	}
+}
+
+func deleteFiles(core lockbook.Core, updates chan<- legitUpdate, files []nameAndID, toTrash bool) {
+	u := filesDeleted{trashed: toTrash}
+	defer func() { updates <- u }()
+
+	var trash *lockbook.Trash
+	if toTrash {
+		if trash, u.err = lockbook.OpenTrash(core); u.err != nil {
+			return
+		}
+	}
+	for _, f := range files {
+		if toTrash {
+			_, err := trash.Move(f.id)
+			if lockbook.IsTrashUnsupported(err) {
+				u.untrashable = append(u.untrashable, f)
+				continue
+			}
+			if err != nil {
+				u.err = err
+				return
+			}
+		} else if err := core.DeleteFile(f.id); err != nil {
+			u.err = fmt.Errorf("deleting %q: %w", f.name, err)
+			return
+		}
+		u.ids = append(u.ids, f.id)
+	}
+}
+
+func (ws *workspace) handleFilesDeleted(u filesDeleted) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, u.err)
+	}
+	for _, id := range u.ids {
+		ws.expl.removeID(id)
+		ws.tree.remove(id)
+		// Trashed files still exist, so their tabs can stay open.
+		if !u.trashed {
+			for i := range ws.tabs {
+				if ws.tabs[i].id == id {
+					ws.closeTab(i)
+					break
+				}
+			}
+		}
+	}
+	switch n := len(u.ids); {
+	case n == 1 && u.trashed:
+		ws.botStatus = "Moved 1 file to the trash"
+	case n > 1 && u.trashed:
+		ws.botStatus = fmt.Sprintf("Moved %d files to the trash", n)
+	case n == 1:
+		ws.botStatus = "Deleted 1 file"
+	case n > 1:
+		ws.botStatus = fmt.Sprintf("Deleted %d files", n)
+	}
+	if len(u.untrashable) > 0 {
+		ws.modals = append(ws.modals, &deleteFilesPrompt{
+			files: u.untrashable,
+			msg:   "These files are in a folder shared with you, so they can't be moved to the trash.",
+		})
+	}
+}
+
+func (ws *workspace) layDeleteFilesPrompt(gtx C, th *material.Theme, p *deleteFilesPrompt) D {
+	closeModal := func() { ws.modals = ws.modals[:len(ws.modals)-1] }
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			closeModal()
+			return D{}
+		}
+	}
+	if p.cancelBtn.Clicked() {
+		closeModal()
+		return D{}
+	}
+	if p.trashBtn.Clicked() {
+		go deleteFiles(ws.core, ws.updates, p.files, true)
+		closeModal()
+		return D{}
+	}
+	if p.deleteBtn.Clicked() {
+		go deleteFiles(ws.core, ws.updates, p.files, false)
+		closeModal()
+		return D{}
+	}
+
+	names := make([]string, 0, maxListedDeletes+1)
+	for i, f := range p.files {
+		if i == maxListedDeletes {
+			names = append(names, fmt.Sprintf("and %d more", len(p.files)-i))
+			break
+		}
+		names = append(names, f.name)
+	}
+	title := fmt.Sprintf("Delete %d files?", len(p.files))
+	if len(p.files) == 1 {
+		title = fmt.Sprintf("Delete %q?", p.files[0].name)
+	}
+
+	btns := []groupButton{{click: &p.cancelBtn, text: "Cancel"}}
+	if p.canTrash {
+		btns = append(btns, groupButton{click: &p.trashBtn, text: "Move to Trash"})
+	}
+	btns = append(btns, groupButton{click: &p.deleteBtn, text: "Delete Permanently"})
+
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body1(th, title)
+				lbl.Font.Weight = text.Bold
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if p.msg == "" {
+					return D{}
+				}
+				return layout.Inset{Top: inset}.Layout(gtx, material.Body2(th, p.msg).Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if len(p.files) == 1 {
+					return D{}
+				}
+				lbl := material.Body2(th, strings.Join(names, "\n"))
+				lbl.Color.A /= 2
+				return layout.Inset{Top: inset}.Layout(gtx, lbl.Layout)
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(func(gtx C) D {
+				return toolbarButtons(th).layout(gtx, btns)
+			}),
+		)
+	})
+}
+
+// trashView is a modal listing the files in the trash with buttons to restore or
+// permanently delete each of them.
+type trashView struct {
+	loaded      bool
+	enabled     bool
+	entries     []lockbook.TrashEntry
+	err         error
+	list        widget.List
+	restoreBtns []widget.Clickable
+	deleteBtns  []widget.Clickable
+	emptyBtn    widget.Clickable
+	enableBtn   widget.Clickable
+}
+
+func (trashView) implsModal() {}
+
+func (ws *workspace) openTrashView() {
+	tv := &trashView{}
+	tv.list.Axis = layout.Vertical
+	ws.modals = append(ws.modals, tv)
+	go loadTrash(ws.core, ws.updates)
+}
+
+func (ws *workspace) trashView() *trashView {
+	for _, m := range ws.modals {
+		if tv, ok := m.(*trashView); ok {
+			return tv
+		}
+	}
+	return nil
+}
+
+func loadTrash(core lockbook.Core, updates chan<- legitUpdate) {
+	u := trashLoaded{}
+	defer func() { updates <- u }()
+
+	t, err := lockbook.OpenTrash(core)
+	if errors.Is(err, lockbook.ErrTrashDisabled) {
+		return
+	}
+	if err != nil {
+		u.err = fmt.Errorf("opening trash: %w", err)
+		return
+	}
+	u.enabled = true
+	u.entries, u.err = t.List()
+}
+
+// changeTrash runs an action on the trash and reloads it afterwards.
+func changeTrash(core lockbook.Core, updates chan<- legitUpdate, fn func(t *lockbook.Trash) trashChanged) {
+	t, err := lockbook.OpenTrash(core)
+	if err != nil && !errors.Is(err, lockbook.ErrTrashDisabled) {
+		updates <- trashChanged{err: fmt.Errorf("opening trash: %w", err)}
+		return
+	}
+	updates <- fn(t)
+	loadTrash(core, updates)
+}
+
+func (ws *workspace) setTrashLoaded(u trashLoaded) {
+	tv := ws.trashView()
+	if tv == nil {
+		return
+	}
+	tv.loaded = true
+	tv.enabled = u.enabled
+	tv.entries = u.entries
+	tv.err = u.err
+	tv.restoreBtns = make([]widget.Clickable, len(u.entries))
+	tv.deleteBtns = make([]widget.Clickable, len(u.entries))
+}
+
+func (ws *workspace) handleTrashChanged(u trashChanged) {
+	if u.err != nil {
+		ws.bgErrs = append(ws.bgErrs, u.err)
+		return
+	}
+	if u.status != "" {
+		ws.botStatus = u.status
+	}
+	if !u.parent.IsNil() {
+		ws.refreshDir(u.parent)
+	}
+}
+
+// refreshDir reloads a folder's children wherever they're currently shown.
+func (ws *workspace) refreshDir(id lockbook.FileID) {
+	rootID := ws.tree.root.file.ID
+	if ws.expl.targetID == id || (ws.expl.targetID.IsNil() && id == rootID) {
+		ws.openDir(ws.expl.targetID)
+	}
+	if en := ws.tree.find(id); en != nil && en.isExpanded {
+		ws.openDirTree(id)
+	}
+}
+
+func (ws *workspace) layTrashView(gtx C, th *material.Theme, tv *trashView) D {
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+	if tv.enableBtn.Clicked() {
+		go changeTrash(ws.core, ws.updates, func(*lockbook.Trash) trashChanged {
+			if _, err := lockbook.EnableTrash(ws.core); err != nil {
+				return trashChanged{err: err}
+			}
+			return trashChanged{status: "Trash enabled"}
+		})
+	}
+	if tv.emptyBtn.Clicked() {
+		go changeTrash(ws.core, ws.updates, func(t *lockbook.Trash) trashChanged {
+			deleted, err := t.Empty(0)
+			return trashChanged{status: fmt.Sprintf("Deleted %d files from the trash", len(deleted)), err: err}
+		})
+	}
+	for i := range tv.entries {
+		e := tv.entries[i]
+		if tv.restoreBtns[i].Clicked() {
+			go changeTrash(ws.core, ws.updates, func(t *lockbook.Trash) trashChanged {
+				p, err := t.Restore(e.ID)
+				if err != nil {
+					return trashChanged{err: err}
+				}
+				u := trashChanged{status: "Restored " + p}
+				if f, err := ws.core.FileByID(e.ID); err == nil {
+					u.parent = f.Parent
+				}
+				return u
+			})
+		}
+		if tv.deleteBtns[i].Clicked() {
+			go changeTrash(ws.core, ws.updates, func(t *lockbook.Trash) trashChanged {
+				if err := t.Delete(e.ID); err != nil {
+					return trashChanged{err: err}
+				}
+				return trashChanged{status: "Deleted " + e.Name}
+			})
+		}
+	}
+
+	btnStyle := toolbarButtons(th)
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx C) D {
+						lbl := material.Body1(th, "Trash")
+						lbl.Font.Weight = text.Bold
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if !tv.enabled || len(tv.entries) == 0 {
+							return D{}
+						}
+						return btnStyle.layout(gtx, []groupButton{{click: &tv.emptyBtn, text: "Empty Trash"}})
+					}),
+				)
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(func(gtx C) D {
+				switch {
+				case tv.err != nil:
+					return material.Body2(th, "error: "+tv.err.Error()).Layout(gtx)
+				case !tv.loaded:
+					return material.Loader(th).Layout(gtx)
+				case !tv.enabled:
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(material.Body2(th, "The trash isn't enabled, so deleted files are removed permanently.").Layout),
+						layout.Rigid(layout.Spacer{Height: inset}.Layout),
+						layout.Rigid(func(gtx C) D {
+							return btnStyle.layout(gtx, []groupButton{{click: &tv.enableBtn, text: "Enable Trash"}})
+						}),
+					)
+				case len(tv.entries) == 0:
+					return material.Body2(th, "The trash is empty.").Layout(gtx)
+				}
+				if gtx.Constraints.Max.Y > 360 {
+					gtx.Constraints.Max.Y = 360
+				}
+				return material.List(th, &tv.list).Layout(gtx, len(tv.entries), func(gtx C, i int) D {
+					return layout.Inset{Bottom: insetHalf}.Layout(gtx, func(gtx C) D {
+						return tv.layEntry(gtx, th, btnStyle, i)
+					})
+				})
+			}),
+		)
+	})
+}
+
+func (tv *trashView) layEntry(gtx C, th *material.Theme, btnStyle buttonGroupStyle, i int) D {
+	e := &tv.entries[i]
+	origPath := e.OrigPath
+	if origPath == "" {
+		origPath = "unknown location"
+	}
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					lbl := material.Body2(th, e.Name)
+					lbl.MaxLines = 1
+					return lbl.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					lbl := material.Caption(th, origPath+" · "+e.Deleted.Local().Format("2 Jan 2006 15:04"))
+					lbl.MaxLines = 1
+					lbl.Color.A /= 2
+					return lbl.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return btnStyle.layout(gtx, []groupButton{
+				{click: &tv.restoreBtns[i], text: "Restore"},
+				{click: &tv.deleteBtns[i], text: "Delete"},
+			})
+		}),
+	)
+}
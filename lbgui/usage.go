@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gioui.org/gesture"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/steverusso/lockbook-x/go-lockbook"
+)
+
+const numLargestDocs = 10
+
+type usageLoaded struct {
+	tree    *lockbook.UsageNode
+	largest []*lockbook.UsageNode
+	metrics lockbook.UsageMetrics
+	err     error
+}
+
+func (usageLoaded) implsWsUpdate() {}
+
+// usageView is a modal showing how much of the data cap is used, a bar for each file
+// within the current folder (which can be drilled into) and the largest documents.
+type usageView struct {
+	loaded  bool
+	tree    *lockbook.UsageNode
+	largest []*lockbook.UsageNode
+	metrics lockbook.UsageMetrics
+	err     error
+	// path holds the folders that have been drilled into, starting with root.
+	path       []*lockbook.UsageNode
+	list       widget.List
+	rowBtns    []widget.Clickable
+	upBtn      widget.Clickable
+	largestBtn widget.Clickable
+	showLarge  bool
+}
+
+func (usageView) implsModal() {}
+
+func (ws *workspace) openUsageView() {
+	uv := &usageView{}
+	uv.list.Axis = layout.Vertical
+	ws.modals = append(ws.modals, uv)
+	go loadUsage(ws.core, ws.updates)
+}
+
+func loadUsage(core lockbook.Core, updates chan<- legitUpdate) {
+	u := usageLoaded{}
+	defer func() { updates <- u }()
+
+	root, err := core.GetRoot()
+	if err != nil {
+		u.err = fmt.Errorf("getting root: %w", err)
+		return
+	}
+	if u.metrics, err = core.GetUsage(); err != nil {
+		u.err = fmt.Errorf("getting usage: %w", err)
+		return
+	}
+	files, err := core.GetAndGetChildrenRecursively(root.ID)
+	if err != nil {
+		u.err = fmt.Errorf("getting all files: %w", err)
+		return
+	}
+	u.tree = lockbook.AggregateUsage(root, "/", files, u.metrics.Usages)
+	u.largest = u.tree.Largest(numLargestDocs)
+}
+
+func (ws *workspace) setUsageLoaded(u usageLoaded) {
+	for _, m := range ws.modals {
+		if uv, ok := m.(*usageView); ok {
+			uv.loaded = true
+			uv.tree = u.tree
+			uv.largest = u.largest
+			uv.metrics = u.metrics
+			uv.err = u.err
+			if u.tree != nil {
+				uv.path = []*lockbook.UsageNode{u.tree}
+			}
+			return
+		}
+	}
+}
+
+// rows returns the nodes currently listed.
+func (uv *usageView) rows() []*lockbook.UsageNode {
+	if uv.showLarge {
+		return uv.largest
+	}
+	return uv.path[len(uv.path)-1].Children
+}
+
+func (ws *workspace) layUsageView(gtx C, th *material.Theme, uv *usageView) D {
+	for _, e := range ws.modalCatch.Events(gtx) {
+		if e.Type == gesture.TypePress {
+			ws.modals = ws.modals[:len(ws.modals)-1]
+			return D{}
+		}
+	}
+
+	var rows []*lockbook.UsageNode
+	if uv.loaded && uv.err == nil {
+		if uv.largestBtn.Clicked() {
+			uv.showLarge = !uv.showLarge
+		}
+		if uv.upBtn.Clicked() && len(uv.path) > 1 {
+			uv.path = uv.path[:len(uv.path)-1]
+		}
+		rows = uv.rows()
+		if len(uv.rowBtns) < len(rows) {
+			uv.rowBtns = make([]widget.Clickable, len(rows))
+		}
+		for i, n := range rows {
+			if uv.rowBtns[i].Clicked() && n.File.IsDir() && !uv.showLarge {
+				uv.path = append(uv.path, n)
+				rows = uv.rows()
+				break
+			}
+		}
+	}
+
+	btnStyle := toolbarButtons(th)
+	return layModalBox(gtx, th, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx C) D {
+						lbl := material.Body1(th, "Usage")
+						lbl.Font.Weight = text.Bold
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if !uv.loaded || uv.err != nil {
+							return D{}
+						}
+						btns := []groupButton{{click: &uv.largestBtn, text: "Largest Documents"}}
+						if uv.showLarge {
+							btns[0].text = "By Folder"
+						} else if len(uv.path) > 1 {
+							btns = append([]groupButton{{click: &uv.upBtn, text: "Up"}}, btns...)
+						}
+						return btnStyle.layout(gtx, btns)
+					}),
+				)
+			}),
+			layout.Rigid(layout.Spacer{Height: 12}.Layout),
+			layout.Rigid(func(gtx C) D {
+				switch {
+				case uv.err != nil:
+					return material.Body2(th, "error: "+uv.err.Error()).Layout(gtx)
+				case !uv.loaded:
+					return material.Loader(th).Layout(gtx)
+				}
+				return uv.layContent(gtx, th, rows)
+			}),
+		)
+	})
+}
+
+func (uv *usageView) layContent(gtx C, th *material.Theme, rows []*lockbook.UsageNode) D {
+	used, dataCap := uv.metrics.ServerUsage.Exact, uv.metrics.DataCap.Exact
+	var pct float32
+	if dataCap > 0 {
+		pct = float32(used) / float32(dataCap)
+	}
+	heading := "Largest documents"
+	if !uv.showLarge {
+		heading = uv.path[len(uv.path)-1].Path + " · " + formatBytes(int(uv.path[len(uv.path)-1].Size))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(material.Body2(th, fmt.Sprintf("%s of %s data cap used (%.0f%%)",
+			uv.metrics.ServerUsage.Readable, uv.metrics.DataCap.Readable, pct*100)).Layout),
+		layout.Rigid(layout.Spacer{Height: insetHalf}.Layout),
+		layout.Rigid(material.ProgressBar(th, pct).Layout),
+		layout.Rigid(layout.Spacer{Height: 12}.Layout),
+		layout.Rigid(func(gtx C) D {
+			lbl := material.Caption(th, heading)
+			lbl.MaxLines = 1
+			lbl.Color.A /= 2
+			return layout.Inset{Bottom: insetHalf}.Layout(gtx, lbl.Layout)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if len(rows) == 0 {
+				return material.Body2(th, "Nothing here uses any storage.").Layout(gtx)
+			}
+			if gtx.Constraints.Max.Y > 360 {
+				gtx.Constraints.Max.Y = 360
+			}
+			// Bars are relative to the largest row so small folders are still visible.
+			var largest uint64
+			for _, n := range rows {
+				if n.Size > largest {
+					largest = n.Size
+				}
+			}
+			return material.List(th, &uv.list).Layout(gtx, len(rows), func(gtx C, i int) D {
+				return uv.layRow(gtx, th, rows[i], largest, &uv.rowBtns[i])
+			})
+		}),
+	)
+}
+
+func (uv *usageView) layRow(gtx C, th *material.Theme, n *lockbook.UsageNode, largest uint64, btn *widget.Clickable) D {
+	name := n.File.Name
+	if n.File.IsDir() {
+		name += "/"
+	}
+	if uv.showLarge {
+		name = n.Path
+	}
+	return material.Clickable(gtx, btn, func(gtx C) D {
+		return layout.UniformInset(insetHalf).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{}.Layout(gtx,
+						layout.Flexed(1, func(gtx C) D {
+							lbl := material.Body2(th, name)
+							lbl.MaxLines = 1
+							return lbl.Layout(gtx)
+						}),
+						layout.Rigid(material.Caption(th, formatBytes(int(n.Size))).Layout),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					w := gtx.Constraints.Max.X
+					if largest > 0 {
+						w = int(float64(w) * float64(n.Size) / float64(largest))
+					}
+					size := image.Pt(w, 4)
+					clr := th.ContrastBg
+					if !n.File.IsDir() {
+						clr.A /= 2
+					}
+					paint.FillShape(gtx.Ops, clr, clip.Rect{Max: size}.Op())
+					return D{Size: image.Pt(gtx.Constraints.Max.X, size.Y)}
+				}),
+			)
+		})
+	})
+}
@@ -22,9 +22,14 @@ var (
 	iconDirectory  = mustIcon(icons.FileFolderOpen)
 	iconDocument   = mustIcon(icons.ActionDescription)
 	iconHome       = mustIcon(icons.ActionHome)
+	iconInbox      = mustIcon(icons.ContentInbox)
 	iconNewDoc     = mustIcon(icons.ActionNoteAdd)
 	iconNewFolder  = mustIcon(icons.FileCreateNewFolder)
 	iconRegFile    = mustIcon(icons.ActionDescription)
+	iconShare      = mustIcon(icons.SocialShare)
+	iconSync       = mustIcon(icons.NotificationSync)
+	iconTrash      = mustIcon(icons.ActionDelete)
+	iconUsage      = mustIcon(icons.EditorInsertChart)
 )
 
 // mustIcon returns a new `widget.Icon` for the given byte slice. It panics on error.
@@ -191,6 +196,21 @@ func (g *buttonGroupStyle) drawButton(gtx C, b groupButton) D {
 	return D{Size: image.Pt(dims.Size.X+12, dims.Size.Y+12)}
 }
 
+// layBadge draws the text as a small pill in the theme's contrast colors.
+func layBadge(gtx C, th *material.Theme, txt string) D {
+	m := op.Record(gtx.Ops)
+	dims := layout.Inset{Left: inset, Right: inset}.Layout(gtx, func(gtx C) D {
+		lbl := material.Caption(th, txt)
+		lbl.Color = th.ContrastFg
+		return lbl.Layout(gtx)
+	})
+	call := m.Stop()
+	rr := clip.UniformRRect(image.Rectangle{Max: dims.Size}, dims.Size.Y/2)
+	paint.FillShape(gtx.Ops, th.ContrastBg, rr.Op(gtx.Ops))
+	call.Add(gtx.Ops)
+	return dims
+}
+
 func darken(c color.NRGBA, f float32) color.NRGBA {
 	return color.NRGBA{
 		R: uint8(float32(c.R) * (1 - f)),
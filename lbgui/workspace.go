@@ -27,8 +27,9 @@ import (
 var logoBytes []byte
 
 const (
-	autoSyncInterval = time.Second * 5
-	autoSaveInterval = time.Second * 3
+	autoSyncInterval   = time.Second * 5
+	autoSaveInterval   = time.Second * 3
+	maxOfflineInterval = time.Minute * 5
 )
 
 type wsLayoutMode uint8
@@ -77,9 +78,13 @@ type (
 		err   error
 	}
 	openFileResult struct {
-		id   lockbook.FileID
-		data []byte
-		err  error
+		id        lockbook.FileID
+		data      []byte
+		kind      contentKind
+		mimeType  string
+		img       image.Image
+		decodeErr error
+		err       error
 	}
 	autoSaveScan  struct{}
 	queuedSave    struct{ id lockbook.FileID }
@@ -88,12 +93,19 @@ type (
 		err  error
 		when time.Time
 	}
-	startSync  struct{ typ syncType }
-	syncResult struct {
+	startSync    struct{ typ syncType }
+	syncProgress struct{ sp lockbook.SyncProgress }
+	syncResult   struct {
 		typ       syncType
 		newStatus string
 		statusErr error
 		syncErr   error
+		isOffline bool
+		numQueued int
+	}
+	workCalcResult struct {
+		work []pendingWork
+		err  error
 	}
 )
 
@@ -104,18 +116,21 @@ func (autoSaveScan) implsWsUpdate()      {}
 func (queuedSave) implsWsUpdate()        {}
 func (completedSave) implsWsUpdate()     {}
 func (startSync) implsWsUpdate()         {}
+func (syncProgress) implsWsUpdate()      {}
 func (syncResult) implsWsUpdate()        {}
+func (workCalcResult) implsWsUpdate()    {}
 
 type workspace struct {
-	mode       wsLayoutMode
-	core       lockbook.Core
-	updates    chan<- legitUpdate
-	tabs       []tab
-	activeTab  int
-	tabList    widget.List
-	bgErrs     []error
-	modals     []modal
-	modalCatch gesture.Click
+	mode         wsLayoutMode
+	core         lockbook.Core
+	conflictCore *lockbook.ConflictCore
+	updates      chan<- legitUpdate
+	tabs         []tab
+	activeTab    int
+	tabList      widget.List
+	bgErrs       []error
+	modals       []modal
+	modalCatch   gesture.Click
 
 	tree fileTree
 	logo widget.Image
@@ -125,6 +140,20 @@ type workspace struct {
 	animPct   float32
 	botStatus string
 
+	syncProg    lockbook.SyncProgress
+	syncingID   lockbook.FileID
+	pending     []pendingWork
+	pendingBtn  widget.Clickable
+	syncDetails syncDetailsPopover
+
+	pendingShares []lockbook.File
+	seenShares    map[lockbook.FileID]bool
+	numNewShares  int
+	sharesBadge   widget.Clickable
+
+	conflicts      []lockbook.Conflict
+	conflictBanner conflictBanner
+
 	saveQueue     queue[saveRequest]
 	lastActionAt  time.Time
 	lastEditAt    time.Time
@@ -133,11 +162,17 @@ type workspace struct {
 	autoSyncTimer *time.Timer
 	manualSync    chan struct{}
 	isSyncing     bool
+	isOffline     bool
+	offlineWait   lockbook.Backoff
+	lastSyncErr   string
+	sessionSeq    uint64
 }
 
 func newWorkspace(updates chan<- legitUpdate, h handoffToWorkspace) workspace {
 	ws := workspace{
 		core:          h.core,
+		conflictCore:  h.conflictCore,
+		conflicts:     h.conflicts,
 		updates:       updates,
 		animPct:       1,
 		modals:        make([]modal, 0, 3),
@@ -146,14 +181,17 @@ func newWorkspace(updates chan<- legitUpdate, h handoffToWorkspace) workspace {
 		autoSaveTimer: time.NewTimer(autoSaveInterval),
 		autoSyncTimer: time.NewTimer(autoSyncInterval),
 		manualSync:    make(chan struct{}),
+		offlineWait:   lockbook.Backoff{Min: autoSyncInterval, Max: maxOfflineInterval},
 	}
 	ws.tree.list.List.Axis = layout.Vertical
-	ws.tree.root = newFileTreeEntry(h.root, h.rootFiles)
+	rootFiles := withoutTrash(h.root.ID, h.rootFiles)
+	ws.tree.root = newFileTreeEntry(h.root, rootFiles)
 	ws.tree.root.isExpanded = true
 	ws.logo = buildLogo()
 	ws.tabList.Axis = layout.Vertical
 	ws.expl.entryList.Axis = layout.Vertical
-	ws.expl.populate(nil, h.rootFiles)
+	ws.expl.populate(nil, rootFiles)
+	ws.syncDetails.list.Axis = layout.Vertical
 	if h.lastSynced != "" {
 		ws.botStatus = "Synced " + h.lastSynced
 	}
@@ -161,7 +199,10 @@ func newWorkspace(updates chan<- legitUpdate, h handoffToWorkspace) workspace {
 }
 
 func buildLogo() widget.Image {
-	img := decodeImage(logoBytes)
+	img, err := decodeImage(logoBytes)
+	if err != nil {
+		panic("decoding logo: " + err.Error())
+	}
 	imgOp := paint.NewImageOp(img)
 	sz := 320
 	if imgOp.Size().X != sz {
@@ -203,10 +244,11 @@ func (ws *workspace) setLastEditAt(t time.Time) {
 }
 
 // setLastActionAt triggers a sync if the duration between now and the next sync is longer
-// than the auto-sync interval.
+// than the auto-sync interval. This doesn't apply while offline since the syncs are backing
+// off on purpose.
 func (ws *workspace) setLastActionAt(t time.Time) {
 	ws.lastActionAt = t
-	if !ws.isSyncing && time.Until(ws.nextSyncAt) > autoSyncInterval {
+	if !ws.isSyncing && !ws.isOffline && time.Until(ws.nextSyncAt) > autoSyncInterval {
 		ws.manualSync <- struct{}{}
 	}
 }
@@ -252,14 +294,35 @@ func (ws *workspace) tabByID(id lockbook.FileID) *tab {
 	return nil
 }
 
-func (ws *workspace) sync(typ syncType) {
+// sync performs a full sync. If the workspace is offline, the server is probed first and
+// the sync only happens if the probe succeeds.
+func (ws *workspace) sync(typ syncType, isOffline bool) {
 	r := syncResult{typ: typ}
 	defer func() { ws.updates <- r }()
 
-	if err := ws.core.SyncAll(nil); err != nil {
+	var err error
+	if isOffline {
+		err = lockbook.Ping(ws.core)
+	}
+	if err == nil {
+		err = ws.core.SyncAll(func(sp lockbook.SyncProgress) {
+			ws.updates <- syncProgress{sp}
+		})
+	}
+	if err != nil {
 		r.syncErr = fmt.Errorf("syncing: %w", err)
+		if lockbook.IsConnectivityError(err) {
+			r.isOffline = true
+			r.numQueued, err = lockbook.CountLocalChanges(ws.core)
+			if err != nil {
+				r.statusErr = fmt.Errorf("counting local changes: %w", err)
+			}
+		}
 		return
 	}
+	ws.updates <- calcWork(ws.core)
+	ws.updates <- loadPendingShares(ws.core)
+	ws.updates <- loadConflicts(ws.conflictCore)
 	lastSynced, err := ws.core.GetLastSyncedHumanString()
 	if err != nil {
 		r.statusErr = fmt.Errorf("getting last synced: %w", err)
@@ -274,31 +337,31 @@ func (ws *workspace) handleUpdate(u wsUpdate) {
 		if u.err != nil {
 			log.Printf("error: %v", u.err)
 		} else if ws.expl.targetID == u.id {
-			ws.expl.populate(u.parents, u.files)
+			ws.expl.populate(u.parents, withoutTrash(ws.tree.root.file.ID, u.files))
 		}
 	case openDirTreeResult:
 		if u.err != nil {
 			log.Printf("error: %v", u.err)
 		} else {
-			ws.tree.populate(u.id, u.files)
+			ws.tree.populate(u.id, withoutTrash(ws.tree.root.file.ID, u.files))
 		}
 	case openFileResult:
 		if u.err != nil {
 			log.Printf("error: %v", u.err)
 		} else {
-			ws.setTabMarkdown(u.id, u.data)
+			ws.setTabContent(u)
 		}
 	case autoSaveScan:
 		if ws.lastEditAt.IsZero() {
 			break
 		}
 		for i := range ws.tabs {
-			if ws.tabs[i].isDirty() {
+			if md := ws.tabs[i].markdown(); md != nil && md.isDirty() {
 				ws.saveQueue.pushBack(saveRequest{
 					id:   ws.tabs[i].id,
-					data: ws.tabs[i].view.Editor.Text(),
+					data: md.view.Editor.Text(),
 				})
-				ws.tabs[i].numQueuedSaves++
+				md.numQueuedSaves++
 			}
 		}
 		sinceLastEdit := time.Since(ws.lastEditAt)
@@ -306,16 +369,17 @@ func (ws *workspace) handleUpdate(u wsUpdate) {
 			ws.autoSaveTimer.Reset(autoSaveInterval)
 		}
 	case queuedSave:
-		if t := ws.tabByID(u.id); t != nil {
-			t.numQueuedSaves++
+		if t := ws.tabByID(u.id); t != nil && t.markdown() != nil {
+			t.markdown().numQueuedSaves++
 		}
 	case completedSave:
 		if u.err != nil {
 			log.Printf("saving %s: %v", u.id, u.err) // todo(steve): needs to get to the ui
 		}
-		if t := ws.tabByID(u.id); t != nil {
-			t.lastSaveAt = u.when
-			t.numQueuedSaves--
+		if t := ws.tabByID(u.id); t != nil && t.markdown() != nil {
+			md := t.markdown()
+			md.lastSaveAt = u.when
+			md.numQueuedSaves--
 		}
 	case startSync:
 		if ws.isSyncing {
@@ -327,15 +391,79 @@ func (ws *workspace) handleUpdate(u wsUpdate) {
 			<-ws.autoSyncTimer.C
 		}
 		ws.isSyncing = true
-		go ws.sync(u.typ)
+		ws.syncProg = lockbook.SyncProgress{}
+		go ws.sync(u.typ, ws.isOffline)
+	case syncProgress:
+		ws.syncProg = u.sp
+		ws.syncingID = pendingWorkID(ws.pending, u.sp)
 	case syncResult:
+		ws.syncingID = uuid.Nil
 		ws.handleSyncResult(u)
+	case sessionSaveTick:
+		s := ws.snapshotSession()
+		go func() {
+			if err := writeSession(s); err != nil {
+				log.Printf("saving session: %v", err)
+			}
+		}()
+	case sessionRestored:
+		ws.applySession(u)
+	case openDrawingResult:
+		ws.setTabDrawing(u)
+	case drawingExported:
+		if u.err != nil {
+			ws.bgErrs = append(ws.bgErrs, u.err)
+		} else {
+			ws.botStatus = "Exported " + u.dest
+		}
+	case filesDeleted:
+		ws.handleFilesDeleted(u)
+	case trashLoaded:
+		ws.setTrashLoaded(u)
+	case trashChecked:
+		ws.setTrashChecked(u)
+	case trashChanged:
+		ws.handleTrashChanged(u)
+	case usageLoaded:
+		ws.setUsageLoaded(u)
+	case fileSharesLoaded:
+		ws.setFileShares(u)
+	case pendingSharesLoaded:
+		ws.setPendingShares(u)
+	case shareChanged:
+		ws.handleShareChanged(u)
+	case conflictsLoaded:
+		ws.setConflicts(u)
+	case conflictResolved:
+		ws.handleConflictResolved(u)
+	case workCalcResult:
+		switch {
+		case lockbook.IsConnectivityError(u.err):
+			// The sync results already report being offline.
+		case u.err != nil:
+			ws.bgErrs = append(ws.bgErrs, u.err)
+		default:
+			ws.pending = u.work
+		}
 	}
 }
 
 func (ws *workspace) handleSyncResult(sr syncResult) {
-	if sr.syncErr != nil {
-		ws.bgErrs = append(ws.bgErrs, sr.syncErr)
+	switch {
+	case sr.isOffline:
+		ws.isOffline = true
+		ws.botStatus = fmt.Sprintf("Offline — about %d local changes queued", sr.numQueued)
+	case sr.syncErr != nil:
+		// Only report an error once if it keeps happening on every sync.
+		if msg := sr.syncErr.Error(); msg != ws.lastSyncErr {
+			ws.bgErrs = append(ws.bgErrs, sr.syncErr)
+			ws.lastSyncErr = msg
+		}
+	default:
+		ws.isOffline = false
+		ws.offlineWait.Reset()
+		ws.lastSyncErr = ""
+		ws.refreshDrawings()
 	}
 	if sr.statusErr != nil {
 		ws.bgErrs = append(ws.bgErrs, sr.statusErr)
@@ -343,6 +471,13 @@ func (ws *workspace) handleSyncResult(sr syncResult) {
 	if sr.newStatus != "" {
 		ws.botStatus = sr.newStatus
 	}
+	if ws.isOffline {
+		wait := ws.offlineWait.Next()
+		ws.autoSyncTimer.Reset(wait)
+		ws.nextSyncAt = time.Now().Add(wait)
+		ws.isSyncing = false
+		return
+	}
 	switch sr.typ {
 	case syncTypeAuto:
 		now := time.Now()
@@ -410,7 +545,9 @@ func (ws *workspace) layoutTreeModeBaseLayer(gtx C, th *material.Theme) D {
 		gtx2.Constraints.Max.X -= sbWidth
 		gtx2.Constraints.Min = gtx2.Constraints.Max
 		offOp := op.Offset(image.Pt(sbWidth, 0)).Push(gtx2.Ops)
-		_ = ws.layTabsNotebook(gtx2, th)
+		_ = ws.layConflictBanner(gtx2, th, func(gtx C) D {
+			return ws.layTabsNotebook(gtx, th)
+		})
 		offOp.Pop()
 	}
 	return D{Size: gtx.Constraints.Max}
@@ -428,6 +565,18 @@ func (ws *workspace) layoutExplMode(gtx C, th *material.Theme) D {
 	if ws.expl.mkdirBtn.Clicked() {
 		ws.modals = append(ws.modals, newCreateFilePrompt(lockbook.FileTypeFolder{}))
 	}
+	if ws.expl.trashBtn.Clicked() {
+		ws.openTrashView()
+	}
+	if ws.expl.usageBtn.Clicked() {
+		ws.openUsageView()
+	}
+	if ws.expl.shareBtn.Clicked() {
+		ws.shareSelectedFile()
+	}
+	if ws.expl.inboxBtn.Clicked() {
+		ws.openShareInbox()
+	}
 
 	_ = ws.layBaseLayer(gtx, th)
 	ws.layModalLayer(gtx, th)
@@ -443,7 +592,12 @@ func (ws *workspace) layBaseLayer(gtx C, th *material.Theme) D {
 	drawBotBar := m.Stop()
 
 	gtx.Constraints.Max.Y -= botBarDims.Size.Y
-	_ = ws.layExplorerAndTabs(gtx, th)
+	_ = ws.layConflictBanner(gtx, th, func(gtx C) D {
+		return ws.layExplorerAndTabs(gtx, th)
+	})
+	if ws.syncDetails.isOpen {
+		ws.laySyncDetails(gtx, th)
+	}
 
 	// Offset to after the explorer & tabs to place the bottom bar.
 	offOp := op.Offset(image.Pt(0, gtx.Constraints.Max.Y)).Push(gtx.Ops)
@@ -455,6 +609,13 @@ func (ws *workspace) layBaseLayer(gtx C, th *material.Theme) D {
 }
 
 func (ws *workspace) layBottomBar(gtx C, th *material.Theme) D {
+	if ws.pendingBtn.Clicked() {
+		ws.toggleSyncDetails()
+	}
+	if ws.sharesBadge.Clicked() {
+		ws.openShareInbox()
+	}
+
 	// background
 	paint.FillShape(gtx.Ops, lighten(th.Bg, 0.1), clip.Rect{Max: gtx.Constraints.Max}.Op())
 
@@ -464,8 +625,11 @@ func (ws *workspace) layBottomBar(gtx C, th *material.Theme) D {
 	offOp := op.Offset(image.Pt(inset, height/2-diam/2)).Push(gtx.Ops)
 	circle := clip.Ellipse{Max: image.Pt(diam, diam)}
 	clr := color.NRGBA{0, 255, 0, 255}
-	if len(ws.bgErrs) > 0 {
+	switch {
+	case len(ws.bgErrs) > 0:
 		clr = color.NRGBA{255, 0, 0, 255}
+	case ws.isOffline:
+		clr = color.NRGBA{150, 150, 150, 255}
 	}
 	paint.FillShape(gtx.Ops, clr, circle.Op(gtx.Ops))
 	offOp.Pop()
@@ -474,9 +638,67 @@ func (ws *workspace) layBottomBar(gtx C, th *material.Theme) D {
 	lblDims := material.Caption(th, ws.botStatus).Layout(gtx)
 	lblCall := m.Stop()
 
-	offOp = op.Offset(image.Pt(inset*2+diam, height/2-lblDims.Size.Y/2)).Push(gtx.Ops)
+	xOffset := inset*2 + diam
+	offOp = op.Offset(image.Pt(xOffset, height/2-lblDims.Size.Y/2)).Push(gtx.Ops)
 	lblCall.Add(gtx.Ops)
 	offOp.Pop()
+	xOffset += lblDims.Size.X + inset*2
+
+	// sync progress
+	if ws.isSyncing && ws.syncProg.Total > 0 {
+		const barWidth = 160
+		gtx1 := gtx
+		gtx1.Constraints.Min.X = barWidth
+		gtx1.Constraints.Max.X = barWidth
+		pct := float32(ws.syncProg.Progress) / float32(ws.syncProg.Total)
+		offOp = op.Offset(image.Pt(xOffset, 0)).Push(gtx.Ops)
+		vertCenter(gtx1, height, material.ProgressBar(th, pct).Layout)
+		offOp.Pop()
+		xOffset += barWidth + inset
+
+		gtx2 := gtx
+		gtx2.Constraints.Max.X -= xOffset
+		offOp = op.Offset(image.Pt(xOffset, 0)).Push(gtx.Ops)
+		lbl := material.Caption(th, syncProgressText(ws.syncProg))
+		lbl.MaxLines = 1
+		lbl.Color.A /= 2
+		vertCenter(gtx2, height, lbl.Layout)
+		offOp.Pop()
+	}
+
+	// pending work (right aligned)
+	{
+		m := op.Record(gtx.Ops)
+		gtx.Constraints.Min = image.Point{}
+		dims := ws.pendingBtn.Layout(gtx, func(gtx C) D {
+			lbl := material.Caption(th, pendingWorkSummary(ws.pending))
+			if !ws.pendingBtn.Hovered() && !ws.syncDetails.isOpen {
+				lbl.Color.A /= 2
+			}
+			return lbl.Layout(gtx)
+		})
+		call := m.Stop()
+		xRight := gtx.Constraints.Max.X - dims.Size.X - inset
+		offOp := op.Offset(image.Pt(xRight, height/2-dims.Size.Y/2)).Push(gtx.Ops)
+		call.Add(gtx.Ops)
+		offOp.Pop()
+
+		// new pending shares badge
+		if ws.numNewShares > 0 {
+			txt := "1 new share"
+			if ws.numNewShares > 1 {
+				txt = fmt.Sprintf("%d new shares", ws.numNewShares)
+			}
+			m := op.Record(gtx.Ops)
+			dims := ws.sharesBadge.Layout(gtx, func(gtx C) D {
+				return layBadge(gtx, th, txt)
+			})
+			call := m.Stop()
+			offOp := op.Offset(image.Pt(xRight-dims.Size.X-inset*2, height/2-dims.Size.Y/2)).Push(gtx.Ops)
+			call.Add(gtx.Ops)
+			offOp.Pop()
+		}
+	}
 
 	return D{Size: image.Pt(gtx.Constraints.Max.X, height)}
 }
@@ -532,6 +754,20 @@ func (ws *workspace) layModalLayer(gtx C, th *material.Theme) {
 			switch m := ws.modals[len(ws.modals)-1].(type) {
 			case *createFilePrompt:
 				return ws.layCreateFilePrompt(gtx, th, m)
+			case *exportDrawingPrompt:
+				return ws.layExportDrawingPrompt(gtx, th, m)
+			case *deleteFilesPrompt:
+				return ws.layDeleteFilesPrompt(gtx, th, m)
+			case *trashView:
+				return ws.layTrashView(gtx, th, m)
+			case *usageView:
+				return ws.layUsageView(gtx, th, m)
+			case *sharePrompt:
+				return ws.laySharePrompt(gtx, th, m)
+			case *shareInbox:
+				return ws.layShareInbox(gtx, th, m)
+			case *acceptSharePrompt:
+				return ws.layAcceptSharePrompt(gtx, th, m)
 			default:
 				return D{}
 			}